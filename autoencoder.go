@@ -0,0 +1,83 @@
+// autoencoder.go
+package dqn
+
+import "math/rand"
+
+// Autoencoder compresses observations into a lower-dimensional latent code.
+// It is a network with the latent code as its hidden layer, trained to
+// reconstruct its input, so that the Q-network can learn from the code
+// instead of the raw observation.
+type Autoencoder struct {
+	network *QNetwork
+	rng     *rand.Rand
+}
+
+// NewAutoencoder initializes a new Autoencoder mapping observations of
+// inputSize values to latentSize values. Its weights and the order in which
+// Fit visits observations are drawn from seed, or from a random seed if
+// seed is zero.
+func NewAutoencoder(inputSize, latentSize int, activation Activation, seed int64) *Autoencoder {
+	rng := rand.New(newPCGSource(seed))
+	return &Autoencoder{
+		network: newQNetwork(inputSize, latentSize, inputSize, activation, "xavier", rng.Float64),
+		rng:     rng,
+	}
+}
+
+// LatentSize returns the length of the latent code.
+func (a *Autoencoder) LatentSize() int {
+	return a.network.hiddenSize
+}
+
+// Encode returns the latent code of an observation.
+func (a *Autoencoder) Encode(obs []float64) []float64 {
+	return a.network.hidden(obs).RawVector().Data
+}
+
+// Decode reconstructs an observation from its latent code.
+func (a *Autoencoder) Decode(code []float64) []float64 {
+	return a.network.Predict(code)
+}
+
+// Fit trains the autoencoder on the observations of experiences, e.g. from
+// DQN.ReplaySnapshot, for the given number of epochs in random order,
+// and returns the mean reconstruction loss of every epoch.
+func (a *Autoencoder) Fit(experiences []Experience, epochs int, learningRate float64) []float64 {
+	var observations [][]float64
+	for _, exp := range experiences {
+		observations = append(observations, exp.State)
+		if exp.Done {
+			observations = append(observations, exp.NextState)
+		}
+	}
+
+	losses := make([]float64, epochs)
+	for epoch := range losses {
+		for _, i := range a.rng.Perm(len(observations)) {
+			obs := observations[i]
+			reconstruction := a.network.Predict(obs)
+			losses[epoch] += a.network.Loss(reconstruction, obs)
+			a.network.Backward(obs, reconstruction, obs, learningRate)
+		}
+		if len(observations) > 0 {
+			losses[epoch] /= float64(len(observations))
+		}
+	}
+	return losses
+}
+
+// Step returns a pipeline step that replaces observations with their latent
+// codes under the current weights. Add it to the pipeline of the Trainer so
+// that policies are saved with the encoder they were trained on.
+func (a *Autoencoder) Step() (FeatureStep, error) {
+	name, err := activationName(a.network.activation)
+	if err != nil {
+		return FeatureStep{}, err
+	}
+	return FeatureStep{
+		Op:         "encode",
+		Weights:    append([]float64(nil), a.network.w1.RawMatrix().Data...),
+		Biases:     append([]float64(nil), a.network.b1.RawVector().Data...),
+		Activation: name,
+	}, nil
+}
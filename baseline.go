@@ -0,0 +1,103 @@
+// baseline.go
+package dqn
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// Baseline builds a reference policy for env, such as a random policy or a
+// scripted heuristic. seed seeds any randomness of the policy.
+type Baseline func(env Environment, seed int64) func(state []float64) int
+
+// baselines maps names to the baselines reported alongside evaluations.
+var baselines = map[string]Baseline{
+	"random": RandomBaseline,
+}
+
+// RegisterBaseline registers a baseline under name so that evaluation
+// reports include its scores, e.g. a greedy heuristic for the environment.
+func RegisterBaseline(name string, baseline Baseline) {
+	baselines[name] = baseline
+}
+
+// Baselines returns the names of the registered baselines in sorted order.
+func Baselines() []string {
+	names := make([]string, 0, len(baselines))
+	for name := range baselines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RandomBaseline chooses actions uniformly at random.
+func RandomBaseline(env Environment, seed int64) func(state []float64) int {
+	rng := rand.New(newPCGSource(seed))
+	numActions := env.NumActions()
+	return func([]float64) int { return rng.Intn(numActions) }
+}
+
+// EvaluateSeeded runs act on env for the given number of episodes and
+// returns the total reward of each episode. If env can be seeded, episode i
+// starts from seed+i, so that policies evaluated with the same seed face the
+// same episodes.
+func EvaluateSeeded(act func(state []float64) int, env Environment, episodes int, seed int64) []float64 {
+	return evaluateSeeded(act, nil, env, episodes, seed)
+}
+
+// EvaluatePolicySeeded is like EvaluateSeeded for a detached policy, which
+// is reset at the start of every episode.
+func EvaluatePolicySeeded(p *Policy, env Environment, episodes int, seed int64) []float64 {
+	return evaluateSeeded(p.Act, p.Reset, env, episodes, seed)
+}
+
+// evaluateSeeded implements EvaluateSeeded, calling reset, if not nil,
+// before every episode.
+func evaluateSeeded(act func(state []float64) int, reset func(), env Environment, episodes int, seed int64) []float64 {
+	returns := make([]float64, episodes)
+	for i := range returns {
+		if reset != nil {
+			reset()
+		}
+		seedEnv(env, seed+int64(i))
+		state := env.Reset()
+		done := false
+		for !done {
+			var reward float64
+			state, reward, done = env.Step(act(state))
+			returns[i] += reward
+		}
+	}
+	return returns
+}
+
+// EvaluateBaselines evaluates the named baselines, or all registered ones if
+// no names are given, with EvaluateSeeded and reports their returns.
+func EvaluateBaselines(env Environment, episodes int, seed int64, names ...string) (map[string]ReturnReport, error) {
+	if len(names) == 0 {
+		names = Baselines()
+	}
+	reports := make(map[string]ReturnReport, len(names))
+	for _, name := range names {
+		baseline, ok := baselines[name]
+		if !ok {
+			return nil, fmt.Errorf("dqn: unknown baseline %q", name)
+		}
+		returns := EvaluateSeeded(baseline(env, seed), env, episodes, seed)
+		reports[name] = NewReturnReport(returns, 0.1, 10)
+	}
+	return reports, nil
+}
+
+// seedEnv seeds env, or the environment wrapped by a pipeline, if it
+// implements Seeder.
+func seedEnv(env Environment, seed int64) {
+	if p, ok := env.(*pipelineEnv); ok {
+		env = p.Environment
+	}
+	if seeder, ok := env.(Seeder); ok {
+		seeder.Seed(seed)
+	}
+}
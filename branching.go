@@ -0,0 +1,89 @@
+// branching.go
+package dqn
+
+import "math/rand"
+
+// BranchingDQN represents a DQN for multi-discrete action spaces. The Q-network
+// has one output group per action dimension and each group is treated as an
+// independent branch.
+type BranchingDQN struct {
+	qNetwork     *QNetwork
+	branches     []int
+	gamma        float64
+	epsilon      float64
+	learningRate float64
+}
+
+// NewBranchingDQN initializes a new BranchingDQN. branches holds the number of
+// discrete choices for each action dimension.
+func NewBranchingDQN(inputSize, hiddenSize int, branches []int, gamma, epsilon, learningRate float64, activation Activation) *BranchingDQN {
+	outputSize := 0
+	for _, n := range branches {
+		outputSize += n
+	}
+	return &BranchingDQN{
+		qNetwork:     NewQNetwork(inputSize, hiddenSize, outputSize, activation),
+		branches:     branches,
+		gamma:        gamma,
+		epsilon:      epsilon,
+		learningRate: learningRate,
+	}
+}
+
+// branchValues splits the network output into one slice per branch.
+func (d *BranchingDQN) branchValues(qValues []float64) [][]float64 {
+	groups := make([][]float64, len(d.branches))
+	offset := 0
+	for b, n := range d.branches {
+		groups[b] = qValues[offset : offset+n]
+		offset += n
+	}
+	return groups
+}
+
+// GreedyPolicy selects the action with the highest Q-value in every branch.
+func (d *BranchingDQN) GreedyPolicy(state []float64) []int {
+	groups := d.branchValues(d.qNetwork.Predict(state))
+	actions := make([]int, len(groups))
+	for b, q := range groups {
+		actions[b] = Argmax(q)
+	}
+	return actions
+}
+
+// EpsilonGreedyPolicy selects an action per branch, exploring each branch
+// independently with probability epsilon.
+func (d *BranchingDQN) EpsilonGreedyPolicy(state []float64) []int {
+	actions := d.GreedyPolicy(state)
+	for b, n := range d.branches {
+		if rand.Float64() < d.epsilon {
+			actions[b] = rand.Intn(n)
+		}
+	}
+	return actions
+}
+
+// Train trains the Q-network on a multi-discrete action. Each branch gets its
+// own target built from the shared reward and that branch's max next Q-value.
+func (d *BranchingDQN) Train(state, nextState []float64, actions []int, reward int, done bool) {
+	if len(actions) != len(d.branches) {
+		panic("Number of actions does not match number of branches")
+	}
+
+	nextGroups := d.branchValues(d.qNetwork.Predict(nextState))
+	currentQValues := d.qNetwork.Predict(state)
+	target := make([]float64, len(currentQValues))
+	copy(target, currentQValues)
+
+	offset := 0
+	for b, n := range d.branches {
+		value := float64(reward)
+		if !done {
+			value += d.gamma * Max(nextGroups[b])
+		}
+		target[offset+actions[b]] = value
+		offset += n
+	}
+
+	d.qNetwork.Backward(state, currentQValues, target, d.learningRate)
+}
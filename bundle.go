@@ -0,0 +1,120 @@
+// bundle.go
+package dqn
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"runtime/debug"
+	"time"
+)
+
+// BundleManifest describes the contents of a bundle written by ExportBundle.
+type BundleManifest struct {
+	Created time.Time
+	// Module version and VCS revision of the binary that trained the agent
+	Version  string
+	Revision string
+	Modified bool
+	// Seed is the seed actually used, also when Config.Seed was zero. The
+	// subsystem seeds derive from it unless they were set explicitly.
+	Seed       int64
+	Activation string
+	// Files maps the name of every other file in the bundle to its SHA-256 checksum.
+	Files map[string]string
+}
+
+// ExportBundle writes a gzipped tar archive to w holding everything needed to
+// reproduce and review the run: the manifest, the configuration, the final
+// checkpoint (readable by Resume), the policy, the episode and evaluation
+// metrics, and the given evaluation report.
+func (t *Trainer) ExportBundle(w io.Writer, evaluation ReturnReport) error {
+	activation, err := activationName(t.cfg.Activation)
+	if err != nil {
+		return err
+	}
+	manifest := BundleManifest{
+		Created:    time.Now().UTC(),
+		Seed:       t.agent.rngSource.seed,
+		Activation: activation,
+		Files:      map[string]string{},
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		manifest.Version = info.Main.Version
+		manifest.Revision, manifest.Modified = vcsInfo(info)
+	}
+
+	var files []bundleFile
+	add := func(name string, write func(io.Writer) error) error {
+		var buf bytes.Buffer
+		if err := write(&buf); err != nil {
+			return err
+		}
+		sum := sha256.Sum256(buf.Bytes())
+		manifest.Files[name] = hex.EncodeToString(sum[:])
+		files = append(files, bundleFile{name, buf.Bytes()})
+		return nil
+	}
+	writeJSON := func(v any) func(io.Writer) error {
+		return func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(v)
+		}
+	}
+	metrics := struct {
+		Episodes    []EpisodeStats
+		Evaluations []EvalResult
+	}{t.history, t.evaluations}
+
+	for _, f := range []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"config.json", writeJSON(t.cfg)},
+		{"checkpoint.gob", t.writeCheckpoint},
+		{"policy.gob", t.policy().Save},
+		{"metrics.json", writeJSON(metrics)},
+		{"evaluation.json", writeJSON(evaluation)},
+		{"evaluation.txt", func(w io.Writer) error {
+			_, err := io.WriteString(w, evaluation.String())
+			return err
+		}},
+	} {
+		if err := add(f.name, f.write); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSON(manifest)(&buf); err != nil {
+		return err
+	}
+	files = append([]bundleFile{{"manifest.json", buf.Bytes()}}, files...)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		header := &tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.data)), ModTime: manifest.Created}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// bundleFile is a file to be written to a bundle.
+type bundleFile struct {
+	name string
+	data []byte
+}
@@ -0,0 +1,119 @@
+// calibration.go
+package dqn
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// CalibrationPoint pairs the predicted Q-value of a visited state and the
+// chosen action with the discounted return that actually followed.
+type CalibrationPoint struct {
+	Predicted float64
+	Realized  float64
+}
+
+// CalibrationBin is the mean realized return of the points whose predicted
+// Q-values fall into one quantile bin.
+type CalibrationBin struct {
+	Predicted float64 // mean predicted Q-value of the bin
+	Realized  float64 // mean realized return of the bin
+	Count     int
+}
+
+// CalibrationReport measures how well the predicted Q-values match the
+// returns realized by the greedy policy.
+type CalibrationReport struct {
+	Points      []CalibrationPoint
+	Bins        []CalibrationBin
+	MAE         float64
+	RMSE        float64
+	Bias        float64 // mean of predicted minus realized; positive means overestimation
+	Correlation float64
+}
+
+// Calibrate runs policy, including its feature pipeline, on env for the
+// given number of episodes and compares Q(s, a) at every visited state with
+// the return realized from that step on, discounted by gamma. Q-values are
+// learned on rewards multiplied by the configured reward scale, so realized
+// returns are scaled by rewardScale before the comparison. The points are
+// grouped into bins of roughly equal size by predicted value. Episodes cut
+// short by a step limit make the realized returns of their last steps too
+// low.
+func Calibrate(policy *Policy, env Environment, episodes, bins int, gamma, rewardScale float64) CalibrationReport {
+	var points []CalibrationPoint
+	for range episodes {
+		var predicted, rewards []float64
+		policy.Reset()
+		state := env.Reset()
+		done := false
+		for !done {
+			qValues := policy.step(state)
+			action := Argmax(qValues)
+			predicted = append(predicted, qValues[action])
+			var reward float64
+			state, reward, done = env.Step(action)
+			rewards = append(rewards, reward*rewardScale)
+		}
+		for i, realized := range DiscountedReturns(rewards, gamma) {
+			points = append(points, CalibrationPoint{Predicted: predicted[i], Realized: realized})
+		}
+	}
+	return NewCalibrationReport(points, bins)
+}
+
+// NewCalibrationReport computes error metrics and calibration bins from
+// pairs of predicted and realized values.
+func NewCalibrationReport(points []CalibrationPoint, bins int) CalibrationReport {
+	report := CalibrationReport{Points: points}
+	if len(points) == 0 {
+		return report
+	}
+
+	predicted := make([]float64, len(points))
+	realized := make([]float64, len(points))
+	var sq float64
+	for i, p := range points {
+		predicted[i], realized[i] = p.Predicted, p.Realized
+		diff := p.Predicted - p.Realized
+		report.MAE += math.Abs(diff)
+		report.Bias += diff
+		sq += diff * diff
+	}
+	n := float64(len(points))
+	report.MAE /= n
+	report.Bias /= n
+	report.RMSE = math.Sqrt(sq / n)
+	report.Correlation = stat.Correlation(predicted, realized, nil)
+
+	sorted := append([]CalibrationPoint(nil), points...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Predicted < sorted[j].Predicted })
+	bins = min(max(bins, 1), len(sorted))
+	for b := range bins {
+		group := sorted[b*len(sorted)/bins : (b+1)*len(sorted)/bins]
+		bin := CalibrationBin{Count: len(group)}
+		for _, p := range group {
+			bin.Predicted += p.Predicted
+			bin.Realized += p.Realized
+		}
+		bin.Predicted /= float64(len(group))
+		bin.Realized /= float64(len(group))
+		report.Bins = append(report.Bins, bin)
+	}
+	return report
+}
+
+// String formats the report as a human-readable summary.
+func (r CalibrationReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Points: %d\n", len(r.Points))
+	fmt.Fprintf(&b, "MAE: %.4f RMSE: %.4f Bias: %+.4f Correlation: %.3f\n", r.MAE, r.RMSE, r.Bias, r.Correlation)
+	for _, bin := range r.Bins {
+		fmt.Fprintf(&b, "predicted %8.3f realized %8.3f (%d)\n", bin.Predicted, bin.Realized, bin.Count)
+	}
+	return b.String()
+}
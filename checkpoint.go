@@ -0,0 +1,363 @@
+// checkpoint.go
+package dqn
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// activations maps activation names to functions so that networks can be saved and loaded.
+var activations = map[string]Activation{
+	"relu":    ReLU,
+	"sigmoid": Sigmoid,
+	"tanh":    Tanh,
+}
+
+// RegisterActivation registers a custom activation function under name so
+// that networks using it can be saved and loaded.
+func RegisterActivation(name string, activation Activation) {
+	activations[name] = activation
+}
+
+// activationName returns the registered name of an activation function
+func activationName(activation Activation) (string, error) {
+	ptr := reflect.ValueOf(activation).Pointer()
+	for name, fn := range activations {
+		if reflect.ValueOf(fn).Pointer() == ptr {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("dqn: activation function is not registered")
+}
+
+// networkState is the serialized form of a QNetwork.
+type networkState struct {
+	InputSize, HiddenSize, OutputSize int
+	W1, B1, W2, B2                    []float64
+	Activation                        string
+	Dropout, WeightDecay              float64
+	Initializer                       string
+}
+
+// Save writes the network weights and architecture to w.
+func (q *QNetwork) Save(w io.Writer) error {
+	state, err := q.state()
+	if err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(state)
+}
+
+// state returns the serialized form of the network.
+func (q *QNetwork) state() (networkState, error) {
+	name, err := activationName(q.activation)
+	if err != nil {
+		return networkState{}, err
+	}
+	return networkState{
+		InputSize:  q.inputSize,
+		HiddenSize: q.hiddenSize,
+		OutputSize: q.outputSize,
+		W1:         q.w1.RawMatrix().Data,
+		B1:         q.b1.RawVector().Data,
+		W2:         q.w2.RawMatrix().Data,
+		B2:         q.b2.RawVector().Data,
+		Activation: name,
+
+		Dropout:     q.dropout,
+		WeightDecay: q.weightDecay,
+		Initializer: q.initializer,
+	}, nil
+}
+
+// LoadQNetwork reads a network written by QNetwork.Save.
+func LoadQNetwork(r io.Reader) (*QNetwork, error) {
+	var state networkState
+	if err := gob.NewDecoder(r).Decode(&state); err != nil {
+		return nil, err
+	}
+	return networkFromState(state)
+}
+
+// networkFromState rebuilds a network from its serialized form.
+func networkFromState(state networkState) (*QNetwork, error) {
+	activation, ok := activations[state.Activation]
+	if !ok {
+		return nil, fmt.Errorf("dqn: unknown activation %q", state.Activation)
+	}
+	if len(state.W1) != state.HiddenSize*state.InputSize || len(state.B1) != state.HiddenSize ||
+		len(state.W2) != state.OutputSize*state.HiddenSize || len(state.B2) != state.OutputSize {
+		return nil, fmt.Errorf("dqn: network weights do not match architecture")
+	}
+	return &QNetwork{
+		inputSize:  state.InputSize,
+		hiddenSize: state.HiddenSize,
+		outputSize: state.OutputSize,
+		w1:         mat.NewDense(state.HiddenSize, state.InputSize, state.W1),
+		b1:         mat.NewVecDense(state.HiddenSize, state.B1),
+		w2:         mat.NewDense(state.OutputSize, state.HiddenSize, state.W2),
+		b2:         mat.NewVecDense(state.OutputSize, state.B2),
+		activation: activation,
+
+		initializer: state.Initializer,
+		dropout:     state.Dropout,
+		weightDecay: state.WeightDecay,
+	}, nil
+}
+
+// trainerState is the serialized form of a Trainer checkpoint.
+type trainerState struct {
+	Network []byte
+	Target  []byte
+	Updates int
+	Steps   int
+	Epsilon float64
+	RNG     []byte
+	// ReplayRNG is the state of the replay sampling stream
+	ReplayRNG []byte
+	// DropoutRNG is the state of the dropout mask stream
+	DropoutRNG []byte
+	// Optimizer holds the Adam moment estimates, if Adam is used
+	Optimizer []byte
+	History   []EpisodeStats
+	// Pipeline transforms raw states into network inputs, if one is configured
+	Pipeline *Pipeline
+	// ActionLimits restrict the actions of the checkpointed policy
+	ActionLimits []ActionLimit
+	// Replay, Exploration and Curiosity hold the state of the replay buffer,
+	// the exploration strategy and the RND module, if they can be encoded
+	Replay      []byte
+	Exploration []byte
+	Curiosity   []byte
+
+	// Early stopping state; Best holds the best policy, if evaluations have run
+	Best           []byte
+	BestScore      float64
+	Evaluations    []EvalResult
+	SinceBest      int
+	AboveThreshold int
+}
+
+// writeCheckpoint encodes the state of the agent and the episode history to w.
+func (t *Trainer) writeCheckpoint(w io.Writer) error {
+	d := t.agent
+	state := trainerState{
+		Updates: d.updates,
+		Steps:   d.steps,
+		Epsilon: d.epsilon,
+		History: t.history,
+
+		Pipeline:     t.cfg.Pipeline,
+		ActionLimits: t.cfg.ActionLimits,
+
+		BestScore:      t.bestScore,
+		Evaluations:    t.evaluations,
+		SinceBest:      t.sinceBest,
+		AboveThreshold: t.aboveThreshold,
+	}
+
+	var network bytes.Buffer
+	if err := d.qNetwork.Save(&network); err != nil {
+		return err
+	}
+	state.Network = network.Bytes()
+	if d.targetNetwork != nil {
+		var target bytes.Buffer
+		if err := d.targetNetwork.Save(&target); err != nil {
+			return err
+		}
+		state.Target = target.Bytes()
+	}
+	rng, err := d.rngSource.pcg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	state.RNG = rng
+	if state.ReplayRNG, err = d.replaySource.pcg.MarshalBinary(); err != nil {
+		return err
+	}
+	if state.DropoutRNG, err = d.dropoutSource.pcg.MarshalBinary(); err != nil {
+		return err
+	}
+	if d.optimizer != nil {
+		if state.Optimizer, err = d.optimizer.MarshalBinary(); err != nil {
+			return err
+		}
+	}
+	if m, ok := d.replayBuffer.(encoding.BinaryMarshaler); ok {
+		if state.Replay, err = m.MarshalBinary(); err != nil {
+			return err
+		}
+	}
+	if m, ok := d.exploration.(encoding.BinaryMarshaler); ok {
+		if state.Exploration, err = m.MarshalBinary(); err != nil {
+			return err
+		}
+	}
+	if t.cfg.Curiosity != nil {
+		if state.Curiosity, err = t.cfg.Curiosity.MarshalBinary(); err != nil {
+			return err
+		}
+	}
+	if t.best != nil {
+		var best bytes.Buffer
+		if err := t.best.Save(&best); err != nil {
+			return err
+		}
+		state.Best = best.Bytes()
+	}
+	return gob.NewEncoder(w).Encode(state)
+}
+
+// saveCheckpoint writes a rolling checkpoint, with a checksum, to the
+// checkpoint directory and removes the oldest ones beyond the configured
+// number to keep. The training environments are then reseeded as Resume
+// reseeds them.
+func (t *Trainer) saveCheckpoint() error {
+	if err := os.MkdirAll(t.cfg.CheckpointDir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(t.cfg.CheckpointDir, fmt.Sprintf("checkpoint-%08d.gob", len(t.history)))
+	if err := writeChecked(path, t.writeCheckpoint); err != nil {
+		return err
+	}
+	t.seedCheckpointEnvs()
+
+	if t.cfg.CheckpointKeep <= 0 {
+		return nil
+	}
+	paths, err := checkpointPaths(t.cfg.CheckpointDir)
+	if err != nil {
+		return err
+	}
+	for len(paths) > t.cfg.CheckpointKeep {
+		if err := os.Remove(paths[0]); err != nil {
+			return err
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
+
+// seedCheckpointEnvs reseeds the training environments from the environment
+// stream and the number of episodes run. Both saveCheckpoint and Resume call
+// it, so that a resumed run faces the same episodes as the uninterrupted
+// one even though the environments' random state is not saved.
+func (t *Trainer) seedCheckpointEnvs() {
+	seed := t.cfg.subsystemSeed(t.cfg.EnvSeed, envStream)
+	if seed == 0 {
+		return
+	}
+	seed = Config{Seed: seed}.subsystemSeed(0, uint64(len(t.history)))
+	if t.vec != nil {
+		for i, env := range t.vec.envs {
+			seedEnv(env, seed+int64(i))
+		}
+		return
+	}
+	seedEnv(t.rawEnv(), seed)
+}
+
+// Resume restores the agent, its replay buffer, exploration and curiosity
+// state, the pipeline, the episode history and the early stopping state from
+// the most recent checkpoint in dir, and reseeds the environments, so that
+// Run continues where the checkpointed run stopped. Checkpoints are written
+// between episodes, when no n-step transitions, action limit windows or
+// eligibility traces are pending, except with a VecEnv, whose unfinished
+// episodes are restarted. Custom replay buffers and explorations are
+// restored if they implement encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler.
+func (t *Trainer) Resume(dir string) error {
+	paths, err := checkpointPaths(dir)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("dqn: no checkpoint found in %s", dir)
+	}
+
+	state, err := readCheckpoint(paths[len(paths)-1])
+	if err != nil {
+		return err
+	}
+
+	d := t.agent
+	network, err := LoadQNetwork(bytes.NewReader(state.Network))
+	if err != nil {
+		return err
+	}
+	d.qNetwork = network
+	if state.Target != nil {
+		if d.targetNetwork, err = LoadQNetwork(bytes.NewReader(state.Target)); err != nil {
+			return err
+		}
+	}
+	if err := d.rngSource.pcg.UnmarshalBinary(state.RNG); err != nil {
+		return err
+	}
+	if state.ReplayRNG != nil {
+		if err := d.replaySource.pcg.UnmarshalBinary(state.ReplayRNG); err != nil {
+			return err
+		}
+	}
+	if state.DropoutRNG != nil {
+		if err := d.dropoutSource.pcg.UnmarshalBinary(state.DropoutRNG); err != nil {
+			return err
+		}
+	}
+	if d.optimizer != nil && state.Optimizer != nil {
+		if err := d.optimizer.UnmarshalBinary(state.Optimizer); err != nil {
+			return err
+		}
+	}
+	if u, ok := d.replayBuffer.(encoding.BinaryUnmarshaler); ok && state.Replay != nil {
+		if err := u.UnmarshalBinary(state.Replay); err != nil {
+			return err
+		}
+	}
+	if u, ok := d.exploration.(encoding.BinaryUnmarshaler); ok && state.Exploration != nil {
+		if err := u.UnmarshalBinary(state.Exploration); err != nil {
+			return err
+		}
+	}
+	if t.cfg.Curiosity != nil && state.Curiosity != nil {
+		if err := t.cfg.Curiosity.UnmarshalBinary(state.Curiosity); err != nil {
+			return err
+		}
+	}
+	if state.Best != nil {
+		if t.best, err = LoadPolicy(bytes.NewReader(state.Best)); err != nil {
+			return err
+		}
+	}
+	if state.Pipeline != nil {
+		t.setPipeline(state.Pipeline)
+	}
+	d.updates = state.Updates
+	d.steps = state.Steps
+	d.epsilon = state.Epsilon
+	t.history = state.History
+	t.next = len(t.history)
+	t.bestScore = state.BestScore
+	t.evaluations = state.Evaluations
+	t.sinceBest = state.SinceBest
+	t.aboveThreshold = state.AboveThreshold
+	t.seedCheckpointEnvs()
+	return nil
+}
+
+// checkpointPaths returns the checkpoints in dir, oldest first.
+func checkpointPaths(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "checkpoint-*.gob"))
+	sort.Strings(paths)
+	return paths, err
+}
@@ -0,0 +1,98 @@
+// compare.go
+package dqn
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// BootstrapSamples is the number of resamples used by Compare for the
+// confidence interval of the difference in means.
+var BootstrapSamples = 10000
+
+// Comparison reports whether agent A performs significantly better than agent B.
+type Comparison struct {
+	MeanA, MeanB float64
+	Difference   float64 // MeanA - MeanB
+	TStatistic   float64
+	DF           float64 // Welch-Satterthwaite degrees of freedom
+	PValue       float64 // two-sided Welch's t-test
+	Confidence   float64
+	CILow        float64 // bootstrap confidence interval of Difference
+	CIHigh       float64
+	Significant  bool // A beats B at the given confidence level
+}
+
+// Compare compares per-seed scores of two agents with Welch's t-test and a
+// bootstrap confidence interval of the difference in means.
+func Compare(a, b []float64, confidence float64) Comparison {
+	return CompareSeeded(a, b, confidence, 0)
+}
+
+// CompareSeeded is like Compare with the bootstrap resamples drawn from
+// seed, so that the confidence interval is reproducible. Zero picks a random
+// seed; Config.BootstrapSeed derives one from an agent's seed.
+func CompareSeeded(a, b []float64, confidence float64, seed int64) Comparison {
+	if len(a) < 2 || len(b) < 2 {
+		panic("Compare needs at least two scores per agent")
+	}
+
+	meanA, varA := stat.MeanVariance(a, nil)
+	meanB, varB := stat.MeanVariance(b, nil)
+	seA := varA / float64(len(a))
+	seB := varB / float64(len(b))
+
+	c := Comparison{
+		MeanA:      meanA,
+		MeanB:      meanB,
+		Difference: meanA - meanB,
+		Confidence: confidence,
+		PValue:     1,
+	}
+
+	if se := seA + seB; se > 0 {
+		c.TStatistic = c.Difference / math.Sqrt(se)
+		c.DF = se * se / (seA*seA/float64(len(a)-1) + seB*seB/float64(len(b)-1))
+		t := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: c.DF}
+		c.PValue = 2 * t.CDF(-math.Abs(c.TStatistic))
+	} else if c.Difference != 0 {
+		c.PValue = 0
+	}
+
+	rng := rand.New(newPCGSource(seed))
+	diffs := make([]float64, BootstrapSamples)
+	for i := range diffs {
+		diffs[i] = resampleMean(rng, a) - resampleMean(rng, b)
+	}
+	sort.Float64s(diffs)
+	tail := (1 - confidence) / 2
+	c.CILow = stat.Quantile(tail, stat.Empirical, diffs, nil)
+	c.CIHigh = stat.Quantile(1-tail, stat.Empirical, diffs, nil)
+
+	c.Significant = c.Difference > 0 && c.PValue < 1-confidence && c.CILow > 0
+	return c
+}
+
+// resampleMean returns the mean of a bootstrap resample of x
+func resampleMean(rng *rand.Rand, x []float64) float64 {
+	var sum float64
+	for range x {
+		sum += x[rng.Intn(len(x))]
+	}
+	return sum / float64(len(x))
+}
+
+// String formats the comparison for printing.
+func (c Comparison) String() string {
+	verdict := "not significant"
+	if c.Significant {
+		verdict = "A significantly better"
+	}
+	return fmt.Sprintf("mean A %.2f, mean B %.2f, diff %.2f, %.0f%% CI [%.2f, %.2f], t %.2f, p %.4f: %s",
+		c.MeanA, c.MeanB, c.Difference, c.Confidence*100, c.CILow, c.CIHigh, c.TStatistic, c.PValue, verdict)
+}
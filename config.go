@@ -0,0 +1,316 @@
+// config.go
+package dqn
+
+import "math"
+
+// Config holds the hyperparameters of a DQN agent.
+type Config struct {
+	StateSize    int
+	NumActions   int
+	HiddenSize   int
+	BufferSize   int
+	Gamma        float64
+	Epsilon      float64
+	LearningRate float64
+	// TargetSyncInterval is the number of updates between target network
+	// syncs. Zero disables the target network.
+	TargetSyncInterval int
+	Activation         Activation `json:"-"`
+	// Initializer is the registered name of the weight initializer, "xavier"
+	// if empty.
+	Initializer string
+	// RewardScale multiplies environment rewards before they are rounded to
+	// the integer rewards expected by Train.
+	RewardScale float64
+	// WeightDecay is the L2 penalty applied to the weights in every update.
+	WeightDecay float64
+	// Dropout is the probability of dropping each hidden unit during training.
+	Dropout float64
+	// DeltaEncoding stores next states in the replay buffer as the values
+	// that differ from their states, see NewDeltaReplayBuffer.
+	DeltaEncoding bool
+	// ReplayBuffer is the buffer Observe stores experiences in and samples
+	// batches from, e.g. one made by NewPrioritizedReplayBuffer. If nil, a
+	// ReplayBuffer of BufferSize experiences is used. A ReplayBuffer samples
+	// from the replay stream.
+	ReplayBuffer Buffer `json:"-"`
+	// SubsampleEvery and SubsampleMinChange make a ReplayBuffer keep only
+	// some of the transitions offered to it, see ReplayBuffer.SetSubsampling.
+	SubsampleEvery     int
+	SubsampleMinChange float64
+	// Lambda enables Watkins's Q(λ) with eligibility traces over the current
+	// episode when greater than zero. It cannot be combined with a BatchSize,
+	// or with TrainEvery or GradientStepsPerUpdate above one.
+	Lambda float64
+	// NSteps makes the Trainer and MultiAgentTrainer store n-step
+	// experiences, which the agent bootstraps with gamma^NSteps. Zero or one
+	// trains on one-step targets. FitOffline only supports one-step targets.
+	NSteps int
+	// Update schedule used by DQN.Observe and the Trainer: BatchSize is the
+	// number of replayed experiences per update, zero to train on each
+	// transition as it arrives. No updates are made during the first
+	// WarmupSteps transitions, and afterwards GradientStepsPerUpdate updates
+	// are made every TrainEvery transitions. Zero intervals and counts mean one.
+	BatchSize              int
+	WarmupSteps            int
+	TrainEvery             int
+	GradientStepsPerUpdate int
+	// Adam replaces plain SGD with the Adam optimizer. With SyncReset, its
+	// moment estimates are multiplied by SyncDamping on every target network
+	// sync, zero resetting them, to avoid loss spikes after the sync.
+	Adam        bool
+	SyncReset   bool
+	SyncDamping float64
+	// Seed seeds the exploration random source. Zero picks a random seed.
+	Seed int64
+	// InitSeed, ReplaySeed and EnvSeed seed separate random streams for weight
+	// initialization, replay sampling and the environments, so that changes
+	// to one subsystem do not shift the randomness of the others. Zero
+	// derives the seed from Seed, or from the random seed picked when Seed is
+	// zero too.
+	InitSeed   int64
+	ReplaySeed int64
+	EnvSeed    int64
+	// Pipeline transforms raw observations into network inputs. The Trainer
+	// applies it to a single environment and attaches it to saved policies.
+	Pipeline *Pipeline
+	// TrainParams and EvalParams set the parameters of a Configurable
+	// environment at the start of every training and evaluation episode,
+	// e.g. noise on for training and off for evaluation. The Trainer applies
+	// them to a single environment.
+	TrainParams EnvParams
+	EvalParams  EnvParams
+	// Curiosity adds an RND exploration bonus to the rewards the Trainer
+	// trains on. Episode returns still report the environment reward only.
+	Curiosity *RND `json:"-"`
+	// Exploration selects the Trainer's actions instead of epsilon-greedy
+	// acting with Epsilon.
+	Exploration Exploration `json:"-"`
+	// Profile times the phases of every update, see DQN.Profile.
+	Profile bool
+	// ActionLimits restrict how often actions may be chosen, e.g. to respect
+	// equipment duty cycles. The Trainer masks limited actions while acting
+	// and in the targets of the following states.
+	ActionLimits []ActionLimit
+
+	// Training settings used by Trainer and Solve
+	Episodes       int
+	EvalEpisodes   int
+	CheckpointPath string
+	// CheckpointDir enables rolling checkpoints written every CheckpointEvery
+	// episodes, keeping the CheckpointKeep most recent ones.
+	CheckpointDir   string
+	CheckpointEvery int
+	CheckpointKeep  int
+	// EvalEvery enables an evaluation over EvalEpisodes every EvalEvery
+	// training episodes. Training stops once the mean evaluation return is at
+	// least StopThreshold for StopConsecutive evaluations in a row, or when
+	// it has not improved for Patience evaluations. Zero disables a criterion.
+	EvalEvery       int
+	StopThreshold   float64
+	StopConsecutive int
+	Patience        int
+}
+
+// Option configures a Config.
+type Option func(*Config)
+
+// WithHiddenSize sets the size of the hidden layer.
+func WithHiddenSize(n int) Option { return func(c *Config) { c.HiddenSize = n } }
+
+// WithBufferSize sets the replay buffer capacity.
+func WithBufferSize(n int) Option { return func(c *Config) { c.BufferSize = n } }
+
+// WithDeltaEncoding makes the replay buffer store next states as deltas from their states.
+func WithDeltaEncoding() Option { return func(c *Config) { c.DeltaEncoding = true } }
+
+// WithReplayBuffer sets the buffer the agent replays experiences from.
+func WithReplayBuffer(b Buffer) Option { return func(c *Config) { c.ReplayBuffer = b } }
+
+// WithSubsampling makes the replay buffer keep only every k-th transition and
+// skip transitions whose state changes by less than minChange.
+func WithSubsampling(k int, minChange float64) Option {
+	return func(c *Config) { c.SubsampleEvery, c.SubsampleMinChange = k, minChange }
+}
+
+// WithGamma sets the discount factor.
+func WithGamma(gamma float64) Option { return func(c *Config) { c.Gamma = gamma } }
+
+// WithEpsilon sets the exploration rate.
+func WithEpsilon(epsilon float64) Option { return func(c *Config) { c.Epsilon = epsilon } }
+
+// WithLearningRate sets the learning rate.
+func WithLearningRate(lr float64) Option { return func(c *Config) { c.LearningRate = lr } }
+
+// WithTargetSyncInterval sets the number of updates between target network syncs.
+func WithTargetSyncInterval(n int) Option { return func(c *Config) { c.TargetSyncInterval = n } }
+
+// WithInitializer sets the weight initializer by its registered name.
+func WithInitializer(name string) Option { return func(c *Config) { c.Initializer = name } }
+
+// WithActivation sets the hidden layer activation function.
+func WithActivation(activation Activation) Option {
+	return func(c *Config) { c.Activation = activation }
+}
+
+// WithRewardScale sets the factor applied to rewards before rounding.
+func WithRewardScale(scale float64) Option { return func(c *Config) { c.RewardScale = scale } }
+
+// WithEpisodes sets the number of training episodes.
+func WithEpisodes(n int) Option { return func(c *Config) { c.Episodes = n } }
+
+// WithEvalEpisodes sets the number of evaluation episodes.
+func WithEvalEpisodes(n int) Option { return func(c *Config) { c.EvalEpisodes = n } }
+
+// WithWeightDecay sets the L2 penalty applied to the weights in every update.
+func WithWeightDecay(lambda float64) Option { return func(c *Config) { c.WeightDecay = lambda } }
+
+// WithDropout sets the dropout probability of the hidden layer during training.
+func WithDropout(p float64) Option { return func(c *Config) { c.Dropout = p } }
+
+// WithLambda enables Q(λ) updates with the given trace decay.
+func WithLambda(lambda float64) Option { return func(c *Config) { c.Lambda = lambda } }
+
+// WithNSteps trains on n-step returns.
+func WithNSteps(n int) Option { return func(c *Config) { c.NSteps = n } }
+
+// WithBatchSize sets the number of replayed experiences per update.
+func WithBatchSize(n int) Option { return func(c *Config) { c.BatchSize = n } }
+
+// WithWarmupSteps sets the number of transitions collected before learning starts.
+func WithWarmupSteps(n int) Option { return func(c *Config) { c.WarmupSteps = n } }
+
+// WithTrainEvery makes the agent update every n transitions.
+func WithTrainEvery(n int) Option { return func(c *Config) { c.TrainEvery = n } }
+
+// WithGradientStepsPerUpdate sets the number of gradient steps made per update.
+func WithGradientStepsPerUpdate(n int) Option {
+	return func(c *Config) { c.GradientStepsPerUpdate = n }
+}
+
+// WithAdam makes the agent train with the Adam optimizer.
+func WithAdam() Option { return func(c *Config) { c.Adam = true } }
+
+// WithOptimizerSyncReset multiplies the optimizer moments by damping on
+// every target network sync. Zero damping resets the optimizer.
+func WithOptimizerSyncReset(damping float64) Option {
+	return func(c *Config) {
+		c.SyncReset = true
+		c.SyncDamping = damping
+	}
+}
+
+// WithSeed seeds the exploration random source.
+func WithSeed(seed int64) Option { return func(c *Config) { c.Seed = seed } }
+
+// WithSubsystemSeeds seeds weight initialization, replay sampling and the
+// environments independently of each other and of exploration.
+func WithSubsystemSeeds(init, replay, env int64) Option {
+	return func(c *Config) {
+		c.InitSeed = init
+		c.ReplaySeed = replay
+		c.EnvSeed = env
+	}
+}
+
+// WithPipeline sets the feature pipeline applied to observations.
+func WithPipeline(p *Pipeline) Option { return func(c *Config) { c.Pipeline = p } }
+
+// WithTrainParams sets the environment parameters used for training episodes.
+func WithTrainParams(p EnvParams) Option { return func(c *Config) { c.TrainParams = p } }
+
+// WithEvalParams sets the environment parameters used for evaluation episodes.
+func WithEvalParams(p EnvParams) Option { return func(c *Config) { c.EvalParams = p } }
+
+// WithExploration sets the exploration strategy used while training.
+func WithExploration(e Exploration) Option { return func(c *Config) { c.Exploration = e } }
+
+// WithProfiling enables the per-phase timing of updates reported by DQN.Profile.
+func WithProfiling() Option { return func(c *Config) { c.Profile = true } }
+
+// WithCuriosity adds the exploration bonus of r to training rewards and sets
+// the reward scale. Rewards are rounded to integers after scaling, so the
+// scale must be large enough for bonuses to survive, e.g. 100 keeps bonuses
+// down to 0.005: a bonus below half a unit of 1/rewardScale is lost.
+func WithCuriosity(r *RND, rewardScale float64) Option {
+	return func(c *Config) {
+		c.Curiosity = r
+		c.RewardScale = rewardScale
+	}
+}
+
+// WithActionLimit allows action to be chosen at most max times in any window
+// of consecutive steps. It can be given several times.
+func WithActionLimit(action, max, window int) Option {
+	return func(c *Config) {
+		c.ActionLimits = append(c.ActionLimits, ActionLimit{Action: action, Max: max, Window: window})
+	}
+}
+
+// WithCheckpointing makes the Trainer write a checkpoint to dir every n
+// episodes, keeping only the keep most recent ones.
+func WithCheckpointing(dir string, n, keep int) Option {
+	return func(c *Config) {
+		c.CheckpointDir = dir
+		c.CheckpointEvery = n
+		c.CheckpointKeep = keep
+	}
+}
+
+// WithEvalEvery evaluates the policy every n training episodes.
+func WithEvalEvery(n int) Option { return func(c *Config) { c.EvalEvery = n } }
+
+// WithStopThreshold stops training once the mean evaluation return reaches
+// threshold for k consecutive evaluations.
+func WithStopThreshold(threshold float64, k int) Option {
+	return func(c *Config) {
+		c.StopThreshold = threshold
+		c.StopConsecutive = k
+	}
+}
+
+// WithPatience stops training when the mean evaluation return has not
+// improved for n evaluations.
+func WithPatience(n int) Option { return func(c *Config) { c.Patience = n } }
+
+// WithCheckpointPath sets the file the trained policy is saved to.
+func WithCheckpointPath(path string) Option { return func(c *Config) { c.CheckpointPath = path } }
+
+// DefaultConfig picks hyperparameters from the state and action dimensions.
+// Small problems get settings close to the common CartPole baselines and
+// larger problems move towards the settings of the original DQN paper.
+func DefaultConfig(stateSize, numActions int) Config {
+	// Hidden layer: a few units per input and output, rounded up to a power of two
+	hiddenSize := nextPowerOfTwo(8 * (stateSize + numActions))
+	hiddenSize = max(64, min(hiddenSize, 512))
+
+	bufferSize := max(10000, min(1000*stateSize*numActions, 1000000))
+
+	learningRate := 1e-3
+	switch {
+	case hiddenSize > 128:
+		learningRate = 2.5e-4
+	case hiddenSize > 64:
+		learningRate = 5e-4
+	}
+
+	return Config{
+		StateSize:          stateSize,
+		NumActions:         numActions,
+		HiddenSize:         hiddenSize,
+		BufferSize:         bufferSize,
+		Gamma:              0.99,
+		Epsilon:            0.1,
+		LearningRate:       learningRate,
+		TargetSyncInterval: max(500, min(bufferSize/20, 10000)),
+		Activation:         ReLU,
+		RewardScale:        1,
+		Episodes:           500,
+		EvalEpisodes:       100,
+	}
+}
+
+// nextPowerOfTwo returns the smallest power of two greater than or equal to n
+func nextPowerOfTwo(n int) int {
+	return int(math.Pow(2, math.Ceil(math.Log2(float64(max(1, n))))))
+}
@@ -0,0 +1,79 @@
+// controller.go
+package dqn
+
+import (
+	"context"
+	"time"
+)
+
+// ControlStats summarizes the timing of a Controller.
+type ControlStats struct {
+	Decisions int // times the policy was queried
+	Holds     int // calls that repeated the previous action
+	// Jitter is the deviation of the interval between decisions from the period.
+	MeanJitter time.Duration
+	MaxJitter  time.Duration
+}
+
+// Controller runs a policy at a fixed control frequency, holding the
+// previous action between decisions, for use in soft real-time loops.
+type Controller struct {
+	act    func(state []float64) int
+	period time.Duration
+	now    func() time.Time
+
+	action      int
+	last        time.Time
+	decided     bool
+	totalJitter time.Duration
+	stats       ControlStats
+}
+
+// NewController initializes a new Controller that queries act, e.g.
+// Policy.Act or DelayedActor.Act, at most once per period.
+func NewController(act func(state []float64) int, period time.Duration) *Controller {
+	return &Controller{act: act, period: period, now: time.Now}
+}
+
+// Act returns a new action from the policy if a period has passed since the
+// last decision, and the previous action otherwise.
+func (c *Controller) Act(state []float64) int {
+	now := c.now()
+	if c.decided && now.Sub(c.last) < c.period {
+		c.stats.Holds++
+		return c.action
+	}
+	if c.decided {
+		jitter := now.Sub(c.last) - c.period
+		c.totalJitter += jitter
+		c.stats.MaxJitter = max(c.stats.MaxJitter, jitter)
+	}
+	c.action = c.act(state)
+	c.last = now
+	c.decided = true
+	c.stats.Decisions++
+	if c.stats.Decisions > 1 {
+		c.stats.MeanJitter = c.totalJitter / time.Duration(c.stats.Decisions-1)
+	}
+	return c.action
+}
+
+// Run drives a control loop until ctx is cancelled: every period it reads
+// the state with sense and applies the chosen action with actuate.
+func (c *Controller) Run(ctx context.Context, sense func() []float64, actuate func(action int)) error {
+	ticker := time.NewTicker(c.period)
+	defer ticker.Stop()
+	for {
+		actuate(c.Act(sense()))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stats returns the timing statistics so far.
+func (c *Controller) Stats() ControlStats {
+	return c.stats
+}
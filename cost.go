@@ -0,0 +1,77 @@
+// cost.go
+package dqn
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// CostEstimate projects the cost of a training run from a calibration run.
+type CostEstimate struct {
+	CalibrationEpisodes int
+	CalibrationSteps    int
+	StepsPerSecond      float64
+	StepsPerEpisode     float64
+	// Projections for the remaining episodes of the training budget
+	RemainingEpisodes int
+	ProjectedSteps    float64
+	ProjectedDuration time.Duration
+	// CPUHours assumes all GOMAXPROCS cores are busy, an upper bound.
+	CPUHours  float64
+	EnergyKWh float64
+}
+
+// EstimateCost trains for the given number of calibration episodes, which
+// count towards the episode budget, measures the throughput and projects
+// the wall-clock time, CPU-hours and energy of the remaining episodes.
+// watts is the power drawn by the machine while training, e.g. its TDP.
+func (t *Trainer) EstimateCost(ctx context.Context, episodes int, watts float64) (CostEstimate, error) {
+	startEpisodes := len(t.history)
+	start := time.Now()
+	var err error
+	if t.vec != nil {
+		err = t.runVec(ctx, startEpisodes+episodes)
+	} else {
+		for len(t.history) < startEpisodes+episodes && err == nil {
+			var stats EpisodeStats
+			if stats, err = t.runEpisode(ctx, len(t.history)); err == nil {
+				err = t.record(stats)
+			}
+		}
+	}
+	elapsed := time.Since(start)
+	if err != nil {
+		return CostEstimate{}, err
+	}
+
+	e := CostEstimate{CalibrationEpisodes: len(t.history) - startEpisodes}
+	for _, stats := range t.history[startEpisodes:] {
+		e.CalibrationSteps += stats.Steps
+	}
+	if e.CalibrationEpisodes == 0 || elapsed <= 0 {
+		return e, fmt.Errorf("dqn: calibration run too short to estimate cost")
+	}
+	e.StepsPerSecond = float64(e.CalibrationSteps) / elapsed.Seconds()
+	e.StepsPerEpisode = float64(e.CalibrationSteps) / float64(e.CalibrationEpisodes)
+
+	e.RemainingEpisodes = max(0, t.cfg.Episodes-len(t.history))
+	e.ProjectedSteps = e.StepsPerEpisode * float64(e.RemainingEpisodes)
+	seconds := e.ProjectedSteps / e.StepsPerSecond
+	e.ProjectedDuration = time.Duration(seconds * float64(time.Second))
+	hours := seconds / 3600
+	e.CPUHours = hours * float64(runtime.GOMAXPROCS(0))
+	e.EnergyKWh = hours * watts / 1000
+	return e, nil
+}
+
+// String formats the estimate as a short report.
+func (e CostEstimate) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Calibration: %d episodes, %d steps, %.1f steps/sec\n", e.CalibrationEpisodes, e.CalibrationSteps, e.StepsPerSecond)
+	fmt.Fprintf(&b, "Remaining: %d episodes, ~%.0f steps\n", e.RemainingEpisodes, e.ProjectedSteps)
+	fmt.Fprintf(&b, "Projected: %s wall-clock, %.2f CPU-hours, %.3f kWh\n", e.ProjectedDuration.Round(time.Second), e.CPUHours, e.EnergyKWh)
+	return b.String()
+}
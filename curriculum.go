@@ -0,0 +1,87 @@
+// curriculum.go
+package dqn
+
+import (
+	"math"
+	"math/rand"
+)
+
+// TaskSampler picks training tasks in proportion to their recent learning
+// progress, the absolute change in mean return between the older and the
+// newer half of a window of recent episodes. Tasks whose return is still
+// changing are sampled more, while solved or hopeless tasks fade out.
+type TaskSampler struct {
+	returns [][]float64
+	window  int
+	// explore is the probability of sampling a task uniformly, so that
+	// every task keeps being measured.
+	explore float64
+	rng     *rand.Rand
+}
+
+// NewTaskSampler initializes a new TaskSampler over numTasks tasks that
+// measures progress over the last window episodes of each task. Zero seed
+// picks a random seed.
+func NewTaskSampler(numTasks, window int, explore float64, seed int64) *TaskSampler {
+	return &TaskSampler{
+		returns: make([][]float64, numTasks),
+		window:  window,
+		explore: explore,
+		rng:     rand.New(newPCGSource(seed)),
+	}
+}
+
+// Record records the return of an episode of task.
+func (s *TaskSampler) Record(task int, ret float64) {
+	s.returns[task] = append(s.returns[task], ret)
+	if len(s.returns[task]) > s.window {
+		s.returns[task] = s.returns[task][1:]
+	}
+}
+
+// Progress returns the learning progress of task. Tasks with fewer than two
+// recorded episodes have infinite progress so that they are tried first.
+func (s *TaskSampler) Progress(task int) float64 {
+	returns := s.returns[task]
+	if len(returns) < 2 {
+		return math.Inf(1)
+	}
+	half := len(returns) / 2
+	var older, newer float64
+	for _, r := range returns[:half] {
+		older += r
+	}
+	for _, r := range returns[half:] {
+		newer += r
+	}
+	return math.Abs(newer/float64(len(returns)-half) - older/float64(half))
+}
+
+// Sample returns the next task to train on.
+func (s *TaskSampler) Sample() int {
+	if s.rng.Float64() < s.explore {
+		return s.rng.Intn(len(s.returns))
+	}
+
+	progress := make([]float64, len(s.returns))
+	var total float64
+	for task := range progress {
+		progress[task] = s.Progress(task)
+		if math.IsInf(progress[task], 1) {
+			return task
+		}
+		total += progress[task]
+	}
+	if total == 0 {
+		return s.rng.Intn(len(s.returns))
+	}
+
+	r := s.rng.Float64() * total
+	for task, p := range progress {
+		r -= p
+		if r < 0 {
+			return task
+		}
+	}
+	return len(progress) - 1
+}
@@ -0,0 +1,96 @@
+// dashboard.go
+package dqn
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// sparkTicks are the characters used to draw sparklines, from low to high.
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// Dashboard renders a live terminal view of training progress using ANSI
+// escape codes: a reward sparkline, loss, epsilon, steps/sec and buffer fill.
+type Dashboard struct {
+	out      io.Writer
+	Width    int           // number of recent episodes shown in sparklines
+	Interval time.Duration // minimum time between redraws
+	returns  []float64
+	losses   []float64
+	steps    int
+	start    time.Time
+	drawn    time.Time
+}
+
+// NewDashboard initializes a new Dashboard writing to out.
+func NewDashboard(out io.Writer) *Dashboard {
+	return &Dashboard{out: out, Width: 60, Interval: 200 * time.Millisecond, start: time.Now()}
+}
+
+// Attach registers the dashboard as a callback of the trainer.
+func (db *Dashboard) Attach(t *Trainer) {
+	t.AddCallback(db.Update)
+}
+
+// Update records a finished episode and redraws the dashboard if the refresh interval has passed.
+func (db *Dashboard) Update(t *Trainer, stats EpisodeStats) {
+	db.returns = appendWindow(db.returns, stats.Return, db.Width)
+	db.losses = appendWindow(db.losses, stats.Loss, db.Width)
+	db.steps += stats.Steps
+
+	now := time.Now()
+	if now.Sub(db.drawn) < db.Interval {
+		return
+	}
+	db.drawn = now
+
+	buffer := t.agent.replayBuffer
+	fill := 0.0
+	if buffer.Cap() > 0 {
+		fill = float64(buffer.Len()) / float64(buffer.Cap())
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&b, "Episode %d/%d\n\n", stats.Episode+1, t.cfg.Episodes)
+	fmt.Fprintf(&b, "Reward  %s  last %.2f\n", Sparkline(db.returns), stats.Return)
+	fmt.Fprintf(&b, "Loss    %s  last %.4f\n\n", Sparkline(db.losses), stats.Loss)
+	fmt.Fprintf(&b, "Epsilon   %.3f\n", stats.Epsilon)
+	fmt.Fprintf(&b, "Steps/sec %.0f\n", float64(db.steps)/now.Sub(db.start).Seconds())
+	fmt.Fprintf(&b, "Buffer    %d/%d (%.0f%%)\n", buffer.Len(), buffer.Cap(), fill*100)
+	io.WriteString(db.out, b.String())
+}
+
+// appendWindow appends val to values, keeping at most n values
+func appendWindow(values []float64, val float64, n int) []float64 {
+	values = append(values, val)
+	if len(values) > n {
+		values = values[len(values)-n:]
+	}
+	return values
+}
+
+// Sparkline draws values as a line of block characters scaled between their minimum and maximum.
+func Sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := values[0], values[0]
+	for _, val := range values {
+		lo = math.Min(lo, val)
+		hi = math.Max(hi, val)
+	}
+
+	line := make([]rune, len(values))
+	for i, val := range values {
+		idx := 0
+		if hi > lo {
+			idx = int((val - lo) / (hi - lo) * float64(len(sparkTicks)-1))
+		}
+		line[i] = sparkTicks[idx]
+	}
+	return string(line)
+}
@@ -0,0 +1,177 @@
+// dataset.go
+package dqn
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// CSVMapping describes how the columns of a historical log map to experiences.
+//
+// Each row holds the state observed, the action taken and the reward that
+// followed. The next state of a row is the state of the following row in the
+// same episode; the last row of an episode is marked done.
+type CSVMapping struct {
+	StateColumns []string
+	ActionColumn string
+	// RewardColumn names the reward column. It is ignored if RewardFunc is set.
+	RewardColumn string
+	// RewardFunc computes the reward from the numeric values of a row, keyed by column name.
+	RewardFunc func(row map[string]float64) float64
+	// RewardScale multiplies rewards before they are rounded to integers. Zero means 1.
+	RewardScale float64
+	// EpisodeColumn starts a new episode whenever its value changes.
+	EpisodeColumn string
+	// DoneColumn marks the last row of an episode with a non-zero value.
+	DoneColumn string
+}
+
+// csvRow is a parsed row of a historical log.
+type csvRow struct {
+	state   []float64
+	action  int
+	reward  int
+	episode string
+	done    bool
+}
+
+// ReadExperiencesCSV converts a CSV log with a header row into experiences.
+func ReadExperiencesCSV(r io.Reader, m CSVMapping) ([]Experience, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range append([]string{m.ActionColumn, m.RewardColumn, m.EpisodeColumn, m.DoneColumn}, m.StateColumns...) {
+		if _, ok := columns[name]; name != "" && !ok {
+			return nil, fmt.Errorf("dqn: column %q not found", name)
+		}
+	}
+	if m.RewardFunc == nil && m.RewardColumn == "" {
+		return nil, fmt.Errorf("dqn: no reward column or function")
+	}
+	scale := m.RewardScale
+	if scale == 0 {
+		scale = 1
+	}
+
+	var rows []csvRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[string]float64, len(header))
+		for i, field := range record {
+			if v, err := strconv.ParseFloat(field, 64); err == nil {
+				values[header[i]] = v
+			}
+		}
+		number := func(name string) (float64, error) {
+			v, ok := values[name]
+			if !ok {
+				return 0, fmt.Errorf("dqn: line %d: column %q is not numeric", line, name)
+			}
+			return v, nil
+		}
+
+		row := csvRow{state: make([]float64, len(m.StateColumns))}
+		for i, name := range m.StateColumns {
+			if row.state[i], err = number(name); err != nil {
+				return nil, err
+			}
+		}
+		action, err := number(m.ActionColumn)
+		if err != nil {
+			return nil, err
+		}
+		row.action = int(action)
+
+		reward := 0.0
+		if m.RewardFunc != nil {
+			reward = m.RewardFunc(values)
+		} else if reward, err = number(m.RewardColumn); err != nil {
+			return nil, err
+		}
+		row.reward = int(math.Round(reward * scale))
+
+		if m.EpisodeColumn != "" {
+			row.episode = record[columns[m.EpisodeColumn]]
+		}
+		if m.DoneColumn != "" {
+			done, err := number(m.DoneColumn)
+			if err != nil {
+				return nil, err
+			}
+			row.done = done != 0
+		}
+		rows = append(rows, row)
+	}
+
+	return rowsToExperiences(rows), nil
+}
+
+// rowsToExperiences links consecutive rows of the same episode into experiences.
+func rowsToExperiences(rows []csvRow) []Experience {
+	experiences := make([]Experience, len(rows))
+	for i, row := range rows {
+		last := i == len(rows)-1 || row.done || rows[i+1].episode != row.episode
+		exp := Experience{State: row.state, NextState: row.state, Action: row.action, Reward: row.reward, Done: last}
+		if !last {
+			exp.NextState = rows[i+1].state
+		}
+		experiences[i] = exp
+	}
+	return experiences
+}
+
+// ReadExperiencesJSONL reads experiences from JSON Lines, one object per line
+// with the fields state, action, reward, next_state, done and optionally
+// next_mask and version. Rewards are multiplied by rewardScale and rounded.
+// Records written with older schema versions are upgraded; lines without a
+// version are version 1.
+func ReadExperiencesJSONL(r io.Reader, rewardScale float64) ([]Experience, error) {
+	var experiences []Experience
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		// A missing version field leaves version 1, while an explicit
+		// non-positive one is rejected like in binary files
+		rec := experienceRecord{Version: 1}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("dqn: line %d: %w", line, err)
+		}
+		if err := rec.upgrade(rec.Version); err != nil {
+			return nil, fmt.Errorf("dqn: line %d: %w", line, err)
+		}
+		experiences = append(experiences, rec.experience(rewardScale))
+	}
+	return experiences, scanner.Err()
+}
+
+// WriteExperiencesJSONL writes experiences as JSON Lines readable by ReadExperiencesJSONL.
+func WriteExperiencesJSONL(w io.Writer, experiences []Experience) error {
+	enc := json.NewEncoder(w)
+	for _, exp := range experiences {
+		if err := enc.Encode(newExperienceRecord(exp)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
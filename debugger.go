@@ -0,0 +1,114 @@
+// debugger.go
+package dqn
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DebugStep describes the step the trainer is about to take.
+type DebugStep struct {
+	Episode   int
+	Step      int
+	State     []float64
+	QValues   []float64
+	Action    int
+	BufferLen int
+	BufferCap int
+}
+
+// Debugger lets a user pause, single-step and inspect training from a terminal.
+// Training starts paused. While running, entering any line pauses it again.
+type Debugger struct {
+	out      io.Writer
+	lines    chan string
+	paused   bool
+	detached bool
+}
+
+// NewDebugger initializes a new Debugger reading commands from in and writing to out.
+// The input is read by a background goroutine until it is exhausted.
+func NewDebugger(in io.Reader, out io.Writer) *Debugger {
+	dbg := &Debugger{out: out, lines: make(chan string), paused: true}
+	go func() {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			dbg.lines <- strings.TrimSpace(scanner.Text())
+		}
+		close(dbg.lines)
+	}()
+	return dbg
+}
+
+// SetDebugger attaches a debugger to the trainer's single-environment loop.
+func (t *Trainer) SetDebugger(dbg *Debugger) {
+	t.debugger = dbg
+}
+
+const debuggerHelp = `commands:
+  s, step      take one step (default)
+  c, continue  run until a line is entered
+  i, info      show the current step
+  q, qvalues   show the Q-values
+  b, buffer    show replay buffer stats
+  d, detach    stop debugging and keep training
+  h, help      show this help
+`
+
+// before is called before each environment step and blocks while training is paused.
+func (dbg *Debugger) before(step DebugStep) {
+	if dbg.detached {
+		return
+	}
+	if !dbg.paused {
+		select {
+		case _, ok := <-dbg.lines:
+			if !ok {
+				dbg.detached = true
+				return
+			}
+			dbg.paused = true
+		default:
+			return
+		}
+	}
+
+	dbg.info(step)
+	for {
+		fmt.Fprint(dbg.out, "(dqn) ")
+		line, ok := <-dbg.lines
+		if !ok {
+			dbg.detached = true
+			return
+		}
+		switch line {
+		case "", "s", "step":
+			return
+		case "c", "continue":
+			dbg.paused = false
+			return
+		case "i", "info":
+			dbg.info(step)
+		case "q", "qvalues":
+			for a, q := range step.QValues {
+				fmt.Fprintf(dbg.out, "  Q[%d] = %.4f\n", a, q)
+			}
+		case "b", "buffer":
+			fmt.Fprintf(dbg.out, "  buffer %d/%d\n", step.BufferLen, step.BufferCap)
+		case "d", "detach":
+			dbg.detached = true
+			return
+		case "h", "help":
+			fmt.Fprint(dbg.out, debuggerHelp)
+		default:
+			fmt.Fprintf(dbg.out, "unknown command %q, enter h for help\n", line)
+		}
+	}
+}
+
+// info prints a summary of the step
+func (dbg *Debugger) info(step DebugStep) {
+	fmt.Fprintf(dbg.out, "episode %d step %d state %.4f action %d\n", step.Episode, step.Step, step.State, step.Action)
+}
@@ -0,0 +1,50 @@
+// delay.go
+package dqn
+
+// DelayRewards wraps env so that every reward is delivered delay steps after
+// it was earned, spread evenly over window steps. A window of one or less
+// delivers it at once. Rewards still pending when the episode ends are
+// delivered with the final step, so episode returns are unchanged.
+//
+// Spread rewards are fractions of the original ones, and the Trainer rounds
+// scaled rewards to integers. Train with a reward scale that keeps the
+// fractions, e.g. WithRewardScale(2) for unit rewards and a window of two,
+// which trains on each half as 1. With the default scale, fractions below
+// one half are lost.
+func DelayRewards(env Environment, delay, window int) Environment {
+	return &delayEnv{Environment: env, delay: delay, window: max(window, 1)}
+}
+
+// delayEnv delays and spreads the rewards of an environment.
+type delayEnv struct {
+	Environment
+	delay, window int
+	// pending[i] is the reward to be delivered i steps from now
+	pending []float64
+}
+
+func (e *delayEnv) Reset() []float64 {
+	e.pending = e.pending[:0]
+	return e.Environment.Reset()
+}
+
+func (e *delayEnv) Step(action int) ([]float64, float64, bool) {
+	state, reward, done := e.Environment.Step(action)
+
+	for len(e.pending) < e.delay+e.window {
+		e.pending = append(e.pending, 0)
+	}
+	for i := e.delay; i < e.delay+e.window; i++ {
+		e.pending[i] += reward / float64(e.window)
+	}
+
+	delivered := e.pending[0]
+	e.pending = e.pending[1:]
+	if done {
+		for _, r := range e.pending {
+			delivered += r
+		}
+		e.pending = e.pending[:0]
+	}
+	return state, delivered, done
+}
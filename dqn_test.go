@@ -2,7 +2,31 @@
 package dqn
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/gif"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+
+	"github.com/iampaapa/dqn/envs"
 )
 
 func TestQNetwork(t *testing.T) {
@@ -25,9 +49,2301 @@ func TestReplayBuffer(t *testing.T) {
 	}
 }
 
+func TestDeltaReplayBuffer(t *testing.T) {
+	buffer := NewDeltaReplayBuffer(2)
+	buffer.Add(Experience{State: []float64{1, 2}, NextState: []float64{1.5, 1}})
+	buffer.Add(Experience{State: []float64{3, 4}, NextState: []float64{3.25, 4}})
+	buffer.Add(Experience{State: []float64{5, 0.1}, NextState: []float64{5, 0.1 + 1e-17}})
+	if len(buffer.buffer) != 2 || len(buffer.changed) != 2 {
+		t.Errorf("Expected buffer size 2, got %d", len(buffer.buffer))
+	}
+	if exp := buffer.get(0); exp.State[0] != 3 || exp.State[1] != 4 || exp.NextState[0] != 3.25 || exp.NextState[1] != 4 {
+		t.Errorf("Unexpected reconstructed states %v and %v", exp.State, exp.NextState)
+	}
+	if exp := buffer.get(1); exp.State[1] != 0.1 || exp.NextState[1] != 0.1+1e-17 {
+		t.Errorf("Expected states to be reconstructed exactly, got %v and %v", exp.State, exp.NextState)
+	}
+	for _, exp := range buffer.Sample(4) {
+		if len(exp.NextState) != 2 {
+			t.Errorf("Expected reconstructed next state, got %v", exp.NextState)
+		}
+	}
+
+	// Slowly changing 512-dimensional states take about half the memory
+	plain := NewDQNFromConfig(DefaultConfig(512, 2)).replayBuffer.(*ReplayBuffer)
+	cfg := DefaultConfig(512, 2)
+	WithDeltaEncoding()(&cfg)
+	delta := NewDQNFromConfig(cfg).replayBuffer.(*ReplayBuffer)
+	state := make([]float64, 512)
+	for i := 0; i < 100; i++ {
+		next := append([]float64(nil), state...)
+		next[i%512] += 0.5
+		plain.Add(Experience{State: state, NextState: next})
+		delta.Add(Experience{State: state, NextState: next})
+		state = next
+	}
+	if ratio := float64(delta.stateBytes()) / float64(plain.stateBytes()); ratio > 0.55 {
+		t.Errorf("Expected delta encoding to halve state memory, got ratio %f", ratio)
+	}
+}
+
+func TestPrioritizedReplayBuffer(t *testing.T) {
+	buffer := NewPrioritizedReplayBuffer(200)
+	for i := 0; i < 99; i++ {
+		buffer.Add(Experience{State: []float64{0}, NextState: []float64{0}})
+	}
+	buffer.Add(Experience{State: []float64{1}, NextState: []float64{1}, Reward: 1})
+
+	novel := 0
+	for _, exp := range buffer.Sample(1000) {
+		novel += exp.Reward
+	}
+	// The novel state has priority 1 against a total of about 19.
+	if novel < 20 {
+		t.Errorf("Expected the novel state to be oversampled, got %d of 1000", novel)
+	}
+
+	// Evicted experiences no longer count as visits and are never sampled
+	buffer = NewPrioritizedReplayBuffer(4)
+	for i := 0; i < 7; i++ {
+		buffer.Add(Experience{State: []float64{float64(i % 5)}, NextState: []float64{0}, Reward: i})
+	}
+	if len(buffer.visits) != 4 {
+		t.Errorf("Expected visits of the 4 buffered states only, got %v", buffer.visits)
+	}
+	counts := map[int]int{}
+	for _, exp := range buffer.Sample(4000) {
+		counts[exp.Reward]++
+	}
+	for i := 3; i < 7; i++ {
+		if counts[i] < 800 {
+			t.Errorf("Expected experience %d to be sampled about 1000 times, got %v", i, counts)
+		}
+	}
+	if len(counts) != 4 {
+		t.Errorf("Expected only buffered experiences to be sampled, got %v", counts)
+	}
+
+	// The agent stores and samples through a configured buffer
+	cfg := DefaultConfig(1, 2)
+	cfg.BatchSize = 4
+	counting := &countingBuffer{Buffer: NewPrioritizedReplayBuffer(50)}
+	WithReplayBuffer(counting)(&cfg)
+	agent := NewDQNFromConfig(cfg)
+	for i := 0; i < 10; i++ {
+		agent.Observe(Experience{State: []float64{float64(i % 3)}, NextState: []float64{0}, Reward: i})
+	}
+	if counting.Len() != 10 || counting.samples != 10 {
+		t.Errorf("Expected 10 experiences stored and 10 batches sampled, got %d and %d", counting.Len(), counting.samples)
+	}
+}
+
+// countingBuffer counts the batches sampled from a Buffer.
+type countingBuffer struct {
+	Buffer
+	samples int
+}
+
+func (b *countingBuffer) Sample(batchSize int) []Experience {
+	b.samples++
+	return b.Buffer.Sample(batchSize)
+}
+
+func TestReplayBufferSubsampling(t *testing.T) {
+	buffer := NewReplayBuffer(100)
+	buffer.SetSubsampling(3, 0.1)
+	for i := 0; i < 9; i++ {
+		buffer.Add(Experience{State: []float64{0}, NextState: []float64{1}})
+	}
+	if len(buffer.buffer) != 3 {
+		t.Errorf("Expected every 3rd transition to be kept, got %d", len(buffer.buffer))
+	}
+
+	buffer = NewReplayBuffer(100)
+	buffer.SetSubsampling(0, 0.1)
+	buffer.Add(Experience{State: []float64{0}, NextState: []float64{0.01}})
+	buffer.Add(Experience{State: []float64{0}, NextState: []float64{0.01}, Done: true})
+	buffer.Add(Experience{State: []float64{0}, NextState: []float64{0.5}})
+	if len(buffer.buffer) != 2 {
+		t.Errorf("Expected unchanged transition to be skipped, got %d", len(buffer.buffer))
+	}
+
+	cfg := DefaultConfig(1, 2)
+	WithSubsampling(3, 0)(&cfg)
+	agent := NewDQNFromConfig(cfg)
+	for i := 0; i < 9; i++ {
+		agent.Observe(Experience{State: []float64{0}, NextState: []float64{1}})
+	}
+	if agent.replayBuffer.Len() != 3 {
+		t.Errorf("Expected the agent to keep every 3rd transition, got %d", agent.replayBuffer.Len())
+	}
+}
+
 func TestDQN(t *testing.T) {
 	dqn := NewDQN(4, 10, 2, 100, 0.9, 0.1, 0.001, ReLU)
 	state := []float64{1, 2, 3, 4}
 	nextState := []float64{2, 3, 4, 5}
 	dqn.Train(state, nextState, 1, 1, false)
 }
+
+func TestPolicyMonitor(t *testing.T) {
+	if e := ActionEntropy([]int{5, 5}); e < 0.69 || e > 0.7 {
+		t.Errorf("Expected entropy ln(2), got %f", e)
+	}
+	dqn := NewDQN(2, 4, 2, 10, 0.9, 0.1, 0.001, ReLU)
+	monitor := NewPolicyMonitor(2, [][]float64{{1, 0}, {0, 1}})
+	monitor.RecordAction(0)
+	monitor.Snapshot(1, dqn)
+	stats := monitor.Snapshot(2, dqn)
+	if stats.Churn != 0 || stats.Entropy != 0 {
+		t.Errorf("Expected no churn or entropy, got %+v", stats)
+	}
+}
+
+func TestActionMasking(t *testing.T) {
+	mask := []bool{false, true, false}
+	if idx := MaskedArgmax([]float64{3, 1, 2}, mask); idx != 1 {
+		t.Errorf("Expected masked argmax 1, got %d", idx)
+	}
+	dqn := NewDQN(2, 4, 3, 10, 0.9, 1.0, 0.001, ReLU)
+	for i := 0; i < 20; i++ {
+		if a := dqn.MaskedEpsilonGreedyPolicy([]float64{1, 2}, mask); a != 1 {
+			t.Fatalf("Selected masked action %d", a)
+		}
+	}
+	dqn.TrainMasked([]float64{1, 2}, []float64{2, 3}, 1, 1, false, mask)
+}
+
+func TestBranchingDQN(t *testing.T) {
+	dqn := NewBranchingDQN(3, 8, []int{2, 3, 2}, 0.9, 0.1, 0.001, ReLU)
+	state := []float64{1, 2, 3}
+	actions := dqn.EpsilonGreedyPolicy(state)
+	if len(actions) != 3 || actions[1] < 0 || actions[1] > 2 {
+		t.Errorf("Unexpected branched actions %v", actions)
+	}
+	dqn.Train(state, []float64{2, 3, 4}, actions, 1, false)
+}
+
+func TestProbeStates(t *testing.T) {
+	dqn := NewDQN(2, 4, 2, 10, 0.9, 0.1, 0.001, ReLU)
+	dqn.SetProbeStates([][]float64{{1, 0}, {0, 1}}, 2)
+	for i := 0; i < 5; i++ {
+		dqn.Train([]float64{1, 2}, []float64{2, 3}, 0, 1, false)
+	}
+	if n := len(dqn.ProbeHistory()); n != 2 {
+		t.Errorf("Expected 2 probe records, got %d", n)
+	}
+}
+
+func TestReturnReport(t *testing.T) {
+	report := NewReturnReport([]float64{4, 1, 3, 2}, 0.5, 2)
+	if report.Mean != 2.5 || report.Min != 1 || report.Max != 4 {
+		t.Errorf("Unexpected summary %+v", report)
+	}
+	if report.CVaR != 1.5 {
+		t.Errorf("Expected CVaR 1.5, got %f", report.CVaR)
+	}
+	if report.Histogram[0].Count != 2 || report.Histogram[1].Count != 2 {
+		t.Errorf("Unexpected histogram %+v", report.Histogram)
+	}
+	for _, bins := range []int{0, -1} {
+		if report := NewReturnReport([]float64{4, 1}, 0.5, bins); len(report.Histogram) != 1 || report.Histogram[0].Count != 2 {
+			t.Errorf("Expected a single bin for %d bins, got %+v", bins, report.Histogram)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	env := envs.NewGridWorld(2, 2)
+	dqn := NewDQN(env.StateSize(), 4, env.NumActions(), 10, 0.9, 0.1, 0.001, ReLU)
+	returns := Evaluate(dqn, env, 3)
+	if len(returns) != 3 {
+		t.Errorf("Expected 3 returns, got %d", len(returns))
+	}
+}
+
+func TestCompare(t *testing.T) {
+	a := []float64{10, 11, 12, 10, 11, 12}
+	b := []float64{1, 2, 3, 1, 2, 3}
+	if c := Compare(a, b, 0.95); !c.Significant || c.Difference != 9 {
+		t.Errorf("Expected A to significantly beat B, got %s", c)
+	}
+	if c := Compare(b, a, 0.95); c.Significant {
+		t.Errorf("Expected B not to beat A, got %s", c)
+	}
+	if x, y := CompareSeeded(a, b, 0.95, 3), CompareSeeded(a, b, 0.95, 3); x != y {
+		t.Errorf("Expected identical comparisons for the same seed, got %s and %s", x, y)
+	}
+}
+
+func TestDefaultConfig(t *testing.T) {
+	cfg := DefaultConfig(4, 2)
+	if cfg.HiddenSize != 64 || cfg.BufferSize != 10000 || cfg.TargetSyncInterval != 500 {
+		t.Errorf("Unexpected small-problem defaults %+v", cfg)
+	}
+	cfg = DefaultConfig(512, 18)
+	if cfg.HiddenSize != 512 || cfg.LearningRate != 2.5e-4 || cfg.TargetSyncInterval != 10000 {
+		t.Errorf("Unexpected large-problem defaults %+v", cfg)
+	}
+
+	dqn := NewDQNForEnv(envs.NewCartPole())
+	dqn.Train([]float64{1, 2, 3, 4}, []float64{2, 3, 4, 5}, 1, 1, false)
+}
+
+func TestSolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.gob")
+	env := envs.NewGridWorld(2, 2)
+	policy, report, err := Solve(env, WithEpisodes(20), WithEvalEpisodes(5), WithCheckpointPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Training) != 20 || report.Evaluation.Episodes != 5 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	loaded, err := LoadPolicy(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := []float64{0.5, 0.5}
+	if loaded.Act(state) != policy.Act(state) {
+		t.Errorf("Loaded policy does not match saved policy")
+	}
+}
+
+func TestVecTrainer(t *testing.T) {
+	vec := NewVecEnv(4, func() Environment { return envs.NewGridWorld(2, 2) })
+	step := vec.Step([]int{2, 2, 2, 2})
+	if len(step.NextStates) != 4 || step.Dones[0] {
+		t.Errorf("Unexpected batched step %+v", step)
+	}
+
+	agent := NewDQN(2, 8, 4, 10, 0.9, 0.1, 0.001, ReLU)
+	history, _ := NewVecTrainer(agent, vec, WithEpisodes(10)).Run(context.Background())
+	if len(history) < 10 {
+		t.Errorf("Expected at least 10 episodes, got %d", len(history))
+	}
+}
+
+func TestTrainerCancel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.gob")
+	env := envs.NewGridWorld(2, 2)
+	agent := NewDQNForEnv(env)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	history, err := NewTrainer(agent, env, WithCheckpointPath(path)).Run(ctx)
+	if !errors.Is(err, context.Canceled) || len(history) != 0 {
+		t.Errorf("Expected cancelled run, got %d episodes and %v", len(history), err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected final checkpoint: %v", err)
+	}
+}
+
+func TestDebugger(t *testing.T) {
+	var out bytes.Buffer
+	env := envs.NewGridWorld(2, 2)
+	trainer := NewTrainer(NewDQNForEnv(env), env, WithEpisodes(1))
+	trainer.SetDebugger(NewDebugger(strings.NewReader("q\nb\ns\nd\n"), &out))
+	if _, err := trainer.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"episode 0 step 0", "episode 0 step 1", "Q[3]", "buffer 0/10000"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected debugger output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	dir := t.TempDir()
+	env := envs.NewGridWorld(2, 2)
+	agent := NewDQNForEnv(env)
+	trainer := NewTrainer(agent, env, WithEpisodes(7), WithCheckpointing(dir, 2, 2), WithEvalEvery(2), WithEvalEpisodes(1))
+	if _, err := trainer.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "checkpoint-*.gob"))
+	if len(paths) != 2 {
+		t.Errorf("Expected 2 rolling checkpoints, got %v", paths)
+	}
+
+	resumed := NewTrainer(NewDQNForEnv(env), env, WithEpisodes(10))
+	if err := resumed.Resume(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed.History()) != 7 {
+		t.Errorf("Expected 7 restored episodes, got %d", len(resumed.History()))
+	}
+	state := []float64{0, 0}
+	if resumed.agent.GreedyPolicy(state) != agent.GreedyPolicy(state) || resumed.agent.rng.Int63() != agent.rng.Int63() ||
+		resumed.agent.replayRand.Int63() != agent.replayRand.Int63() {
+		t.Errorf("Resumed agent does not match checkpointed agent")
+	}
+	best, score := resumed.Best()
+	wantBest, wantScore := trainer.Best()
+	if len(resumed.Evaluations()) != 3 || best == nil || score != wantScore || best.Act(state) != wantBest.Act(state) ||
+		resumed.sinceBest != trainer.sinceBest {
+		t.Errorf("Expected early stopping state to be restored, got %v", resumed.Evaluations())
+	}
+	history, _ := resumed.Run(context.Background())
+	if len(history) != 10 {
+		t.Errorf("Expected 10 episodes after resume, got %d", len(history))
+	}
+}
+
+func TestResumeMatchesUninterruptedRun(t *testing.T) {
+	run := func(dir string, episodes int, resume bool) []EpisodeStats {
+		env := envs.NewCartPole()
+		cfg := DefaultConfig(4, 2)
+		cfg.Seed = 11
+		cfg.BatchSize = 4
+		cfg.Exploration = NewEpsilonGreedy(1, 0.1, 100, 5)
+		agent := NewDQNFromConfig(cfg)
+		pipeline := NewPipeline(ClipStep(-3, 3))
+		trainer := NewTrainer(agent, env, WithSeed(11), WithEpisodes(episodes), WithCheckpointing(dir, 3, 0),
+			WithCuriosity(NewRND(4, 8, 4, 0.05, 1, 0.99), 1), WithPipeline(pipeline))
+		if resume {
+			if err := trainer.Resume(dir); err != nil {
+				t.Fatal(err)
+			}
+		}
+		history, err := trainer.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return history
+	}
+	want := run(t.TempDir(), 6, false)
+	dir := t.TempDir()
+	run(dir, 3, false)
+	got := run(dir, 6, true)
+	for i := range want {
+		if got[i].Return != want[i].Return || got[i].Steps != want[i].Steps || got[i].Loss != want[i].Loss {
+			t.Fatalf("Expected the resumed run to match the uninterrupted one at episode %d, got %+v and %+v", i, got[i], want[i])
+		}
+	}
+
+	// Corrupted checkpoints are rejected
+	paths, _ := filepath.Glob(filepath.Join(dir, "checkpoint-*.gob"))
+	data, _ := os.ReadFile(paths[len(paths)-1])
+	data[len(data)-1] ^= 1
+	os.WriteFile(paths[len(paths)-1], data, 0o644)
+	if err := NewTrainer(NewDQNForEnv(envs.NewCartPole()), envs.NewCartPole()).Resume(dir); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected ErrChecksum, got %v", err)
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	if s := Sparkline([]float64{0, 1, 2}); s != "▁▄█" {
+		t.Errorf("Unexpected sparkline %q", s)
+	}
+
+	var out bytes.Buffer
+	env := envs.NewGridWorld(2, 2)
+	trainer := NewTrainer(NewDQNForEnv(env), env, WithEpisodes(3))
+	NewDashboard(&out).Attach(trainer)
+	if _, err := trainer.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Steps/sec") {
+		t.Errorf("Expected dashboard output, got %q", out.String())
+	}
+}
+
+func TestEarlyStopping(t *testing.T) {
+	env := envs.NewGridWorld(2, 2)
+	trainer := NewTrainer(NewDQNForEnv(env), env, WithEpisodes(100), WithEvalEvery(2), WithEvalEpisodes(1), WithStopThreshold(-1, 3))
+	history, err := trainer.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 6 || trainer.StopReason() != "threshold reached" {
+		t.Errorf("Expected stop after 6 episodes, got %d (%q)", len(history), trainer.StopReason())
+	}
+	if best, _ := trainer.Best(); best == nil {
+		t.Errorf("Expected a best policy")
+	}
+	if _, ok := trainer.Evaluations()[0].Baselines["random"]; !ok {
+		t.Errorf("Expected the random baseline in evaluations, got %+v", trainer.Evaluations()[0])
+	}
+
+	trainer = NewTrainer(NewDQNForEnv(env), env, WithEpisodes(100), WithEvalEvery(1), WithEvalEpisodes(1), WithPatience(2))
+	if _, err := trainer.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if trainer.StopReason() != "no improvement" {
+		t.Errorf("Expected training to stop for lack of improvement")
+	}
+}
+
+func TestRecordEpisode(t *testing.T) {
+	var out bytes.Buffer
+	env := envs.NewGridWorld(2, 2)
+	if err := RecordEpisode(&out, NewDQNForEnv(env).Policy(), env, 10); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) < 2 || anim.Image[0].Bounds().Dy() != 64+annotationHeight {
+		t.Errorf("Unexpected recording with %d frames", len(anim.Image))
+	}
+}
+
+func TestReadExperiencesCSV(t *testing.T) {
+	data := `batch,temp,pressure,action,yield
+a,150,50,0,0.5
+a,155,50,2,0.7
+b,160,52,1,0.2
+`
+	experiences, err := ReadExperiencesCSV(strings.NewReader(data), CSVMapping{
+		StateColumns:  []string{"temp", "pressure"},
+		ActionColumn:  "action",
+		RewardFunc:    func(row map[string]float64) float64 { return row["yield"] - 0.5 },
+		RewardScale:   10,
+		EpisodeColumn: "batch",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(experiences) != 3 {
+		t.Fatalf("Expected 3 experiences, got %d", len(experiences))
+	}
+	if experiences[0].Done || experiences[0].NextState[0] != 155 || experiences[0].Reward != 0 {
+		t.Errorf("Unexpected first experience %+v", experiences[0])
+	}
+	if !experiences[1].Done || experiences[1].Action != 2 || experiences[1].Reward != 2 {
+		t.Errorf("Unexpected episode end %+v", experiences[1])
+	}
+
+	if _, err := ReadExperiencesCSV(strings.NewReader(data), CSVMapping{ActionColumn: "missing"}); err == nil {
+		t.Errorf("Expected missing column error")
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	p := NewPipeline(
+		SelectStep(2, 0),
+		ScaleStep([]float64{1, 0}, []float64{2, 1}),
+		ClipStep(-1, 1),
+		OneHotStep(1, 3),
+		LagStep(1),
+	)
+	first := p.Transform([]float64{2, 9, 5})
+	want := []float64{1, 0, 1, 0, 0, 0, 0, 0}
+	for i := range want {
+		if first[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, first)
+		}
+	}
+	if second := p.Transform([]float64{0, 9, 1}); second[1] != 1 || second[6] != 1 {
+		t.Errorf("Expected lagged features, got %v", second)
+	}
+	peeked := p.Peek([]float64{4, 9, 3})
+	if third := p.Transform([]float64{4, 9, 3}); fmt.Sprint(peeked) != fmt.Sprint(third) {
+		t.Errorf("Expected Peek not to advance the lag state, got %v and %v", peeked, third)
+	}
+	if n := p.OutputSize(3); n != 8 {
+		t.Errorf("Expected output size 8, got %d", n)
+	}
+
+	env := envs.NewGridWorld(2, 2)
+	policy, _, err := Solve(env, WithEpisodes(2), WithEvalEpisodes(1), WithPipeline(NewPipeline(LagStep(1))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := policy.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPolicy(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Pipeline() == nil || len(loaded.QValues([]float64{0, 0})) != 4 {
+		t.Errorf("Expected policy to be saved with its pipeline")
+	}
+}
+
+func TestTrainStep(t *testing.T) {
+	dqn := NewDQN(2, 8, 2, 10, 0.9, 0.1, 0.01, ReLU)
+	state := []float64{1, 0}
+	var first StepReport
+	for i := 0; i < 200; i++ {
+		report := dqn.TrainStep(state, state, 0, 1, true)
+		if i == 0 {
+			first = report
+		}
+		if report.Target != 1 || report.TDError != report.Target-report.Prediction {
+			t.Fatalf("Unexpected step report %+v", report)
+		}
+	}
+	if first.GradNorm <= 0 {
+		t.Errorf("Expected a positive gradient norm, got %f", first.GradNorm)
+	}
+	if last := dqn.TrainStep(state, state, 0, 1, true); math.Abs(last.TDError) >= math.Abs(first.TDError) {
+		t.Errorf("Expected TD error to shrink, got %f then %f", first.TDError, last.TDError)
+	}
+}
+
+func TestFitOffline(t *testing.T) {
+	data := `{"state":[1,0],"action":0,"reward":1.4,"next_state":[0,1],"done":false}
+{"state":[0,1],"action":1,"reward":0,"next_state":[0,1],"done":true}
+`
+	experiences, err := ReadExperiencesJSONL(strings.NewReader(data), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(experiences) != 2 || experiences[0].Reward != 1 || !experiences[1].Done {
+		t.Fatalf("Unexpected experiences %+v", experiences)
+	}
+
+	for _, version := range []string{"0", "-1"} {
+		invalid := `{"version":` + version + `,"state":[0],"action":1,"reward":1,"next_state":[1],"done":true}` + "\n"
+		if _, err := ReadExperiencesJSONL(strings.NewReader(invalid), 1); err == nil || !strings.Contains(err.Error(), "invalid") {
+			t.Errorf("Expected an error for version %s, got %v", version, err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := WriteExperiencesJSONL(&buf, experiences); err != nil {
+		t.Fatal(err)
+	}
+	if roundTrip, _ := ReadExperiencesJSONL(&buf, 1); len(roundTrip) != 2 {
+		t.Errorf("Expected 2 experiences after round trip, got %d", len(roundTrip))
+	}
+
+	dqn := NewDQN(2, 8, 2, 10, 0.9, 0.1, 0.05, ReLU)
+	losses := dqn.FitOffline(experiences, 50, 2)
+	if len(losses) != 50 || losses[49] >= losses[0] {
+		t.Errorf("Expected offline loss to decrease, got %f then %f", losses[0], losses[49])
+	}
+
+	for name, fit := range map[string]func(){
+		"zero batch size": func() { dqn.FitOffline(experiences, 1, 0) },
+		"empty batch":     func() { dqn.TrainBatch(nil) },
+		"n-step targets": func() {
+			cfg := DefaultConfig(2, 2)
+			cfg.NSteps = 3
+			NewDQNFromConfig(cfg).FitOffline(experiences, 1, 2)
+		},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected a panic for %s", name)
+				}
+			}()
+			fit()
+		}()
+	}
+}
+
+func TestNetworkInspection(t *testing.T) {
+	qnet := NewQNetwork(3, 4, 2, Tanh)
+	summary := qnet.Summary()
+	if summary.TotalParams != 3*4+4+4*2+2 || summary.Layers[0].Activation != "tanh" {
+		t.Errorf("Unexpected summary:\n%s", summary)
+	}
+
+	weights := qnet.Weights(1)
+	if len(weights) != 2 || len(weights[0]) != 4 {
+		t.Fatalf("Unexpected weight shape %dx%d", len(weights), len(weights[0]))
+	}
+	weights[0][0] = 42
+	if err := qnet.SetWeights(1, weights); err != nil {
+		t.Fatal(err)
+	}
+	if qnet.Weights(1)[0][0] != 42 {
+		t.Errorf("Expected weight to be set")
+	}
+	if err := qnet.SetBiases(0, []float64{1}); err == nil {
+		t.Errorf("Expected bias length error")
+	}
+}
+
+func TestRegularization(t *testing.T) {
+	dqn := NewDQNFromConfig(Config{
+		StateSize: 2, NumActions: 2, HiddenSize: 16, BufferSize: 10,
+		Gamma: 0.9, LearningRate: 0.01, Activation: ReLU,
+		WeightDecay: 0.1, Dropout: 0.5,
+	})
+	state := []float64{1, 2}
+	first := dqn.qNetwork.Predict(state)
+	if second := dqn.qNetwork.Predict(state); first[0] != second[0] {
+		t.Errorf("Predict must not apply dropout")
+	}
+
+	before := mat.Norm(dqn.qNetwork.w1, 2)
+	qnet := dqn.qNetwork
+	qnet.apply(qnet.gradients(state, first, first, nil), 0.01)
+	if after := mat.Norm(qnet.w1, 2); after >= before {
+		t.Errorf("Expected weight decay to shrink weights, got %f then %f", before, after)
+	}
+	dqn.Train(state, state, 0, 1, true)
+}
+
+func TestRND(t *testing.T) {
+	rnd := NewRND(2, 16, 4, 0.05, 1, 0.5)
+	familiar := []float64{0.5, -0.5}
+	for i := 0; i < 200; i++ {
+		rnd.Bonus(familiar)
+	}
+	if rnd.Error(familiar) >= rnd.Error([]float64{5, 5}) {
+		t.Errorf("Expected a lower prediction error on the familiar state")
+	}
+	if bonus := rnd.Bonus(familiar); bonus > 1e-6 {
+		t.Errorf("Expected the bonus to have decayed, got %f", bonus)
+	}
+
+	// The bonus of a novel state survives rounding with a large reward scale
+	env := &countEnv{}
+	trainer := NewTrainer(NewDQNForEnv(env), env, WithCuriosity(NewRND(1, 16, 4, 0.05, 1, 1), 1000))
+	if reward := trainer.trainingReward([]float64{5}, 0); trainer.cfg.RewardScale != 1000 || reward <= 0 {
+		t.Errorf("Expected a scaled curiosity bonus, got %d with scale %f", reward, trainer.cfg.RewardScale)
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	agent := NewDQN(1, 4, 2, 10, 0.9, 0.1, 0.01, ReLU)
+	for i := 0; i < 4; i++ {
+		agent.Observe(Experience{State: []float64{float64(i)}, NextState: []float64{0}, Action: 0})
+	}
+	a := agent.ReplaySnapshot()
+	for i := 0; i < 4; i++ {
+		agent.Observe(Experience{State: []float64{float64(i + 10)}, NextState: []float64{0}, Action: 1, Reward: 1})
+	}
+	diff := DiffSnapshots(a, agent.ReplaySnapshot(), 2)
+	if diff.StateKS[0] != 0.5 {
+		t.Errorf("Expected KS statistic 0.5, got %f", diff.StateKS[0])
+	}
+	if diff.ActionDistance != 0.5 || diff.RewardDistance != 0.5 {
+		t.Errorf("Expected action and reward distances 0.5, got %f and %f", diff.ActionDistance, diff.RewardDistance)
+	}
+	if diff.StateShift[0] <= 0 {
+		t.Errorf("Expected a positive state shift, got %f", diff.StateShift[0])
+	}
+}
+
+func TestEligibilityTraces(t *testing.T) {
+	cfg := Config{StateSize: 3, NumActions: 1, HiddenSize: 32, BufferSize: 10, Gamma: 1, LearningRate: 0.05, Activation: Tanh}
+	withTraces := cfg
+	withTraces.Lambda = 0.9
+
+	// A three-step chain with a reward only at the end: traces must propagate
+	// it back to the first state in a single episode.
+	chain := [][]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	value := func(d *DQN) float64 {
+		d.Train(chain[0], chain[1], 0, 0, false)
+		d.Train(chain[1], chain[2], 0, 0, false)
+		d.Train(chain[2], chain[2], 0, 1, true)
+		return d.qNetwork.Predict(chain[0])[0]
+	}
+	// A zero output layer predicts zero everywhere, so only the last step has a TD error
+	oneStep := NewDQNFromConfig(cfg)
+	oneStep.qNetwork.w2.Zero()
+	oneStep.qNetwork.b2.Zero()
+	traced := NewDQNFromConfig(withTraces)
+	traced.qNetwork = oneStep.qNetwork.Clone()
+	if a, b := value(oneStep), value(traced); b <= a {
+		t.Errorf("Expected traces to raise the first state's value faster, got %f <= %f", b, a)
+	}
+	if traced.traces != nil {
+		t.Errorf("Expected traces to be cleared at the end of the episode")
+	}
+}
+
+func TestVecTrainerPipeline(t *testing.T) {
+	pipeline := NewPipeline(LagStep(1))
+	vec := NewVecEnv(2, func() Environment { return &countEnv{} })
+	cfg := DefaultConfig(2, 1)
+	trainer := NewVecTrainer(NewDQNFromConfig(cfg), vec, WithPipeline(pipeline), WithEpisodes(2))
+	if _, err := trainer.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := vec.StateSize(); n != 2 {
+		t.Errorf("Expected lagged states of size 2, got %d", n)
+	}
+	if exp := trainer.agent.replayBuffer.Snapshot()[2]; len(exp.State) != 2 || exp.State[1] != 0 {
+		t.Errorf("Expected pipeline features in the replay buffer, got %v", exp.State)
+	}
+}
+
+func TestTrainerTraces(t *testing.T) {
+	cfg := DefaultConfig(1, 1)
+	cfg.Lambda = 0.9
+	for name, schedule := range map[string]func(*Config){
+		"TrainEvery 2": func(c *Config) { c.TrainEvery = 2 },
+		"BatchSize 4":  func(c *Config) { c.BatchSize = 4 },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected traces with %s to be rejected", name)
+				}
+			}()
+			rejected := cfg
+			schedule(&rejected)
+			NewDQNFromConfig(rejected)
+		}()
+	}
+
+	vec := NewVecEnv(2, func() Environment { return &countEnv{} })
+	trainer := NewVecTrainer(NewDQNFromConfig(cfg), vec)
+	exp := Experience{State: []float64{0}, NextState: []float64{1}}
+	trainer.observe(0, exp)
+	if trainer.traces[0] == nil || trainer.traces[1] != nil {
+		t.Fatalf("Expected a trace for the first environment only")
+	}
+	trainer.observe(1, exp)
+	if trainer.traces[0] == trainer.traces[1] {
+		t.Errorf("Expected environments not to share a trace")
+	}
+	trainer.resetEpisode(0)
+	if trainer.traces[0] != nil || trainer.traces[1] == nil {
+		t.Errorf("Expected only the first environment's trace to be cleared")
+	}
+}
+
+func TestGradientSimilarity(t *testing.T) {
+	dqn := NewDQN(2, 8, 2, 10, 0.9, 0.1, 0.01, ReLU)
+	up := []Experience{{State: []float64{1, 0}, NextState: []float64{1, 0}, Action: 0, Reward: 100, Done: true}}
+	down := []Experience{{State: []float64{1, 0}, NextState: []float64{1, 0}, Action: 0, Reward: -100, Done: true}}
+
+	similarity := GradientSimilarity(dqn, [][]Experience{up, up, down})
+	if math.Abs(similarity[0][1]-1) > 1e-9 {
+		t.Errorf("Expected identical tasks to have similarity 1, got %f", similarity[0][1])
+	}
+	if similarity[0][2] > -0.99 {
+		t.Errorf("Expected opposing tasks to have similarity -1, got %f", similarity[0][2])
+	}
+
+	monitor := NewPolicyMonitor(2, nil)
+	monitor.SetGradientTasks([][]Experience{up, down})
+	if stats := monitor.Snapshot(1, dqn); len(stats.GradientSimilarity) != 2 || stats.GradientSimilarity[0][1] > -0.99 {
+		t.Errorf("Expected gradient similarity in the snapshot, got %v", stats.GradientSimilarity)
+	}
+
+	// Diagnostics do not draw dropout masks from the exploration stream
+	dropout := NewDQN(2, 8, 2, 10, 0.9, 0.1, 0.01, ReLU)
+	dropout.qNetwork.SetDropout(0.5)
+	before := *dropout.rngSource.pcg
+	GradientSimilarity(dropout, [][]Experience{up, down})
+	if *dropout.rngSource.pcg != before {
+		t.Errorf("Expected GradientSimilarity to leave the exploration stream untouched")
+	}
+}
+
+func TestSaveFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.dqn")
+	policy := NewDQN(2, 4, 2, 10, 0.9, 0.1, 0.01, ReLU).Policy()
+	if err := policy.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o644 {
+		t.Errorf("Expected a file readable by everyone, got %v", info.Mode())
+	}
+
+	var loaded Policy
+	if err := loaded.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	state := []float64{0.3, -0.7}
+	if loaded.QValues(state)[0] != policy.QValues(state)[0] {
+		t.Errorf("Loaded policy does not match saved policy")
+	}
+
+	data, _ := os.ReadFile(path)
+	data[len(data)-1] ^= 0xff
+	os.WriteFile(path, data, 0o644)
+	if err := loaded.LoadFile(path); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected ErrChecksum, got %v", err)
+	}
+	if matches, _ := filepath.Glob(path + ".tmp*"); len(matches) != 0 {
+		t.Errorf("Expected no temporary files, got %v", matches)
+	}
+}
+
+func TestTaskSampler(t *testing.T) {
+	sampler := NewTaskSampler(2, 10, 0, 1)
+	if task := sampler.Sample(); task != 0 {
+		t.Errorf("Expected unseen task 0 first, got %d", task)
+	}
+	for i := 0; i < 10; i++ {
+		sampler.Record(0, 5)
+		sampler.Record(1, float64(i))
+	}
+	if sampler.Progress(0) != 0 || sampler.Progress(1) != 5 {
+		t.Errorf("Expected progress 0 and 5, got %f and %f", sampler.Progress(0), sampler.Progress(1))
+	}
+	for i := 0; i < 100; i++ {
+		if task := sampler.Sample(); task != 1 {
+			t.Fatalf("Expected only the improving task to be sampled, got %d", task)
+		}
+	}
+}
+
+func TestExportBundle(t *testing.T) {
+	env := envs.NewGridWorld(2, 2)
+	agent := NewDQNFromConfig(DefaultConfig(env.StateSize(), env.NumActions()))
+	trainer := NewTrainer(agent, env, WithEpisodes(3))
+	if _, err := trainer.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	report := NewReturnReport(Evaluate(agent, env, 5), 0.1, 5)
+	if err := trainer.ExportBundle(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name], _ = io.ReadAll(tr)
+	}
+
+	var manifest BundleManifest
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Seed == 0 || manifest.Activation != "relu" {
+		t.Errorf("Unexpected manifest %+v", manifest)
+	}
+	for name, sum := range manifest.Files {
+		got := sha256.Sum256(files[name])
+		if hex.EncodeToString(got[:]) != sum {
+			t.Errorf("Checksum mismatch for %s", name)
+		}
+	}
+	if len(manifest.Files) != len(files)-1 {
+		t.Errorf("Expected the manifest to list %d files, got %d", len(files)-1, len(manifest.Files))
+	}
+}
+
+func TestUpdateSchedule(t *testing.T) {
+	cfg := DefaultConfig(2, 2)
+	cfg.BatchSize = 4
+	cfg.WarmupSteps = 10
+	cfg.TrainEvery = 4
+	cfg.GradientStepsPerUpdate = 2
+	dqn := NewDQNFromConfig(cfg)
+
+	exp := Experience{State: []float64{0, 1}, NextState: []float64{1, 0}, Action: 1, Reward: 1}
+	updates := 0
+	for i := 0; i < 20; i++ {
+		if _, updated := dqn.Observe(exp); updated {
+			updates++
+		}
+	}
+	// Updates at steps 12, 16 and 20, two gradient steps each
+	if updates != 3 || dqn.updates != 6 {
+		t.Errorf("Expected 3 updates of 2 gradient steps, got %d and %d", updates, dqn.updates)
+	}
+	if dqn.replayBuffer.Len() != 20 {
+		t.Errorf("Expected 20 buffered transitions, got %d", dqn.replayBuffer.Len())
+	}
+}
+
+// matchEnv is a two-agent environment rewarding both agents when their actions match.
+type matchEnv struct{ steps int }
+
+func (e *matchEnv) Reset() [][]float64 { e.steps = 0; return [][]float64{{0}, {1}} }
+func (e *matchEnv) NumAgents() int     { return 2 }
+func (e *matchEnv) StateSize() int     { return 1 }
+func (e *matchEnv) NumActions() int    { return 2 }
+
+func (e *matchEnv) Step(actions []int) ([][]float64, []float64, bool) {
+	e.steps++
+	reward := 0.0
+	if actions[0] == actions[1] {
+		reward = 1
+	}
+	return [][]float64{{0}, {1}}, []float64{reward, reward}, e.steps == 5
+}
+
+func TestMultiAgentTrainer(t *testing.T) {
+	trainer := NewMultiAgentTrainer(&matchEnv{}, false, WithEpisodes(4))
+	history, err := trainer.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 4 || len(history[0].Returns) != 2 || history[0].Steps != 5 {
+		t.Errorf("Unexpected history %+v", history)
+	}
+	if history[0].Returns[0] != history[0].Returns[1] {
+		t.Errorf("Expected equal returns, got %v", history[0].Returns)
+	}
+	if agents := trainer.Agents(); agents[0] == agents[1] {
+		t.Errorf("Expected independent agents")
+	}
+	if agents := NewMultiAgentTrainer(&matchEnv{}, true).Agents(); agents[0] != agents[1] {
+		t.Errorf("Expected a shared agent")
+	}
+}
+
+// countMultiEnv is a two-agent countEnv in which both agents observe and
+// receive the same values.
+type countMultiEnv struct{ countEnv }
+
+func (e *countMultiEnv) NumAgents() int { return 2 }
+
+func (e *countMultiEnv) Reset() [][]float64 {
+	state := e.countEnv.Reset()
+	return [][]float64{state, state}
+}
+
+func (e *countMultiEnv) Step(actions []int) ([][]float64, []float64, bool) {
+	state, reward, done := e.countEnv.Step(actions[0])
+	return [][]float64{state, state}, []float64{reward, reward}, done
+}
+
+func TestMultiAgentNSteps(t *testing.T) {
+	trainer := NewMultiAgentTrainer(&countMultiEnv{}, false, WithEpisodes(1), WithGamma(1), WithNSteps(2))
+	if _, err := trainer.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for i, agent := range trainer.Agents() {
+		// Rewards 1..4 give the 2-step experiences 1+2, 2+3, 3+4 and the flushed 4
+		snapshot := agent.ReplaySnapshot()
+		if len(snapshot) != 4 || snapshot[0].Reward != 3 || snapshot[0].NextState[0] != 2 || snapshot[3].Reward != 4 {
+			t.Errorf("Agent %d: expected 2-step experiences, got %+v", i, snapshot)
+		}
+	}
+}
+
+func TestMultiAgentTraces(t *testing.T) {
+	trainer := NewMultiAgentTrainer(&countMultiEnv{}, true, WithLambda(0.9))
+	trainer.observe(0, Experience{State: []float64{0}, NextState: []float64{1}, Reward: 1})
+	first := trainer.traces[0].clone()
+	trainer.observe(1, Experience{State: []float64{5}, NextState: []float64{6}, Reward: 1})
+	if trainer.traces[0] == nil || trainer.traces[1] == nil || trainer.traces[0] == trainer.traces[1] {
+		t.Fatalf("Expected a separate trace per agent")
+	}
+	if trainer.traces[0].norm() != first.norm() {
+		t.Errorf("Expected the trace of agent 0 to be unaffected by agent 1")
+	}
+}
+
+func TestAdamSyncReset(t *testing.T) {
+	cfg := DefaultConfig(2, 2)
+	cfg.Adam = true
+	cfg.TargetSyncInterval = 3
+	dqn := NewDQNFromConfig(cfg)
+
+	state := []float64{0.5, -0.5}
+	first := dqn.TrainStep(state, state, 0, 1, true)
+	dqn.TrainStep(state, state, 0, 1, true)
+	if dqn.TrainStep(state, state, 0, 1, true).Loss >= first.Loss {
+		t.Errorf("Expected Adam to reduce the loss")
+	}
+	if dqn.optimizer.t != 3 {
+		t.Errorf("Expected moments to survive a sync without SyncReset")
+	}
+
+	data, err := dqn.optimizer.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := newAdam()
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if restored.t != 3 || restored.m.b2.AtVec(0) != dqn.optimizer.m.b2.AtVec(0) {
+		t.Errorf("Restored moments do not match")
+	}
+
+	dqn.syncReset = true
+	for i := 0; i < 3; i++ {
+		dqn.TrainStep(state, state, 0, 1, true)
+	}
+	if dqn.optimizer.m != nil || dqn.optimizer.t != 0 {
+		t.Errorf("Expected the optimizer to be reset on sync")
+	}
+}
+
+// countEnv rewards step i of an episode with i and ends after four steps.
+type countEnv struct{ steps int }
+
+func (e *countEnv) Reset() []float64 { e.steps = 0; return []float64{0} }
+func (e *countEnv) StateSize() int   { return 1 }
+func (e *countEnv) NumActions() int  { return 1 }
+
+func (e *countEnv) Step(int) ([]float64, float64, bool) {
+	e.steps++
+	return []float64{float64(e.steps)}, float64(e.steps), e.steps == 4
+}
+
+func TestDelayRewards(t *testing.T) {
+	env := DelayRewards(&countEnv{}, 1, 2)
+	for episode := 0; episode < 2; episode++ {
+		env.Reset()
+		var rewards []float64
+		for done := false; !done; {
+			var reward float64
+			_, reward, done = env.Step(0)
+			rewards = append(rewards, reward)
+		}
+		// Rewards 1..4, each delivered half one step and half two steps later
+		want := []float64{0, 0.5, 1.5, 8}
+		for i := range want {
+			if rewards[i] != want[i] {
+				t.Errorf("Expected %v, got %v", want, rewards)
+				break
+			}
+		}
+	}
+}
+
+func TestObservationExport(t *testing.T) {
+	agent := NewDQN(1, 4, 2, 10, 0.9, 0.1, 0.01, ReLU)
+	agent.Observe(Experience{State: []float64{0}, NextState: []float64{1}})
+	agent.Observe(Experience{State: []float64{1}, NextState: []float64{2}, Done: true})
+	agent.Observe(Experience{State: []float64{5}, NextState: []float64{6}})
+	experiences := agent.ReplaySnapshot()
+	sequences := ObservationSequences(experiences)
+	if len(sequences) != 2 || len(sequences[0]) != 3 || len(sequences[1]) != 2 {
+		t.Errorf("Unexpected sequences %v", sequences)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteObservationsCSV(&buf, experiences); err != nil {
+		t.Fatal(err)
+	}
+	want := "sequence,step,s0\n0,0,0\n0,1,1\n0,2,2\n1,0,5\n1,1,6\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+
+	q := NewQNetwork(2, 2, 1, ReLU)
+	encoder, err := ReadEncoderJSON(strings.NewReader(`{"weights": [[1, 0], [0, 1]], "biases": [0.5, 0.5]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.LoadEncoder(encoder); err != nil {
+		t.Fatal(err)
+	}
+	if q.Weights(0)[1][1] != 1 || q.Biases(0)[0] != 0.5 {
+		t.Errorf("Encoder was not loaded into the hidden layer")
+	}
+	if err := q.LoadEncoder(Encoder{Weights: [][]float64{{1}}}); err == nil {
+		t.Errorf("Expected a shape error")
+	}
+}
+
+func TestAutoencoder(t *testing.T) {
+	// Four-dimensional observations that vary along a single direction
+	agent := NewDQN(4, 8, 2, 20, 0.9, 0.1, 0.01, ReLU)
+	for i := 0; i < 20; i++ {
+		v := float64(i)/20 - 0.5
+		state := []float64{v, -v, 2 * v, 0}
+		agent.Observe(Experience{State: state, NextState: state})
+	}
+	experiences := agent.ReplaySnapshot()
+	ae := NewAutoencoder(4, 2, Tanh, 1)
+	losses := ae.Fit(experiences, 200, 0.05)
+	if losses[len(losses)-1] >= losses[0]/2 {
+		t.Errorf("Expected the reconstruction loss to drop, got %f then %f", losses[0], losses[len(losses)-1])
+	}
+	again := NewAutoencoder(4, 2, Tanh, 1).Fit(experiences, 200, 0.05)
+	if again[len(again)-1] != losses[len(losses)-1] {
+		t.Errorf("Expected the same seed to give the same fit")
+	}
+
+	step, err := ae.Step()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipeline := NewPipeline(step)
+	code := pipeline.Transform(experiences[3].State)
+	want := ae.Encode(experiences[3].State)
+	if len(code) != 2 || math.Abs(code[0]-want[0]) > 1e-12 || math.Abs(code[1]-want[1]) > 1e-12 {
+		t.Errorf("Expected the encode step to give %v, got %v", want, code)
+	}
+
+	// The encoder is saved with the policy
+	policy := NewDQN(2, 8, 2, 10, 0.9, 0.1, 0.01, ReLU).Policy().WithPipeline(pipeline)
+	var buf bytes.Buffer
+	if err := policy.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPolicy(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Act(experiences[3].State) != policy.Act(experiences[3].State) {
+		t.Errorf("Expected the loaded policy to encode observations like the original")
+	}
+}
+
+func TestWindows(t *testing.T) {
+	readings := [][]float64{{1, 10}, {2, 20}, {3, 30}, {4, 40}, {5, 50}}
+	flat, err := Windows(readings, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flat) != 2 || len(flat[1]) != 4 || flat[1][0] != 3 || flat[1][3] != 40 {
+		t.Errorf("Unexpected windows %v", flat)
+	}
+
+	aggregated, err := Windows(readings, 3, 1, WindowMean, WindowMax)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{3, 30, 4, 40}
+	if len(aggregated) != 3 {
+		t.Fatalf("Expected 3 windows, got %d", len(aggregated))
+	}
+	for i := range want {
+		if aggregated[1][i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, aggregated[1])
+			break
+		}
+	}
+	if _, err := Windows(readings, 2, 1, "median"); err == nil {
+		t.Errorf("Expected an error for an unknown aggregation")
+	}
+
+	window := NewSlidingWindow(2, WindowMin)
+	if _, ok, _ := window.Push(readings[0]); ok {
+		t.Errorf("Expected no observation before the window is full")
+	}
+	if obs, ok, _ := window.Push(readings[1]); !ok || obs[0] != 1 || obs[1] != 10 {
+		t.Errorf("Unexpected observation %v", obs)
+	}
+}
+
+func TestDiscountedReturns(t *testing.T) {
+	got := DiscountedReturns([]float64{1, 0, 2}, 0.5)
+	// 2; 0 + 0.5*2 = 1; 1 + 0.5*1 = 1.5
+	want := []float64{1.5, 1, 2}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, got)
+			break
+		}
+	}
+
+	experiences := []Experience{{Reward: 1}, {Reward: 2, Done: true}, {Reward: 4}, {Reward: 8}}
+	got = TrajectoryReturns(experiences, 0.5)
+	want = []float64{2, 2, 8, 8}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, got)
+			break
+		}
+	}
+}
+
+func TestAdvantages(t *testing.T) {
+	rewards := []float64{1, 0, 2}
+	values := []float64{1, 1, 1}
+
+	// lambda 1: discounted returns 1.5, 1, 2 minus the baseline
+	got := Advantages(rewards, values, 0, 0.5, 1)
+	want := []float64{0.5, 0, 1}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-12 {
+			t.Errorf("Expected %v, got %v", want, got)
+			break
+		}
+	}
+
+	// lambda 0: TD errors 1+0.5-1, 0+0.5-1, 2+0.5*4-1
+	got = Advantages(rewards, values, 4, 0.5, 0)
+	want = []float64{0.5, -0.5, 3}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-12 {
+			t.Errorf("Expected %v, got %v", want, got)
+			break
+		}
+	}
+}
+
+func TestDelayedActor(t *testing.T) {
+	// Action 0 moves left and action 1 moves right by one unit
+	var experiences []Experience
+	for x := -3.0; x <= 3; x++ {
+		experiences = append(experiences,
+			Experience{State: []float64{x}, NextState: []float64{x - 1}, Action: 0},
+			Experience{State: []float64{x}, NextState: []float64{x + 1}, Action: 1})
+	}
+	model := NewLinearDynamics(1, 2, 0.01)
+	if loss := model.Fit(experiences, 500); loss > 1e-3 {
+		t.Errorf("Expected the model to fit linear dynamics, got loss %f", loss)
+	}
+
+	actor := NewDelayedActor(NewDQN(1, 4, 2, 10, 0.9, 0, 0.01, ReLU).Policy(), model, 2)
+	first := actor.Act([]float64{0})
+	second := actor.Act([]float64{0})
+	step := map[int]float64{0: -1, 1: 1}
+	if got := actor.Expected([]float64{0})[0]; math.Abs(got-step[first]-step[second]) > 0.05 {
+		t.Errorf("Expected the state to be rolled forward over the pending actions, got %f", got)
+	}
+	actor.Reset()
+	if got := actor.Expected([]float64{0})[0]; got != 0 {
+		t.Errorf("Expected no pending actions after Reset, got %f", got)
+	}
+}
+
+func TestController(t *testing.T) {
+	calls := 0
+	controller := NewController(func([]float64) int { calls++; return calls }, 10*time.Millisecond)
+	clock := time.Unix(0, 0)
+	controller.now = func() time.Time { return clock }
+
+	// Ask every 4ms: decisions at 0, 12 and 24ms, holds in between
+	var actions []int
+	for i := 0; i < 7; i++ {
+		actions = append(actions, controller.Act(nil))
+		clock = clock.Add(4 * time.Millisecond)
+	}
+	want := []int{1, 1, 1, 2, 2, 2, 3}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Errorf("Expected actions %v, got %v", want, actions)
+			break
+		}
+	}
+	stats := controller.Stats()
+	if stats.Decisions != 3 || stats.Holds != 4 || stats.MeanJitter != 2*time.Millisecond || stats.MaxJitter != 2*time.Millisecond {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+// hangingEnv is a countEnv whose second Step blocks until release is closed.
+type hangingEnv struct {
+	countEnv
+	release chan struct{}
+}
+
+func (e *hangingEnv) Step(action int) ([]float64, float64, bool) {
+	if e.steps == 1 {
+		<-e.release
+	}
+	return e.countEnv.Step(action)
+}
+
+func TestWatchdogEnv(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	created := 0
+	env := NewWatchdogEnv(func() Environment {
+		created++
+		return &hangingEnv{release: release}
+	}, 20*time.Millisecond)
+
+	env.Reset()
+	env.Step(0)
+	state, reward, done := env.Step(0)
+	if !done || reward != 0 || state[0] != 1 {
+		t.Errorf("Expected a hung step to end the episode, got %v, %f, %v", state, reward, done)
+	}
+	if len(env.Incidents()) != 1 || env.Incidents()[0].Op != "step" || created != 2 {
+		t.Errorf("Expected one incident and a replacement, got %v and %d environments", env.Incidents(), created)
+	}
+	if !env.Interrupted() {
+		t.Errorf("Expected a hung step to be reported as interrupted")
+	}
+	if state := env.Reset(); state[0] != 0 || env.Interrupted() {
+		t.Errorf("Expected the replacement to reset, got %v", state)
+	}
+
+	// The error of the current environment is visible through the watchdog
+	failing := NewWatchdogEnv(func() Environment { return &failingEnv{} }, time.Second)
+	failing.Reset()
+	for i := 0; i < 3; i++ {
+		failing.Step(0)
+	}
+	if envErr(failing) == nil {
+		t.Errorf("Expected the watchdog to forward the environment's error")
+	}
+}
+
+// stuckEnv is a countEnv whose Reset blocks until released.
+type stuckEnv struct {
+	countEnv
+	release chan struct{}
+}
+
+func (e *stuckEnv) Reset() []float64 {
+	<-e.release
+	return e.countEnv.Reset()
+}
+
+func TestWatchdogEnvFaults(t *testing.T) {
+	// A panic inside the watchdog reaches the VecEnv and is recovered there
+	vec := NewVecEnv(1, func() Environment {
+		return NewWatchdogEnv(func() Environment { return &panicEnv{} }, time.Second)
+	})
+	vec.Reset()
+	for i := 0; i < 3; i++ {
+		vec.Step([]int{0})
+	}
+	if panics := vec.Panics(); len(panics) != 1 || panics[0].Value != "simulator fault" {
+		t.Errorf("Expected the panic to be recovered by the VecEnv, got %v", panics)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	created := 0
+	env := NewWatchdogEnv(func() Environment {
+		created++
+		return &stuckEnv{release: release}
+	}, time.Millisecond)
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected Reset to give up")
+		}
+		if created != maxWorkerRestarts+2 {
+			t.Errorf("Expected %d environments, got %d", maxWorkerRestarts+2, created)
+		}
+	}()
+	env.Reset()
+}
+
+func TestSubsystemSeeds(t *testing.T) {
+	cfg := DefaultConfig(2, 2)
+	cfg.Seed = 7
+	a, b := NewDQNFromConfig(cfg), NewDQNFromConfig(cfg)
+	if a.qNetwork.w1.At(0, 0) != b.qNetwork.w1.At(0, 0) {
+		t.Errorf("Expected identical initial weights for the same seed")
+	}
+
+	// A different replay seed must not change the initial weights
+	cfg.ReplaySeed = 99
+	c := NewDQNFromConfig(cfg)
+	if c.qNetwork.w1.At(0, 0) != a.qNetwork.w1.At(0, 0) {
+		t.Errorf("Expected the replay seed not to affect initialization")
+	}
+	for i := 0; i < 10; i++ {
+		exp := Experience{State: []float64{float64(i), 0}, NextState: []float64{0, 0}}
+		a.replayBuffer.Add(exp)
+		b.replayBuffer.Add(exp)
+	}
+	if a.replayBuffer.Sample(5)[3].State[0] != b.replayBuffer.Sample(5)[3].State[0] {
+		t.Errorf("Expected identical replay samples for the same seed")
+	}
+
+	envA, envB := envs.NewCartPole(), envs.NewCartPole()
+	NewTrainer(a, envA, WithSeed(7))
+	NewTrainer(b, envB, WithSeed(7))
+	if envA.Reset()[0] != envB.Reset()[0] {
+		t.Errorf("Expected identically seeded environments")
+	}
+
+	// Dropout draws from its own stream, leaving exploration unchanged
+	dropout := cfg
+	dropout.Dropout = 0.5
+	d, e := NewDQNFromConfig(cfg), NewDQNFromConfig(dropout)
+	state := []float64{1, 0}
+	for i := 0; i < 5; i++ {
+		d.Train(state, state, 0, 1, false)
+		e.Train(state, state, 0, 1, false)
+	}
+	if d.rng.Int63() != e.rng.Int63() {
+		t.Errorf("Expected dropout not to consume the exploration stream")
+	}
+
+	// An unseeded RND is seeded from the trainer's seed
+	rndA, rndB := NewRND(2, 8, 4, 0.05, 1, 1), NewRND(2, 8, 4, 0.05, 1, 1)
+	NewTrainer(a, envs.NewCartPole(), WithSeed(7), WithCuriosity(rndA, 1))
+	NewTrainer(b, envs.NewCartPole(), WithSeed(7), WithCuriosity(rndB, 1))
+	if rndA.Error(state) != rndB.Error(state) {
+		t.Errorf("Expected identically seeded RND networks")
+	}
+}
+
+func TestEvaluationIsolation(t *testing.T) {
+	run := func(evalEpisodes int) []EpisodeStats {
+		env := envs.NewCartPole()
+		cfg := DefaultConfig(4, 2)
+		cfg.Seed = 5
+		trainer := NewTrainer(NewDQNFromConfig(cfg), env, WithSeed(5), WithEpisodes(6), WithEvalEvery(2), WithEvalEpisodes(evalEpisodes))
+		history, err := trainer.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return history
+	}
+	a, b := run(1), run(4)
+	for i := range a {
+		if a[i].Return != b[i].Return || a[i].Steps != b[i].Steps {
+			t.Fatalf("Expected training not to depend on the number of evaluation episodes, got %+v and %+v", a[i], b[i])
+		}
+	}
+
+	// Without a seed, the trainer seeds the environments from the agent's random seed
+	agent := NewDQNFromConfig(DefaultConfig(4, 2))
+	trainer := NewTrainer(agent, envs.NewCartPole())
+	if agent.rngSource.seed == 0 || trainer.cfg.Seed != agent.rngSource.seed {
+		t.Errorf("Expected the trainer to use the agent's seed %d, got %d", agent.rngSource.seed, trainer.cfg.Seed)
+	}
+}
+
+func TestRunManifest(t *testing.T) {
+	dir := t.TempDir()
+	env := envs.NewGridWorld(2, 2)
+	trainer := NewTrainer(NewDQNForEnv(env), env, WithEpisodes(2), WithCheckpointing(dir, 1, 1))
+	if _, err := trainer.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "manifest-00000000.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m RunManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.GoVersion == "" || m.GOMAXPROCS <= 0 || m.Config.Episodes != 2 {
+		t.Errorf("Unexpected manifest %+v", m)
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	env := envs.NewGridWorld(2, 2)
+	trainer := NewTrainer(NewDQNForEnv(env), env, WithEpisodes(10))
+	estimate, err := trainer.EstimateCost(context.Background(), 2, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if estimate.CalibrationEpisodes != 2 || estimate.RemainingEpisodes != 8 || len(trainer.History()) != 2 {
+		t.Errorf("Unexpected estimate %+v", estimate)
+	}
+	if estimate.StepsPerSecond <= 0 || estimate.ProjectedDuration <= 0 || estimate.EnergyKWh <= 0 {
+		t.Errorf("Expected positive projections, got %+v", estimate)
+	}
+}
+
+func TestEvaluateCheckpoints(t *testing.T) {
+	dir := t.TempDir()
+	env := envs.NewGridWorld(2, 2)
+	pipeline := NewPipeline(LagStep(1))
+	cfg := DefaultConfig(4, 4)
+	trainer := NewTrainer(NewDQNFromConfig(cfg), env, WithEpisodes(4), WithCheckpointing(dir, 2, 0), WithPipeline(pipeline))
+	if _, err := trainer.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	evaluations, baselines, err := EvaluateCheckpoints(dir, env, 3, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if baselines["random"].Episodes != 3 {
+		t.Errorf("Expected the random baseline, got %+v", baselines)
+	}
+	if len(evaluations) != 2 {
+		t.Fatalf("Expected 2 evaluations, got %d", len(evaluations))
+	}
+	if evaluations[0].Episodes != 2 || evaluations[1].Episodes != 4 || evaluations[0].Steps >= evaluations[1].Steps {
+		t.Errorf("Unexpected checkpoint order %+v", evaluations)
+	}
+	for _, e := range evaluations {
+		if e.Report.Episodes != 3 {
+			t.Errorf("Expected 3 evaluation episodes, got %d", e.Report.Episodes)
+		}
+	}
+	best := BestCheckpoint(evaluations)
+	if best.Report.Mean < evaluations[0].Report.Mean || best.Report.Mean < evaluations[1].Report.Mean {
+		t.Errorf("Expected the best checkpoint, got %+v", best)
+	}
+
+	again, _, err := EvaluateCheckpoints(dir, env, 3, 42)
+	if err != nil || again[1].Report.Mean != evaluations[1].Report.Mean {
+		t.Errorf("Expected seeded evaluations to repeat, got %v and %v", again[1].Report.Mean, evaluations[1].Report.Mean)
+	}
+	if _, _, err := EvaluateCheckpoints(t.TempDir(), env, 3, 42); err == nil {
+		t.Error("Expected an error for a directory without checkpoints")
+	}
+}
+
+func TestCalibration(t *testing.T) {
+	points := []CalibrationPoint{{1, 0}, {2, 2}, {3, 2}, {4, 4}}
+	report := NewCalibrationReport(points, 2)
+	if report.MAE != 0.5 || report.Bias != 0.5 || report.RMSE != math.Sqrt(0.5) {
+		t.Errorf("Unexpected metrics %+v", report)
+	}
+	if len(report.Bins) != 2 || report.Bins[0] != (CalibrationBin{1.5, 1, 2}) || report.Bins[1] != (CalibrationBin{3.5, 3, 2}) {
+		t.Errorf("Unexpected bins %+v", report.Bins)
+	}
+
+	// One point per step of every episode, with rewards 1..4 and gamma 0
+	cfg := DefaultConfig(2, 1)
+	cfg.Gamma = 0
+	agent := NewDQNFromConfig(cfg)
+	report = Calibrate(agent.Policy().WithPipeline(NewPipeline(LagStep(1))), &countEnv{}, 2, 4, cfg.Gamma, 1)
+	if len(report.Points) != 8 || len(report.Bins) != 4 {
+		t.Fatalf("Expected 8 points in 4 bins, got %d in %d", len(report.Points), len(report.Bins))
+	}
+	for i, p := range report.Points {
+		if want := float64(i%4 + 1); p.Realized != want {
+			t.Errorf("Expected realized return %v, got %v", want, p.Realized)
+		}
+	}
+}
+
+func TestActionLimiter(t *testing.T) {
+	l := NewActionLimiter(2, ActionLimit{Action: 0, Max: 2, Window: 3})
+	for i, want := range []bool{true, true, false, true, true} {
+		mask := l.Mask()
+		if mask[0] != want || !mask[1] {
+			t.Errorf("Step %d: expected action 0 allowed=%v, got mask %v", i, want, mask)
+		}
+		action := 0
+		if !mask[0] {
+			action = 1
+		}
+		l.Record(action)
+	}
+	l.Reset()
+	if !l.Mask()[0] {
+		t.Error("Expected Reset to clear the action history")
+	}
+
+	// Both actions at their limit: the one exceeding it least stays allowed
+	l = NewActionLimiter(2, ActionLimit{Action: 0, Max: 1, Window: 3}, ActionLimit{Action: 1, Max: 1, Window: 3})
+	l.Record(0)
+	l.Record(1)
+	if mask := l.Mask(); !mask[0] || !mask[1] {
+		t.Errorf("Expected both actions allowed when every action is at its limit, got %v", mask)
+	}
+	l.Record(1)
+	if mask := l.Mask(); !mask[0] || mask[1] {
+		t.Errorf("Expected only action 0 allowed, got %v", mask)
+	}
+}
+
+// actionLogEnv records the actions taken in episodes of ten steps.
+type actionLogEnv struct{ actions []int }
+
+func (e *actionLogEnv) Reset() []float64 { e.actions = e.actions[:0]; return []float64{0} }
+func (e *actionLogEnv) StateSize() int   { return 1 }
+func (e *actionLogEnv) NumActions() int  { return 2 }
+
+func (e *actionLogEnv) Step(action int) ([]float64, float64, bool) {
+	e.actions = append(e.actions, action)
+	return []float64{float64(len(e.actions))}, float64(action), len(e.actions) == 10
+}
+
+func TestTrainerActionLimits(t *testing.T) {
+	env := &actionLogEnv{}
+	cfg := DefaultConfig(1, 2)
+	cfg.Epsilon = 1
+	agent := NewDQNFromConfig(cfg)
+	trainer := NewTrainer(agent, env, WithEpisodes(1), WithActionLimit(1, 1, 4))
+	if _, err := trainer.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	checkWindows := func() {
+		t.Helper()
+		for i := range env.actions {
+			count := 0
+			for _, a := range env.actions[max(0, i-3) : i+1] {
+				if a == 1 {
+					count++
+				}
+			}
+			if count > 1 {
+				t.Fatalf("Action 1 chosen %d times in a window of 4: %v", count, env.actions)
+			}
+		}
+	}
+	checkWindows()
+
+	buffer := agent.replayBuffer.Snapshot()
+	for i, exp := range buffer {
+		if exp.Done != (exp.NextMask == nil) {
+			t.Errorf("Experience %d: unexpected next mask %v", i, exp.NextMask)
+		}
+		if exp.Action == 1 && exp.NextMask != nil && exp.NextMask[1] {
+			t.Errorf("Experience %d: expected action 1 masked after using it", i)
+		}
+	}
+
+	// The trainer's policy keeps the limits through saving and loading
+	agent.qNetwork.b2.SetVec(1, 100)
+	var buf bytes.Buffer
+	if err := trainer.policy().Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := LoadPolicy(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.ActionLimits()) != 1 {
+		t.Errorf("Expected the saved action limit, got %v", policy.ActionLimits())
+	}
+	EvaluatePolicy(policy, env, 1)
+	checkWindows()
+}
+
+func TestNStepAccumulator(t *testing.T) {
+	a := NewNStepAccumulator(3, 1)
+	env := &countEnv{}
+	var got []Experience
+	for episode := 0; episode < 2; episode++ {
+		state := env.Reset()
+		for done := false; !done; {
+			nextState, reward, stepDone := env.Step(0)
+			got = append(got, a.Add(Experience{State: state, NextState: nextState, Reward: int(reward), Done: stepDone})...)
+			state, done = nextState, stepDone
+		}
+	}
+
+	// Rewards 1..4: one full 3-step experience, then the flushed tail
+	want := []Experience{
+		{State: []float64{0}, NextState: []float64{3}, Reward: 6},
+		{State: []float64{1}, NextState: []float64{4}, Reward: 9, Done: true},
+		{State: []float64{2}, NextState: []float64{4}, Reward: 7, Done: true},
+		{State: []float64{3}, NextState: []float64{4}, Reward: 4, Done: true},
+	}
+	if len(got) != 2*len(want) {
+		t.Fatalf("Expected %d experiences, got %d", 2*len(want), len(got))
+	}
+	for i, exp := range got {
+		w := want[i%len(want)]
+		if exp.State[0] != w.State[0] || exp.NextState[0] != w.NextState[0] || exp.Reward != w.Reward || exp.Done != w.Done {
+			t.Errorf("Experience %d: expected %+v, got %+v", i, w, exp)
+		}
+	}
+
+	// A truncated episode flushes only full 3-step experiences
+	a.Add(Experience{State: []float64{0}, NextState: []float64{1}, Reward: 1})
+	a.Add(Experience{State: []float64{1}, NextState: []float64{2}, Reward: 1})
+	flushed := a.Add(Experience{State: []float64{2}, NextState: []float64{3}, Reward: 1, Done: true, Truncated: true})
+	if len(flushed) != 1 || flushed[0].Reward != 3 || !flushed[0].Truncated {
+		t.Errorf("Expected a single truncated 3-step experience, got %+v", flushed)
+	}
+
+	// Transitions of an unfinished episode are dropped on Reset
+	a.Add(Experience{State: []float64{9}, NextState: []float64{9}, Reward: 100})
+	a.Reset()
+	for _, exp := range a.Add(Experience{State: []float64{0}, NextState: []float64{1}, Reward: 1, Done: true}) {
+		if exp.Reward != 1 {
+			t.Errorf("Expected the pending transition to be dropped, got %+v", exp)
+		}
+	}
+}
+
+func TestTrainerNSteps(t *testing.T) {
+	cfg := DefaultConfig(1, 1)
+	cfg.Gamma = 0.5
+	cfg.NSteps = 2
+	agent := NewDQNFromConfig(cfg)
+	if agent.discount != 0.25 {
+		t.Errorf("Expected bootstrap discount 0.25, got %v", agent.discount)
+	}
+	trainer := NewTrainer(agent, &countEnv{}, WithEpisodes(2))
+	if _, err := trainer.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewards 1..4 discounted by 0.5 over two steps, with the last step flushed
+	want := []int{2, 4, 5, 4}
+	buffer := agent.replayBuffer.Snapshot()
+	if len(buffer) != 2*len(want) {
+		t.Fatalf("Expected %d experiences, got %d", 2*len(want), len(buffer))
+	}
+	for i, exp := range buffer {
+		if exp.Reward != want[i%len(want)] {
+			t.Errorf("Experience %d: expected reward %d, got %d", i, want[i%len(want)], exp.Reward)
+		}
+	}
+}
+
+func TestLagStepEpisodeBoundary(t *testing.T) {
+	env := NewPipeline(LagStep(2)).Wrap(&countEnv{})
+	for episode := 0; episode < 2; episode++ {
+		if state := env.Reset(); state[1] != 0 || state[2] != 0 {
+			t.Errorf("Episode %d: expected zero-filled lags after Reset, got %v", episode, state)
+		}
+		state, _, _ := env.Step(0)
+		if want := []float64{1, 0, 0}; state[0] != want[0] || state[1] != want[1] || state[2] != want[2] {
+			t.Errorf("Episode %d: expected %v, got %v", episode, want, state)
+		}
+		env.Step(0)
+		env.Step(0)
+	}
+}
+
+func TestBaselines(t *testing.T) {
+	RegisterBaseline("left", func(Environment, int64) func([]float64) int {
+		return func([]float64) int { return 0 }
+	})
+	t.Cleanup(func() { delete(baselines, "left") })
+	if names := Baselines(); len(names) != 2 || names[0] != "left" || names[1] != "random" {
+		t.Errorf("Expected sorted baseline names, got %v", names)
+	}
+
+	env := envs.NewCartPole()
+	first, err := EvaluateBaselines(env, 5, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := EvaluateBaselines(env, 5, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"left", "random"} {
+		if first[name].Episodes != 5 || first[name].Mean != second[name].Mean {
+			t.Errorf("Expected reproducible %s baseline, got %+v and %+v", name, first[name], second[name])
+		}
+	}
+	if _, err := EvaluateBaselines(env, 1, 42, "missing"); err == nil {
+		t.Error("Expected an error for an unknown baseline")
+	}
+
+	_, report, err := Solve(envs.NewGridWorld(2, 2), WithEpisodes(2), WithEvalEpisodes(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Baselines["random"].Episodes != 3 {
+		t.Errorf("Expected the random baseline in the report, got %+v", report.Baselines)
+	}
+
+	// With early stopping the report evaluates the returned best policy
+	cartPole := envs.NewCartPole()
+	policy, report, err := Solve(cartPole, WithEpisodes(6), WithEvalEvery(2), WithEvalEpisodes(3), WithSeed(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig(4, 2)
+	cfg.Seed = 3
+	returns := EvaluatePolicySeeded(policy, cartPole, 3, cfg.subsystemSeed(0, evalStream))
+	if mean := stat.Mean(returns, nil); mean != report.Evaluation.Mean {
+		t.Errorf("Expected the report to evaluate the returned policy, got %f and %f", report.Evaluation.Mean, mean)
+	}
+}
+
+// paramsEnv is a countEnv with a noise and a gain parameter, recording the
+// parameters in effect at every Reset.
+type paramsEnv struct {
+	countEnv
+	noise, gain float64
+	resets      [][2]float64
+}
+
+func (e *paramsEnv) SetParam(name string, value float64) error {
+	switch name {
+	case "noise":
+		e.noise = value
+	case "gain":
+		e.gain = value
+	default:
+		return errors.New("unknown parameter")
+	}
+	return nil
+}
+
+func (e *paramsEnv) Reset() []float64 {
+	e.resets = append(e.resets, [2]float64{e.noise, e.gain})
+	return e.countEnv.Reset()
+}
+
+func TestEnvParams(t *testing.T) {
+	env := &paramsEnv{}
+	trainer := NewTrainer(NewDQNForEnv(env), env, WithEpisodes(2), WithEvalEvery(1), WithEvalEpisodes(1),
+		WithTrainParams(EnvParams{Values: map[string]float64{"noise": 1}, Ranges: map[string]ParamRange{"gain": {2, 3}}}),
+		WithEvalParams(EnvParams{Values: map[string]float64{"noise": 0, "gain": 1}}))
+	if _, err := trainer.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every training episode is followed by an evaluation episode of the
+	// policy and one of the random baseline
+	if len(env.resets) != 6 {
+		t.Fatalf("Expected 6 episodes, got %d", len(env.resets))
+	}
+	for i, params := range env.resets {
+		if i%3 == 0 && (params[0] != 1 || params[1] < 2 || params[1] > 3) {
+			t.Errorf("Episode %d: expected training parameters, got %v", i, params)
+		}
+		if i%3 != 0 && params != [2]float64{0, 1} {
+			t.Errorf("Episode %d: expected evaluation parameters, got %v", i, params)
+		}
+	}
+
+	_, _, err := Solve(&paramsEnv{}, WithEpisodes(1), WithTrainParams(EnvParams{Values: map[string]float64{"missing": 1}}))
+	if err == nil {
+		t.Error("Expected an error for an unknown parameter")
+	}
+}
+
+func TestTrainerNext(t *testing.T) {
+	trainer := NewTrainer(NewDQNForEnv(&countEnv{}), &countEnv{}, WithEpisodes(3))
+	for i := 0; i < 3; i++ {
+		stats, err := trainer.Next(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Episode != i || stats.Return != 10 || len(trainer.History()) != i+1 {
+			t.Errorf("Unexpected episode %+v after %d calls", stats, i+1)
+		}
+	}
+	if _, err := trainer.Next(context.Background()); !errors.Is(err, ErrTrainingDone) {
+		t.Errorf("Expected ErrTrainingDone, got %v", err)
+	}
+
+	vec := NewVecEnv(2, func() Environment { return &countEnv{} })
+	trainer = NewVecTrainer(NewDQNForEnv(&countEnv{}), vec, WithEpisodes(4))
+	for i := 0; i < 4; i++ {
+		stats, err := trainer.Next(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Episode != i {
+			t.Errorf("Expected episode %d, got %d", i, stats.Episode)
+		}
+	}
+}
+
+// panicEnv is a countEnv that panics on its third step.
+type panicEnv struct{ countEnv }
+
+func (e *panicEnv) Step(action int) ([]float64, float64, bool) {
+	if e.steps == 2 {
+		panic("simulator fault")
+	}
+	return e.countEnv.Step(action)
+}
+
+func TestVecEnvPanicRecovery(t *testing.T) {
+	created := 0
+	vec := NewVecEnv(2, func() Environment {
+		created++
+		if created == 1 {
+			return &panicEnv{}
+		}
+		return &countEnv{}
+	})
+	var logged []string
+	vec.OnPanic(func(p WorkerPanic) { logged = append(logged, p.String()) })
+
+	vec.Reset()
+	vec.Step([]int{0, 0})
+	vec.Step([]int{0, 0})
+	step := vec.Step([]int{0, 0})
+	if !step.Dones[0] || !step.Interrupted[0] || step.Rewards[0] != 0 || step.NextStates[0][0] != 2 || step.States[0][0] != 0 {
+		t.Errorf("Expected the faulty episode to end at the last state, got %+v", step)
+	}
+	if step.Dones[1] || step.Rewards[1] != 3 {
+		t.Errorf("Expected the healthy worker to continue, got %+v", step)
+	}
+
+	panics := vec.Panics()
+	if len(panics) != 1 || len(logged) != 1 || created != 3 {
+		t.Fatalf("Expected one panic and a replacement, got %d panics, %d logged, %d created", len(panics), len(logged), created)
+	}
+	if p := panics[0]; p.Worker != 0 || p.Op != "step" || p.Action != 0 || p.State[0] != 2 || p.Value != "simulator fault" || p.Stack == "" {
+		t.Errorf("Unexpected panic record %+v", p)
+	}
+
+	// The replacement keeps running
+	for i := 0; i < 4; i++ {
+		vec.Step([]int{0, 0})
+	}
+	if len(vec.Panics()) != 1 {
+		t.Errorf("Expected no further panics, got %v", vec.Panics())
+	}
+}
+
+// failingEnv is a countEnv whose simulator is lost on the third step, which
+// ends the episode and is reported by Err, as with remote environments.
+type failingEnv struct {
+	countEnv
+	err error
+}
+
+func (e *failingEnv) Step(action int) ([]float64, float64, bool) {
+	if e.steps == 2 {
+		e.err = errors.New("simulator lost")
+		return []float64{2}, 0, true
+	}
+	return e.countEnv.Step(action)
+}
+
+func (e *failingEnv) Err() error { return e.err }
+
+// failingMatchEnv is a matchEnv whose simulator is lost on the third step.
+type failingMatchEnv struct {
+	matchEnv
+	err error
+}
+
+func (e *failingMatchEnv) Step(actions []int) ([][]float64, []float64, bool) {
+	if e.steps == 2 {
+		e.err = errors.New("simulator lost")
+		return [][]float64{{0}, {1}}, []float64{0, 0}, true
+	}
+	return e.matchEnv.Step(actions)
+}
+
+func (e *failingMatchEnv) Err() error { return e.err }
+
+func TestEnvErrorsStopTraining(t *testing.T) {
+	agent := NewDQNForEnv(&countEnv{})
+	trainer := NewTrainer(agent, &failingEnv{}, WithEpisodes(5), WithPipeline(NewPipeline(ClipStep(-10, 10))))
+	if _, err := trainer.Run(context.Background()); err == nil || err.Error() != "simulator lost" {
+		t.Errorf("Expected the environment error, got %v", err)
+	}
+	if n := agent.replayBuffer.Len(); n != 2 {
+		t.Errorf("Expected only the 2 successful steps to be stored, got %d", n)
+	}
+
+	agent = NewDQNForEnv(&countEnv{})
+	vec := NewVecEnv(2, func() Environment { return &failingEnv{} })
+	if _, err := NewVecTrainer(agent, vec, WithEpisodes(5)).Run(context.Background()); err == nil {
+		t.Errorf("Expected the vectorized run to stop on the environment error")
+	}
+	if n := agent.replayBuffer.Len(); n != 4 {
+		t.Errorf("Expected only the 4 successful steps to be stored, got %d", n)
+	}
+
+	multi := NewMultiAgentTrainer(&failingMatchEnv{}, false, WithEpisodes(5))
+	if _, err := multi.Run(context.Background()); err == nil {
+		t.Errorf("Expected the multi-agent run to stop on the environment error")
+	}
+	if n := multi.Agents()[0].replayBuffer.Len(); n != 2 {
+		t.Errorf("Expected only the 2 successful steps to be stored, got %d", n)
+	}
+}
+
+// interruptingEnv is a countEnv that interrupts every episode on its third step.
+type interruptingEnv struct {
+	countEnv
+	interrupted bool
+}
+
+func (e *interruptingEnv) Step(action int) ([]float64, float64, bool) {
+	e.interrupted = e.steps == 2
+	if e.interrupted {
+		return []float64{2}, 0, true
+	}
+	return e.countEnv.Step(action)
+}
+
+func (e *interruptingEnv) Interrupted() bool { return e.interrupted }
+
+func TestInterruptedSteps(t *testing.T) {
+	agent := NewDQNForEnv(&countEnv{})
+	history, err := NewTrainer(agent, &interruptingEnv{}, WithEpisodes(2)).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Steps != 2 || agent.replayBuffer.Len() != 4 {
+		t.Errorf("Expected interrupted steps not to be stored, got %d transitions", agent.replayBuffer.Len())
+	}
+	for _, exp := range agent.ReplaySnapshot() {
+		if exp.Done {
+			t.Errorf("Expected no terminal transitions, got %+v", exp)
+		}
+	}
+
+	agent = NewDQNForEnv(&countEnv{})
+	vec := NewVecEnv(2, func() Environment { return &interruptingEnv{} })
+	if _, err := NewVecTrainer(agent, vec, WithEpisodes(4)).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range agent.ReplaySnapshot() {
+		if exp.Done {
+			t.Errorf("Expected no terminal transitions, got %+v", exp)
+		}
+	}
+}
+
+// truncatingEnv is a countEnv whose episodes end by truncation.
+type truncatingEnv struct{ countEnv }
+
+func (e *truncatingEnv) Truncated() bool { return e.steps == 4 }
+
+func TestTruncation(t *testing.T) {
+	agent := NewDQN(1, 4, 1, 10, 0.5, 0, 0.01, ReLU)
+	next := []float64{1}
+	value := agent.qNetwork.Predict(next)[0]
+	report, _ := agent.Observe(Experience{State: []float64{0}, NextState: next, Reward: 1, Done: true, Truncated: true})
+	if math.Abs(report.Target-(1+0.5*value)) > 1e-9 {
+		t.Errorf("Expected a truncated target to bootstrap to %f, got %f", 1+0.5*value, report.Target)
+	}
+	if report, _ := agent.Observe(Experience{State: []float64{0}, NextState: next, Reward: 1, Done: true}); report.Target != 1 {
+		t.Errorf("Expected a terminal target of 1, got %f", report.Target)
+	}
+
+	agent = NewDQNForEnv(&countEnv{})
+	if _, err := NewTrainer(agent, &truncatingEnv{}, WithEpisodes(1)).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := agent.ReplaySnapshot()
+	if last := snapshot[len(snapshot)-1]; !last.Done || !last.Truncated || snapshot[0].Truncated {
+		t.Errorf("Expected only the last transition to be truncated, got %+v", snapshot)
+	}
+
+	agent = NewDQNForEnv(&countEnv{})
+	vec := NewVecEnv(2, func() Environment { return &truncatingEnv{} })
+	if _, err := NewVecTrainer(agent, vec, WithEpisodes(2)).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range agent.ReplaySnapshot() {
+		if exp.Done != exp.Truncated {
+			t.Errorf("Expected every episode end to be truncated, got %+v", exp)
+		}
+	}
+}
+
+// brokenEnv is a countEnv whose Reset always panics.
+type brokenEnv struct{ countEnv }
+
+func (e *brokenEnv) Reset() []float64 {
+	panic("cannot start")
+}
+
+func TestVecEnvResetGivesUp(t *testing.T) {
+	vec := NewVecEnv(2, func() Environment { return &brokenEnv{} })
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected Reset to panic on the calling goroutine")
+		}
+		if n := len(vec.Panics()); n != 2*(maxWorkerRestarts+1) {
+			t.Errorf("Expected %d recorded panics, got %d", 2*(maxWorkerRestarts+1), n)
+		}
+	}()
+	vec.Reset()
+}
+
+func TestExploration(t *testing.T) {
+	inf := math.Inf(-1)
+	qValues := []float64{1, inf, 0}
+
+	eps := NewEpsilonGreedy(1, 0, 10, 1)
+	if eps.Epsilon(5) != 0.5 || eps.Epsilon(20) != 0 {
+		t.Errorf("Unexpected epsilon schedule %v, %v", eps.Epsilon(5), eps.Epsilon(20))
+	}
+	soft := NewSoftmax(100, 0, 10, 1)
+	counts := map[string][]int{"epsilon": make([]int, 3), "softmax": make([]int, 3)}
+	for i := 0; i < 1000; i++ {
+		counts["epsilon"][eps.SelectAction(qValues, 0)]++
+		counts["softmax"][soft.SelectAction(qValues, 0)]++
+	}
+	for name, c := range counts {
+		if c[1] != 0 || c[0] == 0 || c[2] == 0 {
+			t.Errorf("%s: expected only legal actions, all explored, got %v", name, c)
+		}
+	}
+	if a := eps.SelectAction(qValues, 10); a != 0 {
+		t.Errorf("Expected the greedy action after decay, got %d", a)
+	}
+	if a := soft.SelectAction(qValues, 10); a != 0 {
+		t.Errorf("Expected the greedy action at zero temperature, got %d", a)
+	}
+
+	ucb := NewUCB(1)
+	if first, second := ucb.SelectAction(qValues, 0), ucb.SelectAction(qValues, 1); first != 0 || second != 2 {
+		t.Errorf("Expected untried legal actions first, got %d and %d", first, second)
+	}
+	if a := ucb.SelectAction(qValues, 2); a != 0 {
+		t.Errorf("Expected the higher bound, got %d", a)
+	}
+
+	network := NewQNetwork(2, 4, 3, ReLU)
+	original := network.Clone()
+	noise := NewParameterNoise(0.5, 3, 1)
+	first := noise.Perturb(network, 0)
+	if noise.Perturb(network, 2) != first || noise.Perturb(network, 3) == first {
+		t.Error("Expected the perturbation to be resampled every 3 steps")
+	}
+	if mat.Equal(first.w1, network.w1) || !mat.Equal(network.w1, original.w1) {
+		t.Error("Expected a perturbed copy, leaving the network unchanged")
+	}
+	if noisy := NewNoisyNet(0.5, 1); mat.Equal(noisy.Perturb(network, 0).w2, network.w2) {
+		t.Error("Expected noisy weights")
+	}
+
+	env := &actionLogEnv{}
+	cfg := DefaultConfig(1, 2)
+	WithExploration(NewSoftmax(1, 0.1, 100, 1))(&cfg)
+	agent := NewDQNFromConfig(cfg)
+	trainer := NewTrainer(agent, env, WithEpisodes(1), WithActionLimit(1, 1, 4))
+	if _, err := trainer.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOrthogonalInitializer(t *testing.T) {
+	uniform := rand.New(rand.NewSource(1)).Float64
+	for _, dims := range [][2]int{{6, 3}, {3, 6}} {
+		w := mat.NewDense(dims[0], dims[1], nil)
+		b := mat.NewVecDense(dims[0], []float64{1, 1, 1, 1, 1, 1}[:dims[0]])
+		Orthogonal(2)(w, b, uniform)
+
+		var product mat.Dense
+		if dims[0] >= dims[1] {
+			product.Mul(w.T(), w)
+		} else {
+			product.Mul(w, w.T())
+		}
+		n, _ := product.Dims()
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				want := 0.0
+				if i == j {
+					want = 4
+				}
+				if math.Abs(product.At(i, j)-want) > 1e-9 {
+					t.Fatalf("%v: expected orthogonal rows or columns with gain 2, got %v", dims, mat.Formatted(&product))
+				}
+			}
+		}
+		if mat.Norm(b, 2) != 0 {
+			t.Errorf("Expected zero biases, got %v", b.RawVector().Data)
+		}
+	}
+
+	cfg := DefaultConfig(3, 2)
+	WithInitializer("orthogonal")(&cfg)
+	agent := NewDQNFromConfig(cfg)
+	var buf bytes.Buffer
+	if err := agent.qNetwork.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadQNetwork(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.initializer != "orthogonal" {
+		t.Errorf("Expected the initializer to be saved, got %q", loaded.initializer)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for an unknown initializer")
+		}
+	}()
+	WithInitializer("missing")(&cfg)
+	NewDQNFromConfig(cfg)
+}
+
+func TestProfile(t *testing.T) {
+	cfg := DefaultConfig(1, 1)
+	WithProfiling()(&cfg)
+	WithBatchSize(4)(&cfg)
+	WithAdam()(&cfg)
+	agent := NewDQNFromConfig(cfg)
+	if _, err := NewTrainer(agent, &countEnv{}, WithEpisodes(3)).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	report := agent.Profile()
+	if report.Updates != 12 || len(report.Phases) != numPhases || report.Total <= 0 {
+		t.Fatalf("Unexpected profile %+v", report)
+	}
+	calls := map[string]int{}
+	for i, p := range report.Phases {
+		calls[p.Phase] = p.Calls
+		if i > 0 && p.Total > report.Phases[i-1].Total {
+			t.Errorf("Expected phases sorted by time, got %+v", report.Phases)
+		}
+	}
+	// Batches of 4 experiences per update
+	want := map[string]int{"replay": 12, "sample": 12, "forward": 48, "target": 48, "backward": 48, "optimizer": 12}
+	for phase, n := range want {
+		if calls[phase] != n {
+			t.Errorf("Expected %d %s calls, got %d", n, phase, calls[phase])
+		}
+	}
+	if !strings.Contains(report.String(), "optimizer") {
+		t.Errorf("Expected every phase in the table, got\n%s", report)
+	}
+
+	if report := NewDQNForEnv(&countEnv{}).Profile(); report.Updates != 0 || report.Phases != nil {
+		t.Errorf("Expected an empty profile without profiling, got %+v", report)
+	}
+}
+
+func TestExperienceSchema(t *testing.T) {
+	// Version 1 records have no version field and no mask
+	legacy := `{"state":[0],"action":1,"reward":2.5,"next_state":[1],"done":false}` + "\n"
+	experiences, err := ReadExperiencesJSONL(strings.NewReader(legacy), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(experiences) != 1 || experiences[0].Reward != 5 || experiences[0].NextMask != nil {
+		t.Errorf("Unexpected legacy experience %+v", experiences)
+	}
+	future := `{"version":99,"state":[0],"action":1,"reward":1,"next_state":[1],"done":true}` + "\n"
+	if _, err := ReadExperiencesJSONL(strings.NewReader(future), 1); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("Expected an error for a newer schema, got %v", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteExperiencesJSONL(&buf, experiences); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"version":3`) {
+		t.Errorf("Expected the schema version in every record, got %s", buf.String())
+	}
+
+	experiences = []Experience{
+		{State: []float64{0}, NextState: []float64{1}, Action: 1, Reward: -3, NextMask: []bool{true, false}},
+		{State: []float64{1}, NextState: []float64{2}, Done: true, Truncated: true},
+	}
+	buf.Reset()
+	if err := WriteExperiences(&buf, experiences); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadExperiences(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 2 || loaded[0].Reward != -3 || loaded[0].NextMask[1] || !loaded[1].Done || !loaded[1].Truncated || loaded[1].NextMask != nil {
+		t.Errorf("Expected a round trip, got %+v", loaded)
+	}
+
+	for name, header := range map[string]experienceHeader{
+		"newer":  {Schema: experienceSchema, Version: 99, Count: 1},
+		"zero":   {Schema: experienceSchema, Version: 0, Count: 1},
+		"schema": {Schema: "other", Version: 1},
+		"count":  {Schema: experienceSchema, Version: 1, Count: -1},
+		"huge":   {Schema: experienceSchema, Version: 1, Count: math.MaxInt},
+	} {
+		buf.Reset()
+		enc := gob.NewEncoder(&buf)
+		enc.Encode(header)
+		enc.Encode(experienceRecord{State: []float64{0}})
+		if _, err := ReadExperiences(&buf); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestTrainConverges(t *testing.T) {
+	dqn := NewDQN(2, 8, 2, 10, 0.9, 0.1, 0.01, ReLU)
+	state := []float64{1, 0}
+	for i := 0; i < 500; i++ {
+		dqn.Train(state, state, 0, 1, true)
+	}
+	if q := dqn.qNetwork.Predict(state)[0]; q < 0.9 || q > 1.1 {
+		t.Errorf("Expected Q-value of the trained action near 1, got %f", q)
+	}
+}
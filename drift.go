@@ -0,0 +1,122 @@
+// drift.go
+package dqn
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// BufferDiff describes how the experience distribution changed between two
+// replay buffer snapshots A and B.
+type BufferDiff struct {
+	SizeA, SizeB int
+	// StateShift is the difference of the means of each state dimension
+	// (B minus A) in units of their pooled standard deviation.
+	StateShift []float64
+	// StateKS is the two-sample Kolmogorov-Smirnov statistic of each state
+	// dimension, from 0 for identical to 1 for disjoint distributions.
+	StateKS []float64
+	// ActionsA and ActionsB are the action frequencies of each snapshot and
+	// ActionDistance their total variation distance.
+	ActionsA, ActionsB []float64
+	ActionDistance     float64
+	// RewardMeanA and RewardMeanB are the mean rewards and RewardDistance the
+	// total variation distance between the reward distributions.
+	RewardMeanA, RewardMeanB float64
+	RewardDistance           float64
+}
+
+// DiffSnapshots compares two replay buffer snapshots, e.g. taken with
+// DQN.ReplaySnapshot at different phases of training.
+func DiffSnapshots(a, b []Experience, numActions int) BufferDiff {
+	diff := BufferDiff{SizeA: len(a), SizeB: len(b)}
+	if len(a) == 0 || len(b) == 0 {
+		return diff
+	}
+
+	for dim := range a[0].State {
+		colA, colB := stateColumn(a, dim), stateColumn(b, dim)
+		meanA, varA := stat.MeanVariance(colA, nil)
+		meanB, varB := stat.MeanVariance(colB, nil)
+		shift := 0.0
+		if pooled := math.Sqrt((varA + varB) / 2); pooled > 0 {
+			shift = (meanB - meanA) / pooled
+		}
+		diff.StateShift = append(diff.StateShift, shift)
+		diff.StateKS = append(diff.StateKS, ksStatistic(colA, colB))
+	}
+
+	diff.ActionsA = make([]float64, numActions)
+	diff.ActionsB = make([]float64, numActions)
+	for _, exp := range a {
+		diff.ActionsA[exp.Action] += 1 / float64(len(a))
+	}
+	for _, exp := range b {
+		diff.ActionsB[exp.Action] += 1 / float64(len(b))
+	}
+	for i := range diff.ActionsA {
+		diff.ActionDistance += math.Abs(diff.ActionsA[i]-diff.ActionsB[i]) / 2
+	}
+
+	rewardsA, rewardsB := map[int]float64{}, map[int]float64{}
+	for _, exp := range a {
+		rewardsA[exp.Reward] += 1 / float64(len(a))
+		diff.RewardMeanA += float64(exp.Reward) / float64(len(a))
+	}
+	for _, exp := range b {
+		rewardsB[exp.Reward] += 1 / float64(len(b))
+		diff.RewardMeanB += float64(exp.Reward) / float64(len(b))
+	}
+	for r, p := range rewardsA {
+		diff.RewardDistance += math.Abs(p-rewardsB[r]) / 2
+	}
+	for r, p := range rewardsB {
+		if _, ok := rewardsA[r]; !ok {
+			diff.RewardDistance += p / 2
+		}
+	}
+	return diff
+}
+
+// stateColumn returns the sorted values of one state dimension.
+func stateColumn(exps []Experience, dim int) []float64 {
+	col := make([]float64, len(exps))
+	for i, exp := range exps {
+		col[i] = exp.State[dim]
+	}
+	sort.Float64s(col)
+	return col
+}
+
+// ksStatistic returns the largest distance between the empirical CDFs of two sorted samples.
+func ksStatistic(a, b []float64) float64 {
+	var i, j int
+	var d float64
+	for i < len(a) && j < len(b) {
+		x := math.Min(a[i], b[j])
+		for i < len(a) && a[i] == x {
+			i++
+		}
+		for j < len(b) && b[j] == x {
+			j++
+		}
+		d = math.Max(d, math.Abs(float64(i)/float64(len(a))-float64(j)/float64(len(b))))
+	}
+	return d
+}
+
+// String formats the diff as a table.
+func (d BufferDiff) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Experiences: %d -> %d\n", d.SizeA, d.SizeB)
+	for i := range d.StateShift {
+		fmt.Fprintf(&b, "State[%d]: shift %+.2f  KS %.2f\n", i, d.StateShift[i], d.StateKS[i])
+	}
+	fmt.Fprintf(&b, "Actions: %.2f -> %.2f  TV %.2f\n", d.ActionsA, d.ActionsB, d.ActionDistance)
+	fmt.Fprintf(&b, "Reward: mean %.2f -> %.2f  TV %.2f\n", d.RewardMeanA, d.RewardMeanB, d.RewardDistance)
+	return b.String()
+}
@@ -0,0 +1,122 @@
+// dynamics.go
+package dqn
+
+// DynamicsModel predicts the next state reached by taking an action.
+type DynamicsModel interface {
+	Predict(state []float64, action int) []float64
+}
+
+// LinearDynamics is a DynamicsModel with one linear map per action,
+// next = W[action]*state + b[action], learned online by gradient descent.
+type LinearDynamics struct {
+	weights      [][][]float64
+	biases       [][]float64
+	learningRate float64
+}
+
+// NewLinearDynamics initializes a new LinearDynamics that predicts no change
+// until trained.
+func NewLinearDynamics(stateSize, numActions int, learningRate float64) *LinearDynamics {
+	m := &LinearDynamics{
+		weights:      make([][][]float64, numActions),
+		biases:       make([][]float64, numActions),
+		learningRate: learningRate,
+	}
+	for a := range m.weights {
+		m.weights[a] = make([][]float64, stateSize)
+		for i := range m.weights[a] {
+			m.weights[a][i] = make([]float64, stateSize)
+			m.weights[a][i][i] = 1
+		}
+		m.biases[a] = make([]float64, stateSize)
+	}
+	return m
+}
+
+// Predict returns the predicted next state.
+func (m *LinearDynamics) Predict(state []float64, action int) []float64 {
+	next := make([]float64, len(state))
+	for i, row := range m.weights[action] {
+		next[i] = m.biases[action][i]
+		for j, w := range row {
+			next[i] += w * state[j]
+		}
+	}
+	return next
+}
+
+// Update takes a gradient step on the squared prediction error of a
+// transition and returns the error before the step.
+func (m *LinearDynamics) Update(state []float64, action int, nextState []float64) float64 {
+	predicted := m.Predict(state, action)
+	var loss float64
+	for i, row := range m.weights[action] {
+		diff := predicted[i] - nextState[i]
+		loss += diff * diff
+		for j := range row {
+			row[j] -= m.learningRate * diff * state[j]
+		}
+		m.biases[action][i] -= m.learningRate * diff
+	}
+	return loss / float64(len(state))
+}
+
+// Fit trains the model on experiences for the given number of epochs and
+// returns the mean error of the last epoch.
+func (m *LinearDynamics) Fit(experiences []Experience, epochs int) float64 {
+	var loss float64
+	for epoch := 0; epoch < epochs; epoch++ {
+		loss = 0
+		for _, exp := range experiences {
+			loss += m.Update(exp.State, exp.Action, exp.NextState)
+		}
+	}
+	if len(experiences) == 0 {
+		return 0
+	}
+	return loss / float64(len(experiences))
+}
+
+// DelayedActor acts under actuation latency: an action only takes effect
+// after the actions issued before it, so the actor rolls the observed state
+// forward through a dynamics model over the pending actions and acts on the
+// state expected when its action is applied.
+type DelayedActor struct {
+	policy  *Policy
+	model   DynamicsModel
+	delay   int
+	pending []int
+}
+
+// NewDelayedActor initializes a new DelayedActor for an actuation delay of
+// the given number of steps.
+func NewDelayedActor(policy *Policy, model DynamicsModel, delay int) *DelayedActor {
+	return &DelayedActor{policy: policy, model: model, delay: delay}
+}
+
+// Act returns the action for the state expected after the pending actions
+// and records it as pending.
+func (a *DelayedActor) Act(state []float64) int {
+	action := a.policy.Act(a.Expected(state))
+	if a.delay > 0 {
+		a.pending = append(a.pending, action)
+		if len(a.pending) > a.delay {
+			a.pending = a.pending[1:]
+		}
+	}
+	return action
+}
+
+// Expected returns the state expected once the pending actions have been applied.
+func (a *DelayedActor) Expected(state []float64) []float64 {
+	for _, action := range a.pending {
+		state = a.model.Predict(state, action)
+	}
+	return state
+}
+
+// Reset clears the pending actions and the policy's episode state.
+func (a *DelayedActor) Reset() {
+	a.pending = nil
+	a.policy.Reset()
+}
@@ -0,0 +1,78 @@
+// earlystop.go
+package dqn
+
+// EvalResult is the outcome of a periodic evaluation during training.
+type EvalResult struct {
+	Episode int
+	Mean    float64
+	// Baselines holds the mean return of every registered baseline on the
+	// same episodes.
+	Baselines map[string]float64
+}
+
+// evaluate runs a periodic evaluation, keeps the best policy seen and
+// reports whether a stopping criterion is met.
+//
+// Every evaluation runs the same episodes, seeded from the evaluation
+// stream. The training environment is then reseeded from the environment
+// stream, so that training does not depend on the number of evaluation
+// episodes.
+func (t *Trainer) evaluate() bool {
+	env := t.rawEnv()
+	policy := t.policy()
+	t.setEvaluating(true)
+	seed := t.cfg.subsystemSeed(0, evalStream)
+	returns := EvaluatePolicySeeded(policy, env, t.cfg.EvalEpisodes, seed)
+	// Only registered baselines are evaluated, which cannot fail
+	baselines, _ := EvaluateBaselines(env, t.cfg.EvalEpisodes, seed)
+	t.setEvaluating(false)
+	seedEnv(env, t.cfg.subsystemSeed(t.cfg.EnvSeed, envStream)+int64(len(t.evaluations)+1))
+
+	result := EvalResult{Episode: len(t.history), Baselines: make(map[string]float64, len(baselines))}
+	for _, r := range returns {
+		result.Mean += r
+	}
+	result.Mean /= float64(len(returns))
+	for name, report := range baselines {
+		result.Baselines[name] = report.Mean
+	}
+	t.evaluations = append(t.evaluations, result)
+
+	if t.best == nil || result.Mean > t.bestScore {
+		t.best = policy
+		t.bestScore = result.Mean
+		t.sinceBest = 0
+	} else {
+		t.sinceBest++
+	}
+
+	if t.cfg.StopConsecutive > 0 && result.Mean >= t.cfg.StopThreshold {
+		t.aboveThreshold++
+	} else {
+		t.aboveThreshold = 0
+	}
+
+	switch {
+	case t.cfg.StopConsecutive > 0 && t.aboveThreshold >= t.cfg.StopConsecutive:
+		t.stopReason = "threshold reached"
+	case t.cfg.Patience > 0 && t.sinceBest >= t.cfg.Patience:
+		t.stopReason = "no improvement"
+	}
+	return t.stopReason != ""
+}
+
+// Evaluations returns the results of the periodic evaluations.
+func (t *Trainer) Evaluations() []EvalResult {
+	return t.evaluations
+}
+
+// Best returns the policy with the highest evaluation score seen so far and
+// its score, or nil if no evaluation has run.
+func (t *Trainer) Best() (*Policy, float64) {
+	return t.best, t.bestScore
+}
+
+// StopReason returns why training stopped early, or an empty string if it did not.
+func (t *Trainer) StopReason() string {
+	return t.stopReason
+}
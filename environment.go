@@ -0,0 +1,70 @@
+// environment.go
+package dqn
+
+// Environment is a reinforcement learning environment with a discrete action space.
+type Environment interface {
+	// Reset starts a new episode and returns the initial state.
+	Reset() []float64
+	// Step applies an action and returns the next state, the reward and whether the episode is done.
+	Step(action int) ([]float64, float64, bool)
+	// StateSize returns the length of the state vector.
+	StateSize() int
+	// NumActions returns the number of discrete actions.
+	NumActions() int
+}
+
+// Seeder is implemented by environments whose randomness can be seeded.
+type Seeder interface {
+	Seed(seed int64)
+}
+
+// Interrupter is implemented by environments whose episodes can be cut short
+// without a real final transition, e.g. a remote environment that lost its
+// instance. Trainers end an interrupted episode without learning from its
+// last step.
+type Interrupter interface {
+	// Interrupted reports whether the last Step was interrupted.
+	Interrupted() bool
+}
+
+// interrupted reports whether env implements Interrupter and its last Step was interrupted.
+func interrupted(env any) bool {
+	i, ok := env.(Interrupter)
+	return ok && i.Interrupted()
+}
+
+// Truncater is implemented by environments that tell episodes cut short by a
+// time limit apart from terminated ones. Trainers still bootstrap from the
+// final state of a truncated episode.
+type Truncater interface {
+	// Truncated reports whether the last Step ended the episode by truncation.
+	Truncated() bool
+}
+
+// truncated reports whether env implements Truncater and its last Step truncated the episode.
+func truncated(env any) bool {
+	t, ok := env.(Truncater)
+	return ok && t.Truncated()
+}
+
+// Configurable is implemented by environments with named parameters, such
+// as the level of observation noise, that can change between episodes.
+type Configurable interface {
+	SetParam(name string, value float64) error
+}
+
+// MultiAgentEnvironment is an environment shared by several agents that act
+// simultaneously, each with its own observation and reward.
+type MultiAgentEnvironment interface {
+	// Reset starts a new episode and returns the initial observation of every agent.
+	Reset() [][]float64
+	// Step applies one action per agent and returns the next observation and
+	// the reward of every agent, and whether the episode is done.
+	Step(actions []int) ([][]float64, []float64, bool)
+	// NumAgents returns the number of agents.
+	NumAgents() int
+	// StateSize returns the length of each agent's observation.
+	StateSize() int
+	// NumActions returns the number of discrete actions of each agent.
+	NumActions() int
+}
@@ -0,0 +1,78 @@
+// cartpole.go
+package envs
+
+import (
+	"math"
+	"math/rand"
+)
+
+// CartPole simulates the classic cart-pole balancing problem. The agent
+// receives a reward of 1 for every step the pole stays upright.
+type CartPole struct {
+	position, velocity, angle, angularVelocity float64
+	stepCount                                  int
+	MaxSteps                                   int
+	rng                                        *rand.Rand
+}
+
+// NewCartPole initializes a new CartPole environment.
+func NewCartPole() *CartPole {
+	return &CartPole{MaxSteps: 200, rng: rand.New(rand.NewSource(rand.Int63()))}
+}
+
+// Seed seeds the random initial states.
+func (env *CartPole) Seed(seed int64) {
+	env.rng = rand.New(rand.NewSource(seed))
+}
+
+// StateSize returns the length of the state vector.
+func (env *CartPole) StateSize() int { return 4 }
+
+// NumActions returns the number of discrete actions.
+func (env *CartPole) NumActions() int { return 2 }
+
+// Reset starts a new episode near the upright position.
+func (env *CartPole) Reset() []float64 {
+	env.position = env.rng.Float64()*0.08 - 0.04
+	env.velocity = env.rng.Float64()*0.08 - 0.04
+	env.angle = env.rng.Float64()*0.08 - 0.04
+	env.angularVelocity = env.rng.Float64()*0.08 - 0.04
+	env.stepCount = 0
+	return env.state()
+}
+
+// Step pushes the cart left (action 0) or right (action 1).
+func (env *CartPole) Step(action int) ([]float64, float64, bool) {
+	const gravity = 9.8
+	const massCart = 1.0
+	const massPole = 0.1
+	const totalMass = massCart + massPole
+	const length = 0.5 // actually half the pole's length
+	const poleMassLength = massPole * length
+	const forceMag = 10.0
+	const tau = 0.02 // seconds between state updates
+
+	force := -forceMag
+	if action == 1 {
+		force = forceMag
+	}
+
+	sin, cos := math.Sin(env.angle), math.Cos(env.angle)
+	temp := (force + poleMassLength*env.angularVelocity*env.angularVelocity*sin) / totalMass
+	angleAcc := (gravity*sin - cos*temp) / (length * (4.0/3.0 - massPole*cos*cos/totalMass))
+	acc := temp - poleMassLength*angleAcc*cos/totalMass
+
+	env.position += tau * env.velocity
+	env.velocity += tau * acc
+	env.angle += tau * env.angularVelocity
+	env.angularVelocity += tau * angleAcc
+	env.stepCount++
+
+	const angleLimit = 12 * 2 * math.Pi / 360
+	done := math.Abs(env.position) > 2.4 || math.Abs(env.angle) > angleLimit || env.stepCount >= env.MaxSteps
+	return env.state(), 1.0, done
+}
+
+func (env *CartPole) state() []float64 {
+	return []float64{env.position, env.velocity, env.angle, env.angularVelocity}
+}
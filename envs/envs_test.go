@@ -0,0 +1,57 @@
+// envs_test.go
+package envs_test
+
+import (
+	"testing"
+
+	"github.com/iampaapa/dqn"
+	"github.com/iampaapa/dqn/envs"
+	"github.com/iampaapa/dqn/envtest"
+)
+
+func TestContracts(t *testing.T) {
+	for name, factory := range map[string]func() dqn.Environment{
+		"CartPole":    func() dqn.Environment { return envs.NewCartPole() },
+		"MountainCar": func() dqn.Environment { return envs.NewMountainCar() },
+		"GridWorld":   func() dqn.Environment { return envs.NewGridWorld(3, 3) },
+		"FrozenLake":  func() dqn.Environment { return envs.NewFrozenLake(envs.FrozenLake4x4, true) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			envtest.Run(t, factory)
+		})
+	}
+}
+
+func TestEnvironments(t *testing.T) {
+	for name, env := range map[string]dqn.Environment{
+		"CartPole":    envs.NewCartPole(),
+		"MountainCar": envs.NewMountainCar(),
+		"GridWorld":   envs.NewGridWorld(3, 3),
+		"FrozenLake":  envs.NewFrozenLake(envs.FrozenLake4x4, true),
+	} {
+		state := env.Reset()
+		if len(state) != env.StateSize() {
+			t.Errorf("%s: expected state size %d, got %d", name, env.StateSize(), len(state))
+		}
+		done := false
+		for steps := 0; !done; steps++ {
+			if steps > 1000 {
+				t.Fatalf("%s: episode did not terminate", name)
+			}
+			state, _, done = env.Step(steps % env.NumActions())
+			if len(state) != env.StateSize() {
+				t.Fatalf("%s: expected state size %d, got %d", name, env.StateSize(), len(state))
+			}
+		}
+	}
+}
+
+func TestGridWorldGoal(t *testing.T) {
+	env := envs.NewGridWorld(2, 2)
+	env.Reset()
+	env.Step(2)
+	_, reward, done := env.Step(1)
+	if reward != 1 || !done {
+		t.Errorf("Expected goal reward, got %f (done=%v)", reward, done)
+	}
+}
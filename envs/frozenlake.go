@@ -0,0 +1,84 @@
+// frozenlake.go
+package envs
+
+import "math/rand"
+
+// FrozenLake4x4 is the standard 4x4 FrozenLake map: S is the start, F is
+// frozen surface, H is a hole and G is the goal.
+var FrozenLake4x4 = []string{
+	"SFFF",
+	"FHFH",
+	"FFFH",
+	"HFFG",
+}
+
+// FrozenLake is a grid of frozen tiles with holes. Reaching the goal gives a
+// reward of 1 and falling into a hole ends the episode with a reward of 0.
+// On slippery ice the agent moves in the intended direction only a third of
+// the time and sideways otherwise. States are one-hot encoded.
+type FrozenLake struct {
+	grid      []string
+	Slippery  bool
+	MaxSteps  int
+	x, y      int
+	stepCount int
+	rng       *rand.Rand
+}
+
+// NewFrozenLake initializes a new FrozenLake on the given map.
+func NewFrozenLake(grid []string, slippery bool) *FrozenLake {
+	return &FrozenLake{
+		grid:     grid,
+		Slippery: slippery,
+		MaxSteps: 100,
+		rng:      rand.New(rand.NewSource(rand.Int63())),
+	}
+}
+
+// Seed seeds the slippery transitions.
+func (env *FrozenLake) Seed(seed int64) {
+	env.rng = rand.New(rand.NewSource(seed))
+}
+
+// StateSize returns the length of the state vector.
+func (env *FrozenLake) StateSize() int { return len(env.grid) * len(env.grid[0]) }
+
+// NumActions returns the number of discrete actions.
+func (env *FrozenLake) NumActions() int { return 4 }
+
+// Reset moves the agent back to the start tile.
+func (env *FrozenLake) Reset() []float64 {
+	for y, row := range env.grid {
+		for x, tile := range row {
+			if tile == 'S' {
+				env.x, env.y = x, y
+			}
+		}
+	}
+	env.stepCount = 0
+	return env.state()
+}
+
+// Step moves the agent left (0), down (1), right (2) or up (3).
+func (env *FrozenLake) Step(action int) ([]float64, float64, bool) {
+	if env.Slippery {
+		// Slip to one of the two perpendicular directions with probability 2/3
+		action = (action + env.rng.Intn(3) + 3) % 4
+	}
+	env.x, env.y = move(env.x, env.y, action, len(env.grid[0]), len(env.grid))
+	env.stepCount++
+
+	switch env.grid[env.y][env.x] {
+	case 'G':
+		return env.state(), 1.0, true
+	case 'H':
+		return env.state(), 0.0, true
+	}
+	return env.state(), 0.0, env.stepCount >= env.MaxSteps
+}
+
+func (env *FrozenLake) state() []float64 {
+	state := make([]float64, env.StateSize())
+	state[env.y*len(env.grid[0])+env.x] = 1
+	return state
+}
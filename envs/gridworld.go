@@ -0,0 +1,64 @@
+// gridworld.go
+package envs
+
+// GridWorld is a deterministic grid where the agent starts in the top-left
+// corner and must reach the bottom-right corner. Reaching the goal gives a
+// reward of 1, every other step a reward of 0.
+type GridWorld struct {
+	Width, Height int
+	MaxSteps      int
+	x, y          int
+	stepCount     int
+}
+
+// NewGridWorld initializes a new GridWorld of the given size.
+func NewGridWorld(width, height int) *GridWorld {
+	return &GridWorld{Width: width, Height: height, MaxSteps: 4 * width * height}
+}
+
+// StateSize returns the length of the state vector.
+func (env *GridWorld) StateSize() int { return 2 }
+
+// NumActions returns the number of discrete actions.
+func (env *GridWorld) NumActions() int { return 4 }
+
+// Reset moves the agent back to the start cell.
+func (env *GridWorld) Reset() []float64 {
+	env.x, env.y = 0, 0
+	env.stepCount = 0
+	return env.state()
+}
+
+// Step moves the agent left (0), down (1), right (2) or up (3).
+func (env *GridWorld) Step(action int) ([]float64, float64, bool) {
+	env.x, env.y = move(env.x, env.y, action, env.Width, env.Height)
+	env.stepCount++
+
+	if env.x == env.Width-1 && env.y == env.Height-1 {
+		return env.state(), 1.0, true
+	}
+	return env.state(), 0.0, env.stepCount >= env.MaxSteps
+}
+
+// state returns the agent position scaled to [0, 1]
+func (env *GridWorld) state() []float64 {
+	return []float64{
+		float64(env.x) / float64(max(1, env.Width-1)),
+		float64(env.y) / float64(max(1, env.Height-1)),
+	}
+}
+
+// move applies a grid action, keeping the position inside the grid
+func move(x, y, action, width, height int) (int, int) {
+	switch action {
+	case 0:
+		x--
+	case 1:
+		y++
+	case 2:
+		x++
+	case 3:
+		y--
+	}
+	return max(0, min(x, width-1)), max(0, min(y, height-1))
+}
@@ -0,0 +1,59 @@
+// mountaincar.go
+package envs
+
+import (
+	"math"
+	"math/rand"
+)
+
+// MountainCar simulates an underpowered car that must rock back and forth to
+// climb a hill. The agent receives a reward of -1 for every step until it
+// reaches the goal.
+type MountainCar struct {
+	position, velocity float64
+	stepCount          int
+	MaxSteps           int
+	rng                *rand.Rand
+}
+
+// NewMountainCar initializes a new MountainCar environment.
+func NewMountainCar() *MountainCar {
+	return &MountainCar{MaxSteps: 200, rng: rand.New(rand.NewSource(rand.Int63()))}
+}
+
+// Seed seeds the random initial states.
+func (env *MountainCar) Seed(seed int64) {
+	env.rng = rand.New(rand.NewSource(seed))
+}
+
+// StateSize returns the length of the state vector.
+func (env *MountainCar) StateSize() int { return 2 }
+
+// NumActions returns the number of discrete actions.
+func (env *MountainCar) NumActions() int { return 3 }
+
+// Reset starts a new episode at the bottom of the valley.
+func (env *MountainCar) Reset() []float64 {
+	env.position = -0.6 + env.rng.Float64()*0.2
+	env.velocity = 0
+	env.stepCount = 0
+	return []float64{env.position, env.velocity}
+}
+
+// Step accelerates left (action 0), coasts (action 1) or accelerates right (action 2).
+func (env *MountainCar) Step(action int) ([]float64, float64, bool) {
+	const force = 0.001
+	const gravity = 0.0025
+
+	env.velocity += float64(action-1)*force - math.Cos(3*env.position)*gravity
+	env.velocity = math.Max(-0.07, math.Min(env.velocity, 0.07))
+	env.position += env.velocity
+	env.position = math.Max(-1.2, math.Min(env.position, 0.6))
+	if env.position == -1.2 && env.velocity < 0 {
+		env.velocity = 0
+	}
+	env.stepCount++
+
+	done := env.position >= 0.5 || env.stepCount >= env.MaxSteps
+	return []float64{env.position, env.velocity}, -1.0, done
+}
@@ -0,0 +1,80 @@
+// render.go
+package envs
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// cellSize is the size in pixels of a grid cell in rendered images.
+const cellSize = 32
+
+var (
+	colorAgent = color.RGBA{R: 220, A: 255}
+	colorGoal  = color.RGBA{G: 180, A: 255}
+	colorHole  = color.RGBA{B: 120, A: 255}
+	colorIce   = color.RGBA{R: 200, G: 230, B: 255, A: 255}
+	colorCart  = color.RGBA{R: 60, G: 60, B: 60, A: 255}
+	colorPole  = color.RGBA{R: 200, G: 140, B: 60, A: 255}
+)
+
+// Render draws the grid with the goal and the agent.
+func (env *GridWorld) Render() image.Image {
+	img := newCanvas(env.Width*cellSize, env.Height*cellSize)
+	fillCell(img, env.Width-1, env.Height-1, colorGoal)
+	fillCell(img, env.x, env.y, colorAgent)
+	return img
+}
+
+// Render draws the lake with its holes, the goal and the agent.
+func (env *FrozenLake) Render() image.Image {
+	img := newCanvas(len(env.grid[0])*cellSize, len(env.grid)*cellSize)
+	for y, row := range env.grid {
+		for x, tile := range row {
+			switch tile {
+			case 'H':
+				fillCell(img, x, y, colorHole)
+			case 'G':
+				fillCell(img, x, y, colorGoal)
+			default:
+				fillCell(img, x, y, colorIce)
+			}
+		}
+	}
+	fillCell(img, env.x, env.y, colorAgent)
+	return img
+}
+
+// Render draws the cart on its track and the pole.
+func (env *CartPole) Render() image.Image {
+	const width, height = 240, 120
+	const scale = width / 4.8 // pixels per unit of track
+	img := newCanvas(width, height)
+
+	cartX := int(width/2 + env.position*scale)
+	cartY := height - 20
+	draw.Draw(img, image.Rect(cartX-15, cartY-8, cartX+15, cartY+8), image.NewUniform(colorCart), image.Point{}, draw.Src)
+
+	// The pole is 1 unit long (twice the half length used by the physics)
+	for i := 0.0; i < scale; i++ {
+		x := cartX + int(i*math.Sin(env.angle))
+		y := cartY - int(i*math.Cos(env.angle))
+		draw.Draw(img, image.Rect(x-2, y-2, x+2, y+2), image.NewUniform(colorPole), image.Point{}, draw.Src)
+	}
+	return img
+}
+
+// newCanvas returns a white image of the given size
+func newCanvas(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	return img
+}
+
+// fillCell fills a grid cell, leaving a one pixel border
+func fillCell(img *image.RGBA, x, y int, c color.Color) {
+	rect := image.Rect(x*cellSize+1, y*cellSize+1, (x+1)*cellSize-1, (y+1)*cellSize-1)
+	draw.Draw(img, rect, image.NewUniform(c), image.Point{}, draw.Src)
+}
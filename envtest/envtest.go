@@ -0,0 +1,184 @@
+// Package envtest checks that an Environment implementation honours the
+// contract the agents rely on, so that simulator bugs are caught before
+// they show up as failed training runs.
+//
+// Call Run from a test of the environment's package:
+//
+//	func TestContract(t *testing.T) {
+//		envtest.Run(t, func() dqn.Environment { return NewSimulator() })
+//	}
+package envtest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/iampaapa/dqn"
+)
+
+// Config controls the checks made by Run.
+type Config struct {
+	Episodes int   // number of random episodes to run
+	MaxSteps int   // steps after which an episode is reported as not terminating, zero for no limit
+	Seed     int64 // seed of the random actions and of seeded environments
+}
+
+// Option configures Run.
+type Option func(*Config)
+
+// WithEpisodes sets the number of random episodes to run.
+func WithEpisodes(n int) Option { return func(c *Config) { c.Episodes = n } }
+
+// WithMaxSteps sets the number of steps within which every episode must end,
+// or zero for environments without a time limit.
+func WithMaxSteps(n int) Option { return func(c *Config) { c.MaxSteps = n } }
+
+// WithSeed sets the seed of the random actions and of seeded environments.
+func WithSeed(seed int64) Option { return func(c *Config) { c.Seed = seed } }
+
+// Run checks environments returned by factory, each check in its own
+// subtest: dimensions and finite values after Reset and Step, termination
+// of random episodes, stepping after the end of an episode, and, for
+// environments implementing dqn.Seeder, identical episodes for identical
+// seeds. factory must return a new, independent environment on every call.
+func Run(t *testing.T, factory func() dqn.Environment, opts ...Option) {
+	t.Helper()
+	cfg := Config{Episodes: 5, MaxSteps: 10000, Seed: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	t.Run("Reset", func(t *testing.T) {
+		env := factory()
+		if env.StateSize() <= 0 || env.NumActions() <= 0 {
+			t.Fatalf("Expected positive state size and action count, got %d and %d", env.StateSize(), env.NumActions())
+		}
+		for i := 0; i < 2; i++ {
+			if err := checkState(env, env.Reset()); err != nil {
+				t.Errorf("Reset: %v", err)
+			}
+		}
+	})
+
+	t.Run("Episodes", func(t *testing.T) {
+		env := factory()
+		rng := rand.New(rand.NewSource(cfg.Seed))
+		for episode := 0; episode < cfg.Episodes; episode++ {
+			if _, err := runEpisode(env, rng, cfg.MaxSteps); err != nil {
+				t.Fatalf("Episode %d: %v", episode, err)
+			}
+		}
+	})
+
+	t.Run("StepAfterDone", func(t *testing.T) {
+		env := factory()
+		rng := rand.New(rand.NewSource(cfg.Seed))
+		if _, err := runEpisode(env, rng, cfg.MaxSteps); err != nil {
+			t.Fatal(err)
+		}
+		if err := step(env, rng.Intn(env.NumActions())); err != nil {
+			t.Errorf("Step after the end of an episode: %v", err)
+		}
+		if err := checkState(env, env.Reset()); err != nil {
+			t.Errorf("Reset after the end of an episode: %v", err)
+		}
+	})
+
+	t.Run("Seed", func(t *testing.T) {
+		if _, ok := factory().(dqn.Seeder); !ok {
+			t.Skip("environment does not implement dqn.Seeder")
+		}
+		var trajectories [2][]float64
+		for i := range trajectories {
+			env := factory()
+			env.(dqn.Seeder).Seed(cfg.Seed)
+			rng := rand.New(rand.NewSource(cfg.Seed))
+			for episode := 0; episode < cfg.Episodes; episode++ {
+				trajectory, err := runEpisode(env, rng, cfg.MaxSteps)
+				if err != nil {
+					t.Fatalf("Episode %d: %v", episode, err)
+				}
+				trajectories[i] = append(trajectories[i], trajectory...)
+			}
+		}
+		if len(trajectories[0]) != len(trajectories[1]) {
+			t.Fatalf("Expected identical episodes for the same seed, got %d and %d values", len(trajectories[0]), len(trajectories[1]))
+		}
+		for i := range trajectories[0] {
+			if trajectories[0][i] != trajectories[1][i] {
+				t.Fatalf("Expected identical episodes for the same seed, first difference at value %d", i)
+			}
+		}
+	})
+}
+
+// runEpisode runs an episode with random actions and returns its states and
+// rewards in order.
+func runEpisode(env dqn.Environment, rng *rand.Rand, maxSteps int) ([]float64, error) {
+	state := env.Reset()
+	if err := checkState(env, state); err != nil {
+		return nil, fmt.Errorf("reset: %w", err)
+	}
+	trajectory := append([]float64(nil), state...)
+	for steps := 1; ; steps++ {
+		if maxSteps > 0 && steps > maxSteps {
+			return trajectory, fmt.Errorf("episode did not end within %d steps", maxSteps)
+		}
+		action := rng.Intn(env.NumActions())
+		state, reward, done, err := safeStep(env, action)
+		if err != nil {
+			return trajectory, fmt.Errorf("step %d, action %d: %w", steps, action, err)
+		}
+		if err := checkState(env, state); err != nil {
+			return trajectory, fmt.Errorf("step %d, action %d: %w", steps, action, err)
+		}
+		if math.IsNaN(reward) || math.IsInf(reward, 0) {
+			return trajectory, fmt.Errorf("step %d, action %d: reward is %v", steps, action, reward)
+		}
+		trajectory = append(append(trajectory, state...), reward)
+		if done {
+			return trajectory, nil
+		}
+	}
+}
+
+// step takes a single step and checks its result.
+func step(env dqn.Environment, action int) error {
+	state, reward, _, err := safeStep(env, action)
+	if err != nil {
+		return err
+	}
+	if err := checkState(env, state); err != nil {
+		return err
+	}
+	if math.IsNaN(reward) || math.IsInf(reward, 0) {
+		return fmt.Errorf("reward is %v", reward)
+	}
+	return nil
+}
+
+// safeStep calls Step and turns a panic into an error.
+func safeStep(env dqn.Environment, action int) (state []float64, reward float64, done bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	state, reward, done = env.Step(action)
+	return state, reward, done, nil
+}
+
+// checkState checks that a state has the declared size and finite values.
+func checkState(env dqn.Environment, state []float64) error {
+	if len(state) != env.StateSize() {
+		return fmt.Errorf("expected state size %d, got %d", env.StateSize(), len(state))
+	}
+	for i, v := range state {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("state[%d] is %v", i, v)
+		}
+	}
+	return nil
+}
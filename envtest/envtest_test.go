@@ -0,0 +1,63 @@
+package envtest
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/iampaapa/dqn"
+)
+
+// brokenEnv returns a NaN reward on its third step and panics when stepped
+// after the end of an episode.
+type brokenEnv struct{ steps int }
+
+func (e *brokenEnv) Reset() []float64 { e.steps = 0; return []float64{0} }
+func (e *brokenEnv) StateSize() int   { return 1 }
+func (e *brokenEnv) NumActions() int  { return 2 }
+
+func (e *brokenEnv) Step(int) ([]float64, float64, bool) {
+	e.steps++
+	if e.steps > 5 {
+		panic("stepped after done")
+	}
+	if e.steps == 3 {
+		return []float64{3}, math.NaN(), false
+	}
+	return []float64{float64(e.steps)}, 1, e.steps == 5
+}
+
+func TestChecks(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	if _, err := runEpisode(&brokenEnv{}, rng, 100); err == nil || !strings.Contains(err.Error(), "reward is NaN") {
+		t.Errorf("Expected a NaN reward error, got %v", err)
+	}
+	if _, err := runEpisode(&brokenEnv{}, rng, 2); err == nil || !strings.Contains(err.Error(), "did not end") {
+		t.Errorf("Expected a termination error, got %v", err)
+	}
+
+	env := &brokenEnv{steps: 5}
+	if err := step(env, 0); err == nil || !strings.Contains(err.Error(), "panic") {
+		t.Errorf("Expected a recovered panic, got %v", err)
+	}
+	if err := checkState(env, []float64{1, 2}); err == nil {
+		t.Error("Expected a state size error")
+	}
+}
+
+func TestRun(t *testing.T) {
+	Run(t, func() dqn.Environment { return &countdownEnv{} }, WithEpisodes(2))
+}
+
+// countdownEnv ends after three steps.
+type countdownEnv struct{ steps int }
+
+func (e *countdownEnv) Reset() []float64 { e.steps = 0; return []float64{0} }
+func (e *countdownEnv) StateSize() int   { return 1 }
+func (e *countdownEnv) NumActions() int  { return 2 }
+
+func (e *countdownEnv) Step(action int) ([]float64, float64, bool) {
+	e.steps++
+	return []float64{float64(e.steps)}, float64(action), e.steps >= 3
+}
@@ -0,0 +1,135 @@
+// evaluate.go
+package dqn
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// ReportQuantiles are the quantile levels included in a ReturnReport.
+var ReportQuantiles = []float64{0.05, 0.25, 0.5, 0.75, 0.95}
+
+// HistogramBin is a single bin of a return histogram.
+type HistogramBin struct {
+	Low, High float64
+	Count     int
+}
+
+// ReturnReport summarizes the distribution of episode returns, including its
+// lower tail.
+type ReturnReport struct {
+	Episodes  int
+	Mean      float64
+	Std       float64
+	Min       float64
+	Max       float64
+	Quantiles []float64 // values at ReportQuantiles
+	Alpha     float64
+	CVaR      float64 // mean return of the worst Alpha fraction of episodes
+	Histogram []HistogramBin
+}
+
+// NewReturnReport computes a ReturnReport from episode returns. alpha is the
+// tail fraction used for CVaR and bins the number of histogram bins, at
+// least one.
+func NewReturnReport(returns []float64, alpha float64, bins int) ReturnReport {
+	if len(returns) == 0 {
+		panic("Cannot report on an empty set of returns")
+	}
+
+	sorted := make([]float64, len(returns))
+	copy(sorted, returns)
+	sort.Float64s(sorted)
+
+	report := ReturnReport{
+		Episodes: len(sorted),
+		Min:      sorted[0],
+		Max:      sorted[len(sorted)-1],
+		Alpha:    alpha,
+	}
+	report.Mean, report.Std = stat.MeanStdDev(sorted, nil)
+
+	for _, q := range ReportQuantiles {
+		report.Quantiles = append(report.Quantiles, stat.Quantile(q, stat.Empirical, sorted, nil))
+	}
+
+	tail := int(math.Ceil(alpha * float64(len(sorted))))
+	tail = max(1, min(tail, len(sorted)))
+	report.CVaR = stat.Mean(sorted[:tail], nil)
+
+	report.Histogram = histogram(sorted, max(1, bins))
+	return report
+}
+
+// histogram bins sorted values into equally wide bins
+func histogram(sorted []float64, bins int) []HistogramBin {
+	low, high := sorted[0], sorted[len(sorted)-1]
+	width := (high - low) / float64(bins)
+	hist := make([]HistogramBin, bins)
+	for i := range hist {
+		hist[i].Low = low + float64(i)*width
+		hist[i].High = low + float64(i+1)*width
+	}
+	for _, val := range sorted {
+		i := bins - 1
+		if width > 0 {
+			i = min(int((val-low)/width), bins-1)
+		}
+		hist[i].Count++
+	}
+	return hist
+}
+
+// String formats the report for printing.
+func (r ReturnReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Episodes: %d\n", r.Episodes)
+	fmt.Fprintf(&b, "Mean: %.2f  Std: %.2f  Min: %.2f  Max: %.2f\n", r.Mean, r.Std, r.Min, r.Max)
+	for i, q := range ReportQuantiles {
+		fmt.Fprintf(&b, "P%02.0f: %.2f  ", q*100, r.Quantiles[i])
+	}
+	fmt.Fprintf(&b, "\nCVaR(%.2f): %.2f\n", r.Alpha, r.CVaR)
+	for _, bin := range r.Histogram {
+		fmt.Fprintf(&b, "[%8.2f, %8.2f) %d\n", bin.Low, bin.High, bin.Count)
+	}
+	return b.String()
+}
+
+// Evaluate runs the greedy policy of d on env for the given number of
+// episodes and returns the total reward of each episode. The agent knows
+// nothing of a Trainer's action limits; evaluate a policy with
+// WithActionLimits to respect them.
+func Evaluate(d *DQN, env Environment, episodes int) []float64 {
+	returns := make([]float64, episodes)
+	for i := range returns {
+		state := env.Reset()
+		done := false
+		for !done {
+			var reward float64
+			state, reward, done = env.Step(d.GreedyPolicy(state))
+			returns[i] += reward
+		}
+	}
+	return returns
+}
+
+// EvaluatePolicy runs a detached policy on env for the given number of
+// episodes and returns the total reward of each episode.
+func EvaluatePolicy(p *Policy, env Environment, episodes int) []float64 {
+	returns := make([]float64, episodes)
+	for i := range returns {
+		p.Reset()
+		state := env.Reset()
+		done := false
+		for !done {
+			var reward float64
+			state, reward, done = env.Step(p.Act(state))
+			returns[i] += reward
+		}
+	}
+	return returns
+}
@@ -2,17 +2,15 @@ package main
 
 import (
 	"fmt"
-	"image/color"
 	"log"
 	"math"
 	"math/rand"
 
 	"gonum.org/v1/gonum/stat"
-	"gonum.org/v1/plot"
-	"gonum.org/v1/plot/plotter"
-	"gonum.org/v1/plot/vg"
 
 	"github.com/iampaapa/dqn"
+	"github.com/iampaapa/dqn/tabular"
+	"github.com/iampaapa/dqn/viz"
 )
 
 // ManufacturingEnvironment simulates a manufacturing process
@@ -72,59 +70,6 @@ func (env *ManufacturingEnvironment) Reset() []float64 {
 	return []float64{env.temperature, env.pressure, env.flow}
 }
 
-// QLearning implements a simple Q-learning algorithm for comparison
-type QLearning struct {
-	qTable     map[int][]float64
-	alpha      float64
-	gamma      float64
-	epsilon    float64
-	numActions int
-}
-
-func NewQLearning(numActions int, alpha, gamma, epsilon float64) *QLearning {
-	return &QLearning{
-		qTable:     make(map[int][]float64),
-		alpha:      alpha,
-		gamma:      gamma,
-		epsilon:    epsilon,
-		numActions: numActions,
-	}
-}
-
-func (q *QLearning) GetAction(state []float64) int {
-	stateKey := q.discretizeState(state)
-	if _, ok := q.qTable[stateKey]; !ok {
-		q.qTable[stateKey] = make([]float64, q.numActions)
-	}
-
-	if rand.Float64() < q.epsilon {
-		return rand.Intn(q.numActions)
-	}
-	return dqn.Argmax(q.qTable[stateKey])
-}
-
-func (q *QLearning) Update(state []float64, action int, reward float64, nextState []float64) {
-	stateKey := q.discretizeState(state)
-	nextStateKey := q.discretizeState(nextState)
-
-	if _, ok := q.qTable[stateKey]; !ok {
-		q.qTable[stateKey] = make([]float64, q.numActions)
-	}
-	if _, ok := q.qTable[nextStateKey]; !ok {
-		q.qTable[nextStateKey] = make([]float64, q.numActions)
-	}
-
-	currentQ := q.qTable[stateKey][action]
-	maxNextQ := dqn.Max(q.qTable[nextStateKey])
-	newQ := currentQ + q.alpha*(reward+q.gamma*maxNextQ-currentQ)
-	q.qTable[stateKey][action] = newQ
-}
-
-func (q *QLearning) discretizeState(state []float64) int {
-	// Simple discretization: round each value to nearest integer
-	return int(math.Round(state[0])*10000 + math.Round(state[1])*100 + math.Round(state[2]))
-}
-
 func runExperiment(agent interface{}, env *ManufacturingEnvironment, episodes int) []float64 {
 	rewards := make([]float64, episodes)
 
@@ -141,7 +86,7 @@ func runExperiment(agent interface{}, env *ManufacturingEnvironment, episodes in
 			switch a := agent.(type) {
 			case *dqn.DQN:
 				action = a.EpsilonGreedyPolicy(dqn.Normalize(state), 6)
-			case *QLearning:
+			case *tabular.Agent:
 				action = a.GetAction(state)
 			}
 
@@ -151,8 +96,8 @@ func runExperiment(agent interface{}, env *ManufacturingEnvironment, episodes in
 			switch a := agent.(type) {
 			case *dqn.DQN:
 				a.Train(dqn.Normalize(state), dqn.Normalize(nextState), action, int(reward*100), stepDone)
-			case *QLearning:
-				a.Update(state, action, reward, nextState)
+			case *tabular.Agent:
+				a.Update(state, action, reward, nextState, stepDone)
 			}
 
 			state = nextState
@@ -165,64 +110,58 @@ func runExperiment(agent interface{}, env *ManufacturingEnvironment, episodes in
 	return rewards
 }
 
-func plotResults(dqnRewards, qLearningRewards []float64) {
-	p := plot.New()
-
-	p.Title.Text = "DQN vs Q-Learning Performance"
-	p.X.Label.Text = "Episode"
-	p.Y.Label.Text = "Total Reward"
-
-	dqnData := make(plotter.XYs, len(dqnRewards))
-	qLearningData := make(plotter.XYs, len(qLearningRewards))
-
-	for i := range dqnRewards {
-		dqnData[i].X = float64(i)
-		dqnData[i].Y = dqnRewards[i]
-		qLearningData[i].X = float64(i)
-		qLearningData[i].Y = qLearningRewards[i]
-	}
-
-	// Create a line plotter for the DQN data
-	dqnLine, err := plotter.NewLine(dqnData)
-	if err != nil {
-		log.Panic(err)
-	}
-	dqnLine.Color = color.RGBA{R: 255, G: 0, B: 0, A: 255} // Red
-
-	// Create a line plotter for the Q-Learning data
-	qLearningLine, err := plotter.NewLine(qLearningData)
-	if err != nil {
-		log.Panic(err)
-	}
-	qLearningLine.Color = color.RGBA{B: 255, A: 255} // Blue
-
-	// Add the lines to the plot
-	p.Add(dqnLine, qLearningLine)
-	p.Legend.Add("DQN", dqnLine)
-	p.Legend.Add("Q-Learning", qLearningLine)
-
-	// Save the plot to a PNG file
-	if err := p.Save(8*vg.Inch, 4*vg.Inch, "performance_comparison.png"); err != nil {
-		log.Panic(err)
-	}
-}
-
 func main() {
 	env := NewManufacturingEnvironment()
 	episodes := 1000
+	seeds := 5
+
+	// Episode returns within a run are not independent, so the agents are
+	// compared on the mean return of independently seeded runs. The return
+	// distributions and curves show the first run.
+	var dqnRewards, qLearningRewards []float64
+	dqnScores := make([]float64, seeds)
+	qLearningScores := make([]float64, seeds)
+	for seed := range dqnScores {
+		fmt.Printf("Starting DQN experiment %d/%d...\n", seed+1, seeds)
+		cfg := dqn.Config{
+			StateSize:    3,
+			NumActions:   6,
+			HiddenSize:   64,
+			BufferSize:   10000,
+			Gamma:        0.99,
+			Epsilon:      0.1,
+			LearningRate: 0.001,
+			Activation:   dqn.ReLU,
+			Seed:         int64(seed + 1),
+		}
+		dqnAgent := dqn.NewDQNFromConfig(cfg)
+		rewards := runExperiment(dqnAgent, env, episodes)
+		dqnScores[seed] = stat.Mean(rewards, nil)
+		if seed == 0 {
+			dqnRewards = rewards
+		}
 
-	fmt.Println("Starting DQN experiment...")
-	dqnAgent := dqn.NewDQN(3, 64, 6, 10000, 0.99, 0.1, 0.001, dqn.ReLU)
-	dqnRewards := runExperiment(dqnAgent, env, episodes)
-
-	fmt.Println("Starting Q-Learning experiment...")
-	qLearningAgent := NewQLearning(6, 0.1, 0.99, 0.1)
-	qLearningRewards := runExperiment(qLearningAgent, env, episodes)
+		fmt.Printf("Starting Q-Learning experiment %d/%d...\n", seed+1, seeds)
+		discretizer := tabular.NewUniformBinning([]float64{100, 30, 0}, []float64{250, 90, 25}, 10)
+		qLearningAgent := tabular.NewQLearning(discretizer, 6, 0.1, 0.99, 0.1)
+		qLearningAgent.Seed(cfg.TabularSeed())
+		rewards = runExperiment(qLearningAgent, env, episodes)
+		qLearningScores[seed] = stat.Mean(rewards, nil)
+		if seed == 0 {
+			qLearningRewards = rewards
+		}
+	}
 
-	fmt.Printf("DQN Average Reward: %.2f\n", stat.Mean(dqnRewards, nil))
-	fmt.Printf("Q-Learning Average Reward: %.2f\n", stat.Mean(qLearningRewards, nil))
+	fmt.Printf("DQN Average Reward: %.2f\n", stat.Mean(dqnScores, nil))
+	fmt.Printf("Q-Learning Average Reward: %.2f\n", stat.Mean(qLearningScores, nil))
+	fmt.Printf("DQN Return Distribution:\n%s", dqn.NewReturnReport(dqnRewards, 0.1, 10))
+	fmt.Printf("Q-Learning Return Distribution:\n%s", dqn.NewReturnReport(qLearningRewards, 0.1, 10))
+	fmt.Printf("DQN vs Q-Learning: %s\n", dqn.Compare(dqnScores, qLearningScores, 0.95))
 
 	fmt.Println("Plotting results...")
-	plotResults(dqnRewards, qLearningRewards)
+	runs := []viz.Series{{Name: "DQN", Values: dqnRewards}, {Name: "Q-Learning", Values: qLearningRewards}}
+	if err := viz.RewardCurves("performance_comparison.png", 1, runs...); err != nil {
+		log.Panic(err)
+	}
 	fmt.Println("Done. Check 'performance_comparison.png' for the results.")
 }
\ No newline at end of file
@@ -0,0 +1,336 @@
+// exploration.go
+package dqn
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+	"math/rand"
+)
+
+// Exploration selects actions while training. Illegal actions are passed
+// with a Q-value of -Inf and must never be selected.
+type Exploration interface {
+	SelectAction(qValues []float64, step int) int
+}
+
+// Perturber is implemented by explorations that act greedily on a
+// perturbed copy of the Q-network, such as parameter noise.
+type Perturber interface {
+	Perturb(q *QNetwork, step int) *QNetwork
+}
+
+// EpsilonGreedy picks a random legal action with probability epsilon, which
+// decays linearly from Start to End over DecaySteps steps, and otherwise
+// the greedy action.
+type EpsilonGreedy struct {
+	Start, End float64
+	DecaySteps int
+	source     *pcgSource
+	rng        *rand.Rand
+}
+
+// NewEpsilonGreedy initializes epsilon-greedy exploration. A zero seed picks a random seed.
+func NewEpsilonGreedy(start, end float64, decaySteps int, seed int64) *EpsilonGreedy {
+	source := newPCGSource(seed)
+	return &EpsilonGreedy{Start: start, End: end, DecaySteps: decaySteps, source: source, rng: rand.New(source)}
+}
+
+// Epsilon returns the exploration rate at step.
+func (e *EpsilonGreedy) Epsilon(step int) float64 {
+	if step >= e.DecaySteps {
+		return e.End
+	}
+	return e.Start + (e.End-e.Start)*float64(step)/float64(e.DecaySteps)
+}
+
+// SelectAction implements Exploration.
+func (e *EpsilonGreedy) SelectAction(qValues []float64, step int) int {
+	if e.rng.Float64() < e.Epsilon(step) {
+		legal := legalActions(finiteMask(qValues))
+		return legal[e.rng.Intn(len(legal))]
+	}
+	return Argmax(qValues)
+}
+
+// Softmax samples actions with probabilities proportional to exp(Q/T),
+// where the temperature T decays linearly from Start to End over DecaySteps steps.
+type Softmax struct {
+	Start, End float64
+	DecaySteps int
+	source     *pcgSource
+	rng        *rand.Rand
+}
+
+// NewSoftmax initializes Boltzmann exploration. A zero seed picks a random seed.
+func NewSoftmax(start, end float64, decaySteps int, seed int64) *Softmax {
+	source := newPCGSource(seed)
+	return &Softmax{Start: start, End: end, DecaySteps: decaySteps, source: source, rng: rand.New(source)}
+}
+
+// Temperature returns the temperature at step.
+func (s *Softmax) Temperature(step int) float64 {
+	if step >= s.DecaySteps {
+		return s.End
+	}
+	return s.Start + (s.End-s.Start)*float64(step)/float64(s.DecaySteps)
+}
+
+// SelectAction implements Exploration.
+func (s *Softmax) SelectAction(qValues []float64, step int) int {
+	temperature := math.Max(s.Temperature(step), 1e-8)
+	best := Max(qValues)
+	weights := make([]float64, len(qValues))
+	var sum float64
+	for i, q := range qValues {
+		weights[i] = math.Exp((q - best) / temperature) // zero for -Inf
+		sum += weights[i]
+	}
+	r := s.rng.Float64() * sum
+	for i, w := range weights {
+		if r -= w; r < 0 && w > 0 {
+			return i
+		}
+	}
+	return Argmax(qValues)
+}
+
+// UCB picks the action with the highest upper confidence bound
+// Q + C*sqrt(ln(t)/n), where n counts how often the action was chosen over
+// all states. Untried legal actions are chosen first.
+type UCB struct {
+	C      float64
+	counts []int
+	total  int
+}
+
+// NewUCB initializes upper confidence bound exploration.
+func NewUCB(c float64) *UCB {
+	return &UCB{C: c}
+}
+
+// SelectAction implements Exploration.
+func (u *UCB) SelectAction(qValues []float64, step int) int {
+	if u.counts == nil {
+		u.counts = make([]int, len(qValues))
+	}
+	best, bestScore := -1, math.Inf(-1)
+	for i, q := range qValues {
+		if math.IsInf(q, -1) {
+			continue
+		}
+		score := math.Inf(1)
+		if u.counts[i] > 0 {
+			score = q + u.C*math.Sqrt(math.Log(float64(u.total))/float64(u.counts[i]))
+		}
+		if best < 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	u.counts[best]++
+	u.total++
+	return best
+}
+
+// ParameterNoise acts greedily on a copy of the Q-network whose weights
+// are perturbed with Gaussian noise of standard deviation Stddev, resampled
+// every Every steps, which explores consistently within a stretch of steps.
+type ParameterNoise struct {
+	Stddev    float64
+	Every     int
+	source    *pcgSource
+	rng       *rand.Rand
+	perturbed *QNetwork
+	sampledAt int
+}
+
+// NewParameterNoise initializes parameter-space noise. A zero seed picks a random seed.
+func NewParameterNoise(stddev float64, every int, seed int64) *ParameterNoise {
+	source := newPCGSource(seed)
+	return &ParameterNoise{Stddev: stddev, Every: max(1, every), source: source, rng: rand.New(source)}
+}
+
+// Perturb implements Perturber.
+func (p *ParameterNoise) Perturb(q *QNetwork, step int) *QNetwork {
+	if p.perturbed == nil || step-p.sampledAt >= p.Every {
+		p.perturbed = q.perturbed(p.rng, func(int) float64 { return p.Stddev })
+		p.sampledAt = step
+	}
+	return p.perturbed
+}
+
+// SelectAction implements Exploration.
+func (p *ParameterNoise) SelectAction(qValues []float64, step int) int {
+	return Argmax(qValues)
+}
+
+// NoisyNet acts greedily on a copy of the Q-network with fresh Gaussian
+// weight noise at every step, scaled by Sigma/sqrt(fan-in) as in noisy
+// networks. QNetwork has no noisy layers, so the noise scales are fixed
+// rather than learned.
+type NoisyNet struct {
+	Sigma  float64
+	source *pcgSource
+	rng    *rand.Rand
+}
+
+// NewNoisyNet initializes noisy-network exploration. A zero seed picks a random seed.
+func NewNoisyNet(sigma float64, seed int64) *NoisyNet {
+	source := newPCGSource(seed)
+	return &NoisyNet{Sigma: sigma, source: source, rng: rand.New(source)}
+}
+
+// Perturb implements Perturber.
+func (n *NoisyNet) Perturb(q *QNetwork, step int) *QNetwork {
+	return q.perturbed(n.rng, func(fanIn int) float64 { return n.Sigma / math.Sqrt(float64(fanIn)) })
+}
+
+// SelectAction implements Exploration.
+func (n *NoisyNet) SelectAction(qValues []float64, step int) int {
+	return Argmax(qValues)
+}
+
+// explorationState is the serialized form of the state of an exploration
+// strategy, saved in checkpoints so that a resumed run explores as the
+// uninterrupted one would have.
+type explorationState struct {
+	RNG       []byte
+	Counts    []int
+	Total     int
+	Perturbed *networkState
+	SampledAt int
+}
+
+// encodeExploration encodes the state of an exploration strategy.
+func encodeExploration(state explorationState, source *pcgSource) ([]byte, error) {
+	if source != nil {
+		rng, err := source.pcg.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		state.RNG = rng
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(state)
+	return buf.Bytes(), err
+}
+
+// decodeExploration decodes the state of an exploration strategy and
+// restores its random source.
+func decodeExploration(data []byte, source *pcgSource) (explorationState, error) {
+	var state explorationState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+		return state, err
+	}
+	if source != nil && state.RNG != nil {
+		return state, source.pcg.UnmarshalBinary(state.RNG)
+	}
+	return state, nil
+}
+
+// MarshalBinary encodes the random state of the exploration.
+func (e *EpsilonGreedy) MarshalBinary() ([]byte, error) {
+	return encodeExploration(explorationState{}, e.source)
+}
+
+// UnmarshalBinary restores the random state encoded by MarshalBinary.
+func (e *EpsilonGreedy) UnmarshalBinary(data []byte) error {
+	_, err := decodeExploration(data, e.source)
+	return err
+}
+
+// MarshalBinary encodes the random state of the exploration.
+func (s *Softmax) MarshalBinary() ([]byte, error) {
+	return encodeExploration(explorationState{}, s.source)
+}
+
+// UnmarshalBinary restores the random state encoded by MarshalBinary.
+func (s *Softmax) UnmarshalBinary(data []byte) error {
+	_, err := decodeExploration(data, s.source)
+	return err
+}
+
+// MarshalBinary encodes the action counts.
+func (u *UCB) MarshalBinary() ([]byte, error) {
+	return encodeExploration(explorationState{Counts: u.counts, Total: u.total}, nil)
+}
+
+// UnmarshalBinary restores the action counts encoded by MarshalBinary.
+func (u *UCB) UnmarshalBinary(data []byte) error {
+	state, err := decodeExploration(data, nil)
+	u.counts, u.total = state.Counts, state.Total
+	return err
+}
+
+// MarshalBinary encodes the random state and the current perturbed network.
+func (p *ParameterNoise) MarshalBinary() ([]byte, error) {
+	state := explorationState{SampledAt: p.sampledAt}
+	if p.perturbed != nil {
+		network, err := p.perturbed.state()
+		if err != nil {
+			return nil, err
+		}
+		state.Perturbed = &network
+	}
+	return encodeExploration(state, p.source)
+}
+
+// UnmarshalBinary restores the state encoded by MarshalBinary.
+func (p *ParameterNoise) UnmarshalBinary(data []byte) error {
+	state, err := decodeExploration(data, p.source)
+	if err != nil {
+		return err
+	}
+	p.sampledAt, p.perturbed = state.SampledAt, nil
+	if state.Perturbed != nil {
+		p.perturbed, err = networkFromState(*state.Perturbed)
+	}
+	return err
+}
+
+// MarshalBinary encodes the random state of the exploration.
+func (n *NoisyNet) MarshalBinary() ([]byte, error) {
+	return encodeExploration(explorationState{}, n.source)
+}
+
+// UnmarshalBinary restores the random state encoded by MarshalBinary.
+func (n *NoisyNet) UnmarshalBinary(data []byte) error {
+	_, err := decodeExploration(data, n.source)
+	return err
+}
+
+// Explore selects an action among those allowed by mask, nil for all, with
+// the configured Exploration, or epsilon-greedily if there is none.
+func (d *DQN) Explore(state []float64, mask []bool) int {
+	if d.exploration == nil {
+		if mask == nil {
+			return d.EpsilonGreedyPolicy(state, d.qNetwork.outputSize)
+		}
+		return d.MaskedEpsilonGreedyPolicy(state, mask)
+	}
+	network := d.qNetwork
+	if p, ok := d.exploration.(Perturber); ok {
+		network = p.Perturb(network, d.steps)
+	}
+	qValues := network.Predict(state)
+	if mask != nil {
+		if len(legalActions(mask)) == 0 {
+			panic("Action mask has no legal actions")
+		}
+		for i, legal := range mask {
+			if !legal {
+				qValues[i] = math.Inf(-1)
+			}
+		}
+	}
+	return d.exploration.SelectAction(qValues, d.steps)
+}
+
+// finiteMask marks the actions whose Q-value is not -Inf.
+func finiteMask(qValues []float64) []bool {
+	mask := make([]bool, len(qValues))
+	for i, q := range qValues {
+		mask[i] = !math.IsInf(q, -1)
+	}
+	return mask
+}
@@ -0,0 +1,146 @@
+// file.go
+package dqn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// fileMagic starts every file written by SaveFile.
+const fileMagic = "DQNF"
+
+// ErrChecksum is returned by LoadFile when a file's payload does not match its checksum.
+var ErrChecksum = errors.New("dqn: checksum mismatch")
+
+// writeFileAtomic writes a file through a temporary file in the same
+// directory that is renamed over path once complete, so that path never
+// holds a partially written file. The directory is synced after the rename
+// so that the new file survives a crash, and the file is readable by
+// everyone like one made by os.Create.
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	// CreateTemp uses mode 0600, while os.Create usually gives 0644
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir flushes the directory entries of dir to disk. Directories cannot
+// be synced on Windows, where it does nothing.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// writeChecked atomically writes the payload produced by save to path,
+// preceded by a magic string and its SHA-256 checksum.
+func writeChecked(path string, save func(io.Writer) error) error {
+	var payload bytes.Buffer
+	if err := save(&payload); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(payload.Bytes())
+	return writeFileAtomic(path, func(w io.Writer) error {
+		if _, err := io.WriteString(w, fileMagic); err != nil {
+			return err
+		}
+		if _, err := w.Write(sum[:]); err != nil {
+			return err
+		}
+		_, err := w.Write(payload.Bytes())
+		return err
+	})
+}
+
+// readChecked reads a file written by writeChecked and returns its payload
+// after verifying the checksum.
+func readChecked(path string) (io.Reader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	header := len(fileMagic) + sha256.Size
+	if len(data) < header || string(data[:len(fileMagic)]) != fileMagic {
+		return nil, fmt.Errorf("dqn: %s is not a model file", path)
+	}
+	payload := data[header:]
+	if sum := sha256.Sum256(payload); !bytes.Equal(sum[:], data[len(fileMagic):header]) {
+		return nil, fmt.Errorf("%w in %s", ErrChecksum, path)
+	}
+	return bytes.NewReader(payload), nil
+}
+
+// SaveFile saves the network to path atomically with an embedded checksum.
+func (q *QNetwork) SaveFile(path string) error {
+	return writeChecked(path, q.Save)
+}
+
+// LoadFile replaces the network with the one saved to path by SaveFile,
+// returning ErrChecksum if the file is corrupted.
+func (q *QNetwork) LoadFile(path string) error {
+	r, err := readChecked(path)
+	if err != nil {
+		return err
+	}
+	loaded, err := LoadQNetwork(r)
+	if err != nil {
+		return err
+	}
+	*q = *loaded
+	return nil
+}
+
+// SaveFile saves the policy to path atomically with an embedded checksum.
+func (p *Policy) SaveFile(path string) error {
+	return writeChecked(path, p.Save)
+}
+
+// LoadFile replaces the policy with the one saved to path by SaveFile,
+// returning ErrChecksum if the file is corrupted.
+func (p *Policy) LoadFile(path string) error {
+	r, err := readChecked(path)
+	if err != nil {
+		return err
+	}
+	loaded, err := LoadPolicy(r)
+	if err != nil {
+		return err
+	}
+	*p = *loaded
+	return nil
+}
@@ -0,0 +1,76 @@
+// initializer.go
+package dqn
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Initializer sets the initial weights and biases of a layer, drawing from
+// uniform, a source of uniform numbers in [0, 1).
+type Initializer func(w *mat.Dense, b *mat.VecDense, uniform func() float64)
+
+// initializers maps initializer names to functions so that the choice can
+// be recorded in configs and saved networks.
+var initializers = map[string]Initializer{
+	"xavier":     Xavier,
+	"orthogonal": Orthogonal(1),
+}
+
+// RegisterInitializer registers a custom initializer under name, e.g. an
+// orthogonal initializer with the gain suited to an activation.
+func RegisterInitializer(name string, init Initializer) {
+	initializers[name] = init
+}
+
+// Xavier draws weights and biases uniformly from ±sqrt(6/(fanIn+fanOut)).
+func Xavier(w *mat.Dense, b *mat.VecDense, uniform func() float64) {
+	fanOut, fanIn := w.Dims()
+	bound := math.Sqrt(6.0 / float64(fanIn+fanOut))
+	w.Apply(func(_, _ int, _ float64) float64 { return uniform()*2*bound - bound }, w)
+	for i := 0; i < b.Len(); i++ {
+		b.SetVec(i, uniform()*2*bound-bound)
+	}
+}
+
+// Orthogonal returns an initializer that sets the weights to a random
+// orthogonal matrix scaled by gain, and the biases to zero. Use a gain of
+// sqrt(2) for ReLU layers.
+func Orthogonal(gain float64) Initializer {
+	return func(w *mat.Dense, b *mat.VecDense, uniform func() float64) {
+		rows, cols := w.Dims()
+		// Factorize a tall Gaussian matrix, transposing wide layers
+		n, m := max(rows, cols), min(rows, cols)
+		a := mat.NewDense(n, m, nil)
+		a.Apply(func(_, _ int, _ float64) float64 { return normal(uniform) }, a)
+
+		var qr mat.QR
+		qr.Factorize(a)
+		var q, r mat.Dense
+		qr.QTo(&q)
+		qr.RTo(&r)
+		// Fix the signs so that the distribution is uniform over orthogonal matrices
+		for j := 0; j < m; j++ {
+			sign := gain
+			if r.At(j, j) < 0 {
+				sign = -gain
+			}
+			for i := 0; i < n; i++ {
+				q.Set(i, j, q.At(i, j)*sign)
+			}
+		}
+		if rows >= cols {
+			w.Copy(q.Slice(0, n, 0, m))
+		} else {
+			w.Copy(q.Slice(0, n, 0, m).T())
+		}
+		b.Zero()
+	}
+}
+
+// normal draws a standard normal number from a uniform source with the
+// Box-Muller transform.
+func normal(uniform func() float64) float64 {
+	return math.Sqrt(-2*math.Log(1-uniform())) * math.Cos(2*math.Pi*uniform())
+}
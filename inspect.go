@@ -0,0 +1,124 @@
+// inspect.go
+package dqn
+
+import (
+	"fmt"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// LayerSummary describes a single dense layer of a QNetwork.
+type LayerSummary struct {
+	Name       string
+	Inputs     int
+	Outputs    int
+	Params     int
+	Activation string
+}
+
+// NetworkSummary describes the layers and parameter counts of a QNetwork.
+type NetworkSummary struct {
+	Layers      []LayerSummary
+	TotalParams int
+}
+
+// QNetwork returns the agent's online Q-network.
+func (d *DQN) QNetwork() *QNetwork {
+	return d.qNetwork
+}
+
+// NumLayers returns the number of dense layers.
+func (q *QNetwork) NumLayers() int {
+	return 2
+}
+
+// Summary returns the layer shapes and parameter counts of the network.
+func (q *QNetwork) Summary() NetworkSummary {
+	activation, err := activationName(q.activation)
+	if err != nil {
+		activation = "custom"
+	}
+	summary := NetworkSummary{Layers: []LayerSummary{
+		{Name: "hidden", Inputs: q.inputSize, Outputs: q.hiddenSize, Activation: activation},
+		{Name: "output", Inputs: q.hiddenSize, Outputs: q.outputSize, Activation: "linear"},
+	}}
+	for i := range summary.Layers {
+		layer := &summary.Layers[i]
+		layer.Params = layer.Inputs*layer.Outputs + layer.Outputs
+		summary.TotalParams += layer.Params
+	}
+	return summary
+}
+
+// String formats the summary as a table.
+func (s NetworkSummary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-8s %-12s %-10s %s\n", "Layer", "Shape", "Activation", "Params")
+	for _, layer := range s.Layers {
+		shape := fmt.Sprintf("%dx%d", layer.Outputs, layer.Inputs)
+		fmt.Fprintf(&b, "%-8s %-12s %-10s %d\n", layer.Name, shape, layer.Activation, layer.Params)
+	}
+	fmt.Fprintf(&b, "Total params: %d\n", s.TotalParams)
+	return b.String()
+}
+
+// layer returns the weights and biases of a layer; 0 is the hidden layer and 1 the output layer.
+func (q *QNetwork) layer(i int) (*mat.Dense, *mat.VecDense) {
+	switch i {
+	case 0:
+		return q.w1, q.b1
+	case 1:
+		return q.w2, q.b2
+	}
+	panic(fmt.Sprintf("Layer %d out of range", i))
+}
+
+// Weights returns a copy of a layer's weight matrix, one row per output unit.
+// Layer 0 is the hidden layer and layer 1 the output layer.
+func (q *QNetwork) Weights(layer int) [][]float64 {
+	w, _ := q.layer(layer)
+	rows, cols := w.Dims()
+	out := make([][]float64, rows)
+	for i := range out {
+		out[i] = make([]float64, cols)
+		mat.Row(out[i], i, w)
+	}
+	return out
+}
+
+// Biases returns a copy of a layer's bias vector.
+func (q *QNetwork) Biases(layer int) []float64 {
+	_, b := q.layer(layer)
+	return append([]float64(nil), b.RawVector().Data...)
+}
+
+// SetWeights replaces a layer's weight matrix. The shape must match.
+func (q *QNetwork) SetWeights(layer int, weights [][]float64) error {
+	w, _ := q.layer(layer)
+	rows, cols := w.Dims()
+	if len(weights) != rows {
+		return fmt.Errorf("dqn: layer %d expects %d weight rows, got %d", layer, rows, len(weights))
+	}
+	for _, row := range weights {
+		if len(row) != cols {
+			return fmt.Errorf("dqn: layer %d expects %d weight columns, got %d", layer, cols, len(row))
+		}
+	}
+	for i, row := range weights {
+		w.SetRow(i, row)
+	}
+	return nil
+}
+
+// SetBiases replaces a layer's bias vector. The length must match.
+func (q *QNetwork) SetBiases(layer int, biases []float64) error {
+	_, b := q.layer(layer)
+	if len(biases) != b.Len() {
+		return fmt.Errorf("dqn: layer %d expects %d biases, got %d", layer, b.Len(), len(biases))
+	}
+	for i, v := range biases {
+		b.SetVec(i, v)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// limits.go
+package dqn
+
+import "fmt"
+
+// ActionLimit allows Action to be chosen at most Max times in any Window
+// consecutive steps.
+type ActionLimit struct {
+	Action int
+	Max    int
+	Window int
+}
+
+// ActionLimiter tracks the recent actions of an episode and masks the
+// actions that have reached their limit.
+type ActionLimiter struct {
+	numActions int
+	limits     []ActionLimit
+	recent     []int // the last actions, oldest first
+	history    int   // number of recent actions kept
+}
+
+// NewActionLimiter returns a limiter for numActions actions. It panics if a
+// limit is invalid.
+func NewActionLimiter(numActions int, limits ...ActionLimit) *ActionLimiter {
+	l := &ActionLimiter{numActions: numActions, limits: limits}
+	for _, limit := range limits {
+		if problem := limit.check(numActions); problem != "" {
+			panic(problem)
+		}
+		l.history = max(l.history, limit.Window-1)
+	}
+	return l
+}
+
+// check returns why the limit is invalid for numActions actions, or an
+// empty string if it is valid.
+func (limit ActionLimit) check(numActions int) string {
+	if limit.Action < 0 || limit.Action >= numActions {
+		return fmt.Sprintf("Action limit for unknown action %d", limit.Action)
+	}
+	if limit.Max < 0 || limit.Window < 1 {
+		return "Action limits need a non-negative maximum and a positive window"
+	}
+	return ""
+}
+
+// Mask returns which actions may be chosen at the next step without
+// exceeding a limit. Limits on several actions can together exclude every
+// action, e.g. two actions allowed once in a window of three after both
+// were taken. The mask then allows the least violating actions: those that
+// exceed their limits by the fewest choices.
+func (l *ActionLimiter) Mask() []bool {
+	// excess counts how many choices over its limits an action would be
+	excess := make([]int, l.numActions)
+	for _, limit := range l.limits {
+		count := 0
+		for _, a := range l.recent[max(0, len(l.recent)-(limit.Window-1)):] {
+			if a == limit.Action {
+				count++
+			}
+		}
+		excess[limit.Action] = max(excess[limit.Action], count-limit.Max+1)
+	}
+
+	least := excess[0]
+	for _, e := range excess {
+		least = min(least, e)
+	}
+	least = max(least, 0)
+	mask := make([]bool, l.numActions)
+	for i, e := range excess {
+		mask[i] = e <= least
+	}
+	return mask
+}
+
+// Record adds the action taken at the current step.
+func (l *ActionLimiter) Record(action int) {
+	l.recent = append(l.recent, action)
+	if len(l.recent) > l.history {
+		l.recent = l.recent[len(l.recent)-l.history:]
+	}
+}
+
+// Reset forgets the actions of the previous episode.
+func (l *ActionLimiter) Reset() {
+	l.recent = l.recent[:0]
+}
@@ -0,0 +1,113 @@
+// manifest.go
+package dqn
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// ModuleVersion is a dependency of the binary that ran an experiment.
+type ModuleVersion struct {
+	Path    string
+	Version string
+}
+
+// RunManifest records the software and hardware an experiment ran on.
+type RunManifest struct {
+	Started    time.Time
+	Episode    int // episodes already run when this run started, e.g. after Resume
+	GoVersion  string
+	OS, Arch   string
+	GOMAXPROCS int
+	NumCPU     int
+	CPUModel   string
+	Hostname   string
+	// Module version and VCS revision of the main module
+	Version  string
+	Revision string
+	Modified bool
+	Modules  []ModuleVersion
+	Config   Config
+}
+
+// NewRunManifest collects the manifest of the current process for cfg.
+func NewRunManifest(cfg Config) RunManifest {
+	m := RunManifest{
+		Started:    time.Now().UTC(),
+		GoVersion:  runtime.Version(),
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		CPUModel:   cpuModel(),
+		Config:     cfg,
+	}
+	m.Hostname, _ = os.Hostname()
+	if info, ok := debug.ReadBuildInfo(); ok {
+		m.Version = info.Main.Version
+		m.Revision, m.Modified = vcsInfo(info)
+		for _, dep := range info.Deps {
+			m.Modules = append(m.Modules, ModuleVersion{Path: dep.Path, Version: dep.Version})
+		}
+	}
+	return m
+}
+
+// vcsInfo returns the VCS revision the binary was built from and whether
+// the working tree had uncommitted changes.
+func vcsInfo(info *debug.BuildInfo) (revision string, modified bool) {
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	return revision, modified
+}
+
+// cpuModel returns the CPU model name on Linux, or an empty string elsewhere.
+func cpuModel() string {
+	f, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok && strings.TrimSpace(key) == "model name" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// Write writes the manifest as indented JSON to w.
+func (m RunManifest) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// writeRunManifest records the manifest of a run starting at the current
+// episode in the checkpoint directory. Every start, including resumed runs,
+// gets its own file.
+func (t *Trainer) writeRunManifest() error {
+	if err := os.MkdirAll(t.cfg.CheckpointDir, 0o755); err != nil {
+		return err
+	}
+	m := NewRunManifest(t.cfg)
+	m.Episode = len(t.history)
+	path := filepath.Join(t.cfg.CheckpointDir, fmt.Sprintf("manifest-%08d.json", m.Episode))
+	return writeFileAtomic(path, m.Write)
+}
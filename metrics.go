@@ -0,0 +1,135 @@
+// metrics.go
+package dqn
+
+import "math"
+
+// ActionEntropy returns the Shannon entropy (in nats) of the action distribution given by counts.
+func ActionEntropy(counts []int) float64 {
+	var total int
+	for _, c := range counts {
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+
+	var entropy float64
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / float64(total)
+		entropy -= p * math.Log(p)
+	}
+	return entropy
+}
+
+// PolicyStats is a single snapshot of the policy diagnostics.
+type PolicyStats struct {
+	Step    int
+	Entropy float64
+	Churn   float64
+	// GradientSimilarity holds the GradientSimilarity of the tasks set with
+	// SetGradientTasks, or nil if none are set.
+	GradientSimilarity [][]float64
+}
+
+// PolicyMonitor tracks the entropy of chosen actions and the churn of greedy
+// actions on a fixed set of probe states, to help detect policy collapse and instability.
+type PolicyMonitor struct {
+	probes     [][]float64
+	counts     []int
+	lastGreedy []int
+	history    []PolicyStats
+	tasks      [][]Experience
+}
+
+// NewPolicyMonitor initializes a new PolicyMonitor for the given probe states.
+func NewPolicyMonitor(numActions int, probes [][]float64) *PolicyMonitor {
+	return &PolicyMonitor{
+		probes: probes,
+		counts: make([]int, numActions),
+	}
+}
+
+// SetGradientTasks sets held-out experiences of several tasks whose gradient
+// similarity is included in every snapshot, to detect negative transfer as
+// training goes on.
+func (m *PolicyMonitor) SetGradientTasks(tasks [][]Experience) {
+	m.tasks = tasks
+}
+
+// RecordAction records an action chosen by the agent.
+func (m *PolicyMonitor) RecordAction(action int) {
+	m.counts[action]++
+}
+
+// Snapshot computes the action entropy since the last snapshot and the fraction
+// of probe states whose greedy action changed, then appends them to the history.
+func (m *PolicyMonitor) Snapshot(step int, d *DQN) PolicyStats {
+	stats := PolicyStats{Step: step, Entropy: ActionEntropy(m.counts)}
+
+	greedy := make([]int, len(m.probes))
+	changed := 0
+	for i, probe := range m.probes {
+		greedy[i] = d.GreedyPolicy(probe)
+		if m.lastGreedy != nil && greedy[i] != m.lastGreedy[i] {
+			changed++
+		}
+	}
+	if m.lastGreedy != nil && len(m.probes) > 0 {
+		stats.Churn = float64(changed) / float64(len(m.probes))
+	}
+	m.lastGreedy = greedy
+	if m.tasks != nil {
+		stats.GradientSimilarity = GradientSimilarity(d, m.tasks)
+	}
+
+	for i := range m.counts {
+		m.counts[i] = 0
+	}
+	m.history = append(m.history, stats)
+	return stats
+}
+
+// History returns all recorded snapshots.
+func (m *PolicyMonitor) History() []PolicyStats {
+	return m.history
+}
+
+// GradientSimilarity computes the mean TD gradient of d on the experiences of
+// each task, without updating the network or applying dropout, and returns
+// the matrix of pairwise cosine similarities between them. Negative entries
+// mean that training on one task works against the other, a sign of
+// negative transfer.
+func GradientSimilarity(d *DQN, tasks [][]Experience) [][]float64 {
+	grads := make([]*gradients, len(tasks))
+	for i, batch := range tasks {
+		for _, exp := range batch {
+			_, g := d.tdGradientsWith(nil, exp.State, exp.NextState, exp.Action, exp.Reward, exp.terminal(), exp.NextMask)
+			if grads[i] == nil {
+				grads[i] = g
+			} else {
+				grads[i].add(g)
+			}
+		}
+		if grads[i] != nil {
+			grads[i].scale(1 / float64(len(batch)))
+		}
+	}
+
+	similarity := make([][]float64, len(tasks))
+	for i := range similarity {
+		similarity[i] = make([]float64, len(tasks))
+		for j := range similarity[i] {
+			if grads[i] == nil || grads[j] == nil {
+				continue
+			}
+			norms := math.Sqrt(grads[i].dot(grads[i]) * grads[j].dot(grads[j]))
+			if norms > 0 {
+				similarity[i][j] = grads[i].dot(grads[j]) / norms
+			}
+		}
+	}
+	return similarity
+}
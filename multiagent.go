@@ -0,0 +1,146 @@
+// multiagent.go
+package dqn
+
+import "context"
+
+// MultiAgentEpisodeStats holds statistics of a single multi-agent training episode.
+type MultiAgentEpisodeStats struct {
+	Episode int
+	Returns []float64 // return of each agent
+	Steps   int
+}
+
+// MultiAgentTrainer trains independent DQN agents on a shared environment.
+// Each agent learns from its own observations and rewards, treating the
+// other agents as part of the environment.
+type MultiAgentTrainer struct {
+	agents  []*DQN
+	env     MultiAgentEnvironment
+	cfg     Config
+	history []MultiAgentEpisodeStats
+	// accumulators build the n-step experiences of each agent, nil for one-step targets
+	accumulators []*NStepAccumulator
+	// traces holds the eligibility trace of each agent, nil without traces
+	traces []*gradients
+}
+
+// NewMultiAgentTrainer initializes a new MultiAgentTrainer with one agent per
+// environment agent, built from DefaultConfig and opts. With shared set, all
+// agents use the same network and replay buffer; observations should then
+// identify the agent if agents are meant to behave differently. Eligibility
+// traces and n-step transitions are still kept per agent.
+func NewMultiAgentTrainer(env MultiAgentEnvironment, shared bool, opts ...Option) *MultiAgentTrainer {
+	cfg := DefaultConfig(env.StateSize(), env.NumActions())
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	agents := make([]*DQN, env.NumAgents())
+	for i := range agents {
+		if shared && i > 0 {
+			agents[i] = agents[0]
+		} else {
+			agents[i] = NewDQNFromConfig(cfg)
+		}
+	}
+	t := &MultiAgentTrainer{agents: agents, env: env, cfg: cfg}
+	if cfg.Lambda > 0 {
+		t.traces = make([]*gradients, len(agents))
+	}
+	if cfg.NSteps > 1 {
+		t.accumulators = make([]*NStepAccumulator, len(agents))
+		for i := range t.accumulators {
+			t.accumulators[i] = NewNStepAccumulator(cfg.NSteps, cfg.Gamma)
+		}
+	}
+	return t
+}
+
+// Agents returns the agent of each environment agent. With parameter
+// sharing, every entry is the same agent.
+func (t *MultiAgentTrainer) Agents() []*DQN {
+	return t.agents
+}
+
+// History returns the statistics of every episode run so far.
+func (t *MultiAgentTrainer) History() []MultiAgentEpisodeStats {
+	return t.history
+}
+
+// Run trains the agents for the configured number of episodes and returns
+// the statistics of every episode. If ctx is cancelled, Run stops after the
+// current step, discards the unfinished episode and returns ctx.Err(). It
+// also stops, without training on the failed step, if the environment
+// reports an error through an Err method.
+func (t *MultiAgentTrainer) Run(ctx context.Context) ([]MultiAgentEpisodeStats, error) {
+	for len(t.history) < t.cfg.Episodes {
+		stats, err := t.runEpisode(ctx, len(t.history))
+		if err != nil {
+			return t.history, err
+		}
+		t.history = append(t.history, stats)
+	}
+	return t.history, nil
+}
+
+// runEpisode trains the agents on a single episode.
+func (t *MultiAgentTrainer) runEpisode(ctx context.Context, episode int) (MultiAgentEpisodeStats, error) {
+	stats := MultiAgentEpisodeStats{Episode: episode, Returns: make([]float64, len(t.agents))}
+	states := t.env.Reset()
+	if err := envErr(t.env); err != nil {
+		return stats, err
+	}
+	for _, a := range t.accumulators {
+		a.Reset()
+	}
+	for i := range t.traces {
+		t.traces[i] = nil
+	}
+	actions := make([]int, len(t.agents))
+	for done := false; !done; {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		for i, agent := range t.agents {
+			actions[i] = agent.EpsilonGreedyPolicy(states[i], t.env.NumActions())
+		}
+		nextStates, rewards, stepDone := t.env.Step(actions)
+		if err := envErr(t.env); err != nil {
+			return stats, err
+		}
+		if interrupted(t.env) {
+			return stats, nil
+		}
+		for i := range t.agents {
+			t.observe(i, Experience{
+				State:     states[i],
+				NextState: nextStates[i],
+				Action:    actions[i],
+				Reward:    scaleReward(rewards[i], t.cfg.RewardScale),
+				Done:      stepDone,
+				Truncated: stepDone && truncated(t.env),
+			})
+			stats.Returns[i] += rewards[i]
+		}
+		stats.Steps++
+		states = nextStates
+		done = stepDone
+	}
+	return stats, nil
+}
+
+// observe passes a transition of agent i to its DQN, as part of n-step
+// experiences if configured. The DQN's eligibility trace is swapped for the
+// one of agent i, so that agents sharing a DQN do not share a trace.
+func (t *MultiAgentTrainer) observe(i int, exp Experience) {
+	if t.traces != nil {
+		t.agents[i].traces = t.traces[i]
+		defer func() { t.traces[i] = t.agents[i].traces }()
+	}
+	if t.accumulators == nil {
+		t.agents[i].Observe(exp)
+		return
+	}
+	for _, e := range t.accumulators[i].Add(exp) {
+		t.agents[i].Observe(e)
+	}
+}
@@ -0,0 +1,70 @@
+// nstep.go
+package dqn
+
+import "math"
+
+// NStepAccumulator turns the transitions of one environment into n-step
+// experiences, whose reward is the discounted sum of up to n rewards and
+// whose next state lies n steps ahead. At the end of an episode it flushes
+// the shorter experiences of its last steps, all marked done, so that no
+// transition is combined with one from the next episode. When the episode
+// is truncated the shorter experiences are dropped instead, since their
+// targets would bootstrap over fewer than n steps.
+type NStepAccumulator struct {
+	n       int
+	gamma   float64
+	pending []Experience
+}
+
+// NewNStepAccumulator initializes an accumulator over n steps with discount gamma.
+func NewNStepAccumulator(n int, gamma float64) *NStepAccumulator {
+	if n < 1 {
+		panic("N-step accumulator needs at least one step")
+	}
+	return &NStepAccumulator{n: n, gamma: gamma}
+}
+
+// Add records a transition and returns the experiences that are complete.
+func (a *NStepAccumulator) Add(exp Experience) []Experience {
+	a.pending = append(a.pending, exp)
+	var ready []Experience
+	if exp.Done {
+		for i := range a.pending {
+			if exp.Truncated && len(a.pending)-i < a.n {
+				break
+			}
+			ready = append(ready, a.merge(a.pending[i:]))
+		}
+		a.Reset()
+		return ready
+	}
+	if len(a.pending) == a.n {
+		ready = append(ready, a.merge(a.pending))
+		a.pending = append(a.pending[:0], a.pending[1:]...)
+	}
+	return ready
+}
+
+// Reset discards the transitions of an unfinished episode.
+func (a *NStepAccumulator) Reset() {
+	a.pending = a.pending[:0]
+}
+
+// merge combines consecutive transitions into a single experience.
+func (a *NStepAccumulator) merge(transitions []Experience) Experience {
+	first, last := transitions[0], transitions[len(transitions)-1]
+	var reward, discount float64 = 0, 1
+	for _, exp := range transitions {
+		reward += discount * float64(exp.Reward)
+		discount *= a.gamma
+	}
+	return Experience{
+		State:     first.State,
+		NextState: last.NextState,
+		Action:    first.Action,
+		Reward:    int(math.Round(reward)),
+		Done:      last.Done,
+		NextMask:  last.NextMask,
+		Truncated: last.Truncated,
+	}
+}
@@ -0,0 +1,36 @@
+// offline.go
+package dqn
+
+// FitOffline trains the Q-network purely from logged experiences, without an
+// environment. Each epoch visits every experience once in mini-batches,
+// shuffled with the replay stream. It returns the mean loss of every epoch.
+// The experiences are one-step transitions, so the agent must not be
+// configured with NSteps above one.
+func (d *DQN) FitOffline(experiences []Experience, epochs, batchSize int) []float64 {
+	if len(experiences) == 0 {
+		panic("Cannot fit on an empty set of experiences")
+	}
+	if d.nSteps > 1 {
+		panic("Offline fitting needs one-step targets")
+	}
+	if batchSize <= 0 {
+		panic("Offline batch size must be positive")
+	}
+
+	losses := make([]float64, epochs)
+	for epoch := range losses {
+		order := d.replayRand.Perm(len(experiences))
+		batches := 0
+		for start := 0; start < len(order); start += batchSize {
+			end := min(start+batchSize, len(order))
+			batch := make([]Experience, 0, end-start)
+			for _, i := range order[start:end] {
+				batch = append(batch, experiences[i])
+			}
+			losses[epoch] += d.TrainBatch(batch).Loss
+			batches++
+		}
+		losses[epoch] /= float64(batches)
+	}
+	return losses
+}
@@ -0,0 +1,131 @@
+// optimizer.go
+package dqn
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// adam holds the moment estimates of the Adam optimizer.
+type adam struct {
+	beta1, beta2, eps float64
+	m, v              *gradients
+	t                 int
+}
+
+// newAdam initializes Adam with the usual defaults.
+func newAdam() *adam {
+	return &adam{beta1: 0.9, beta2: 0.999, eps: 1e-8}
+}
+
+// step updates the moment estimates with g and returns the bias-corrected
+// update direction, to be applied with the learning rate.
+func (a *adam) step(g *gradients) *gradients {
+	if a.m == nil {
+		a.m = g.clone()
+		a.m.scale(0)
+		a.v = a.m.clone()
+	}
+	a.t++
+
+	squared := g.clone()
+	squared.w1.MulElem(g.w1, g.w1)
+	squared.b1.MulElemVec(g.b1, g.b1)
+	squared.w2.MulElem(g.w2, g.w2)
+	squared.b2.MulElemVec(g.b2, g.b2)
+
+	a.m.scale(a.beta1)
+	g = g.clone()
+	g.scale(1 - a.beta1)
+	a.m.add(g)
+	a.v.scale(a.beta2)
+	squared.scale(1 - a.beta2)
+	a.v.add(squared)
+
+	c1 := 1 - math.Pow(a.beta1, float64(a.t))
+	c2 := 1 - math.Pow(a.beta2, float64(a.t))
+	direction := func(m, v float64) float64 {
+		return (m / c1) / (math.Sqrt(v/c2) + a.eps)
+	}
+	update := a.m.clone()
+	update.w1.Apply(func(i, j int, m float64) float64 { return direction(m, a.v.w1.At(i, j)) }, a.m.w1)
+	update.w2.Apply(func(i, j int, m float64) float64 { return direction(m, a.v.w2.At(i, j)) }, a.m.w2)
+	for i := 0; i < update.b1.Len(); i++ {
+		update.b1.SetVec(i, direction(a.m.b1.AtVec(i), a.v.b1.AtVec(i)))
+	}
+	for i := 0; i < update.b2.Len(); i++ {
+		update.b2.SetVec(i, direction(a.m.b2.AtVec(i), a.v.b2.AtVec(i)))
+	}
+	return update
+}
+
+// dampen multiplies the moment estimates by factor. A zero factor resets
+// the optimizer, including its bias correction.
+func (a *adam) dampen(factor float64) {
+	if factor == 0 {
+		a.m, a.v, a.t = nil, nil, 0
+		return
+	}
+	if a.m != nil {
+		a.m.scale(factor)
+		a.v.scale(factor)
+	}
+}
+
+// adamState is the serialized form of the Adam moment estimates.
+type adamState struct {
+	M, V [4][]byte
+	T    int
+}
+
+// MarshalBinary encodes the moment estimates for checkpoints.
+func (a *adam) MarshalBinary() ([]byte, error) {
+	state := adamState{T: a.t}
+	if a.m != nil {
+		for _, pair := range []struct {
+			g   *gradients
+			out *[4][]byte
+		}{{a.m, &state.M}, {a.v, &state.V}} {
+			for i, p := range []interface{ MarshalBinary() ([]byte, error) }{pair.g.w1, pair.g.b1, pair.g.w2, pair.g.b2} {
+				data, err := p.MarshalBinary()
+				if err != nil {
+					return nil, err
+				}
+				pair.out[i] = data
+			}
+		}
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(state)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary restores moment estimates encoded by MarshalBinary.
+func (a *adam) UnmarshalBinary(data []byte) error {
+	var state adamState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+		return err
+	}
+	a.m, a.v, a.t = nil, nil, state.T
+	if state.M[0] == nil {
+		return nil
+	}
+	decode := func(raw [4][]byte) (*gradients, error) {
+		g := &gradients{w1: &mat.Dense{}, b1: &mat.VecDense{}, w2: &mat.Dense{}, b2: &mat.VecDense{}}
+		for i, p := range []interface{ UnmarshalBinary([]byte) error }{g.w1, g.b1, g.w2, g.b2} {
+			if err := p.UnmarshalBinary(raw[i]); err != nil {
+				return nil, err
+			}
+		}
+		return g, nil
+	}
+	var err error
+	if a.m, err = decode(state.M); err != nil {
+		return err
+	}
+	a.v, err = decode(state.V)
+	return err
+}
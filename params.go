@@ -0,0 +1,117 @@
+// params.go
+package dqn
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// ParamRange is a range from which a parameter is drawn uniformly at the
+// start of every episode, for domain randomization.
+type ParamRange struct {
+	Min, Max float64
+}
+
+// EnvParams declares the parameters of a Configurable environment: fixed
+// values and randomized ranges.
+type EnvParams struct {
+	Values map[string]float64
+	Ranges map[string]ParamRange
+}
+
+// empty reports whether no parameters are declared.
+func (p EnvParams) empty() bool {
+	return len(p.Values) == 0 && len(p.Ranges) == 0
+}
+
+// apply sets the fixed parameters and samples the randomized ones, in name
+// order so that a seeded rng gives reproducible parameters.
+func (p EnvParams) apply(env Configurable, rng *rand.Rand) error {
+	for _, name := range sortedKeys(p.Values) {
+		if err := env.SetParam(name, p.Values[name]); err != nil {
+			return fmt.Errorf("dqn: parameter %s: %w", name, err)
+		}
+	}
+	for _, name := range sortedKeys(p.Ranges) {
+		r := p.Ranges[name]
+		if err := env.SetParam(name, r.Min+rng.Float64()*(r.Max-r.Min)); err != nil {
+			return fmt.Errorf("dqn: parameter %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// paramEnv applies the training or evaluation parameters to an environment
+// at every Reset.
+type paramEnv struct {
+	Environment
+	target      Configurable
+	train, eval EnvParams
+	evaluating  bool
+	rng         *rand.Rand
+	err         error
+}
+
+// newParamEnv wraps env, which must be Configurable, with the parameters of cfg.
+func newParamEnv(env Environment, cfg Config) *paramEnv {
+	target, ok := env.(Configurable)
+	if !ok {
+		panic("Environment parameters require a Configurable environment")
+	}
+	return &paramEnv{
+		Environment: env,
+		target:      target,
+		train:       cfg.TrainParams,
+		eval:        cfg.EvalParams,
+		rng:         rand.New(newPCGSource(cfg.subsystemSeed(0, paramStream))),
+	}
+}
+
+func (e *paramEnv) Reset() []float64 {
+	params := e.train
+	if e.evaluating {
+		params = e.eval
+	}
+	if err := params.apply(e.target, e.rng); err != nil && e.err == nil {
+		e.err = err
+	}
+	return e.Environment.Reset()
+}
+
+// Seed seeds the parameter ranges and the wrapped environment, if it is a Seeder.
+func (e *paramEnv) Seed(seed int64) {
+	e.rng.Seed(seed)
+	if seeder, ok := e.Environment.(Seeder); ok {
+		seeder.Seed(seed)
+	}
+}
+
+// Err returns the first error setting a parameter, or the error of the
+// wrapped environment.
+func (e *paramEnv) Err() error {
+	if e.err != nil {
+		return e.err
+	}
+	return envErr(e.Environment)
+}
+
+// Interrupted reports whether the last Step of the wrapped environment was interrupted.
+func (e *paramEnv) Interrupted() bool {
+	return interrupted(e.Environment)
+}
+
+// Truncated reports whether the last Step of the wrapped environment truncated the episode.
+func (e *paramEnv) Truncated() bool {
+	return truncated(e.Environment)
+}
@@ -0,0 +1,209 @@
+// pipeline.go
+package dqn
+
+import "math"
+
+// FeatureStep is a single declarative step of a feature Pipeline. Only the
+// fields used by its Op are set; use the constructor functions to build steps.
+type FeatureStep struct {
+	Op         string
+	Indices    []int     // select
+	Shift      []float64 // scale
+	Scale      []float64 // scale
+	Min, Max   float64   // clip
+	Index      int       // onehot
+	Categories int       // onehot
+	Lags       int       // lag
+	Weights    []float64 // encode, row-major with one row per output
+	Biases     []float64 // encode
+	Activation string    // encode
+}
+
+// SelectStep keeps only the features at the given indices, in that order.
+func SelectStep(indices ...int) FeatureStep {
+	return FeatureStep{Op: "select", Indices: indices}
+}
+
+// ScaleStep maps every feature x[i] to (x[i] - shift[i]) / scale[i].
+func ScaleStep(shift, scale []float64) FeatureStep {
+	return FeatureStep{Op: "scale", Shift: shift, Scale: scale}
+}
+
+// ClipStep clips every feature to [min, max].
+func ClipStep(min, max float64) FeatureStep {
+	return FeatureStep{Op: "clip", Min: min, Max: max}
+}
+
+// OneHotStep replaces the integer feature at index with a one-hot encoding of
+// the given number of categories.
+func OneHotStep(index, categories int) FeatureStep {
+	return FeatureStep{Op: "onehot", Index: index, Categories: categories}
+}
+
+// LagStep appends the features of the previous lags observations of the
+// episode, zero-filled at the start of an episode.
+func LagStep(lags int) FeatureStep {
+	return FeatureStep{Op: "lag", Lags: lags}
+}
+
+// Pipeline transforms raw observations into network inputs. It is saved
+// with policies so that serving uses exactly the features used in training.
+type Pipeline struct {
+	Steps []FeatureStep
+	// history holds the previous outputs of each lag step
+	history map[int][][]float64
+}
+
+// NewPipeline initializes a new Pipeline from steps.
+func NewPipeline(steps ...FeatureStep) *Pipeline {
+	return &Pipeline{Steps: steps}
+}
+
+// Clone returns a copy of the pipeline with fresh lag state.
+func (p *Pipeline) Clone() *Pipeline {
+	return &Pipeline{Steps: p.Steps}
+}
+
+// Reset clears the lag state at the start of an episode.
+func (p *Pipeline) Reset() {
+	p.history = nil
+}
+
+// OutputSize returns the number of features produced from inputSize raw features.
+func (p *Pipeline) OutputSize(inputSize int) int {
+	return len(p.Clone().Transform(make([]float64, inputSize)))
+}
+
+// Transform applies the pipeline to the next raw observation of the
+// episode, which lag steps record.
+func (p *Pipeline) Transform(state []float64) []float64 {
+	return p.transform(state, true)
+}
+
+// Peek applies the pipeline to a raw observation without recording it, so
+// that the lag state is unchanged.
+func (p *Pipeline) Peek(state []float64) []float64 {
+	return p.transform(state, false)
+}
+
+// transform applies the pipeline, recording state in the lag history if record is set.
+func (p *Pipeline) transform(state []float64, record bool) []float64 {
+	x := append([]float64(nil), state...)
+	for i, step := range p.Steps {
+		switch step.Op {
+		case "select":
+			selected := make([]float64, len(step.Indices))
+			for j, idx := range step.Indices {
+				selected[j] = x[idx]
+			}
+			x = selected
+		case "scale":
+			for j := range x {
+				x[j] = (x[j] - step.Shift[j]) / step.Scale[j]
+			}
+		case "clip":
+			for j := range x {
+				x[j] = math.Max(step.Min, math.Min(x[j], step.Max))
+			}
+		case "onehot":
+			encoded := make([]float64, step.Categories)
+			if c := int(x[step.Index]); c >= 0 && c < step.Categories {
+				encoded[c] = 1
+			}
+			x = append(append(append([]float64(nil), x[:step.Index]...), encoded...), x[step.Index+1:]...)
+		case "lag":
+			x = p.lag(i, step.Lags, x, record)
+		case "encode":
+			x = encode(step, x)
+		default:
+			panic("Unknown feature step " + step.Op)
+		}
+	}
+	return x
+}
+
+// encode applies the single layer of an encode step to x.
+func encode(step FeatureStep, x []float64) []float64 {
+	activation, ok := activations[step.Activation]
+	if !ok {
+		panic("Unknown activation " + step.Activation)
+	}
+	if len(step.Weights) != len(step.Biases)*len(x) {
+		panic("Input size does not match encoder input size")
+	}
+	out := make([]float64, len(step.Biases))
+	for j := range out {
+		sum := step.Biases[j]
+		for k, v := range x {
+			sum += step.Weights[j*len(x)+k] * v
+		}
+		out[j] = activation(sum)
+	}
+	return out
+}
+
+// lag appends the previous observations seen by step i and, if record is
+// set, records x.
+func (p *Pipeline) lag(i, lags int, x []float64, record bool) []float64 {
+	if p.history == nil {
+		p.history = make(map[int][][]float64)
+	}
+	past := p.history[i]
+	out := append([]float64(nil), x...)
+	for k := 1; k <= lags; k++ {
+		if k <= len(past) {
+			out = append(out, past[len(past)-k]...)
+		} else {
+			out = append(out, make([]float64, len(x))...)
+		}
+	}
+	if !record {
+		return out
+	}
+	past = append(past, x)
+	if len(past) > lags {
+		past = past[len(past)-lags:]
+	}
+	p.history[i] = past
+	return out
+}
+
+// Wrap returns an environment whose states are transformed by a copy of the pipeline.
+func (p *Pipeline) Wrap(env Environment) Environment {
+	return &pipelineEnv{Environment: env, pipeline: p.Clone()}
+}
+
+// pipelineEnv transforms the states of an environment with a pipeline.
+type pipelineEnv struct {
+	Environment
+	pipeline *Pipeline
+}
+
+func (e *pipelineEnv) StateSize() int {
+	return e.pipeline.OutputSize(e.Environment.StateSize())
+}
+
+func (e *pipelineEnv) Reset() []float64 {
+	e.pipeline.Reset()
+	return e.pipeline.Transform(e.Environment.Reset())
+}
+
+func (e *pipelineEnv) Step(action int) ([]float64, float64, bool) {
+	state, reward, done := e.Environment.Step(action)
+	return e.pipeline.Transform(state), reward, done
+}
+
+// Err returns the error of the wrapped environment, if it keeps one.
+func (e *pipelineEnv) Err() error {
+	return envErr(e.Environment)
+}
+
+// Interrupted reports whether the last Step of the wrapped environment was interrupted.
+func (e *pipelineEnv) Interrupted() bool {
+	return interrupted(e.Environment)
+}
+
+// Truncated reports whether the last Step of the wrapped environment truncated the episode.
+func (e *pipelineEnv) Truncated() bool {
+	return truncated(e.Environment)
+}
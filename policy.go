@@ -0,0 +1,127 @@
+// policy.go
+package dqn
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// Policy is a trained greedy policy, detached from the agent that produced it.
+type Policy struct {
+	qNetwork *QNetwork
+	pipeline *Pipeline
+	// limiter masks the actions that reached their limits, nil without limits
+	limiter *ActionLimiter
+}
+
+// Policy returns a snapshot of the agent's current greedy policy.
+func (d *DQN) Policy() *Policy {
+	return &Policy{qNetwork: d.qNetwork.Clone()}
+}
+
+// WithPipeline returns a copy of the policy that transforms raw observations
+// with the pipeline before evaluating the network.
+func (p *Policy) WithPipeline(pipeline *Pipeline) *Policy {
+	return (&Policy{qNetwork: p.qNetwork, pipeline: pipeline.Clone()}).WithActionLimits(p.ActionLimits()...)
+}
+
+// WithActionLimits returns a copy of the policy that never chooses an action
+// beyond its limits within an episode, like the Trainer while training, so
+// that evaluation and deployment follow the same constraints.
+func (p *Policy) WithActionLimits(limits ...ActionLimit) *Policy {
+	policy := &Policy{qNetwork: p.qNetwork, pipeline: p.pipeline}
+	if len(limits) > 0 {
+		policy.limiter = NewActionLimiter(p.qNetwork.outputSize, limits...)
+	}
+	return policy
+}
+
+// ActionLimits returns the action limits of the policy, or nil if it has none.
+func (p *Policy) ActionLimits() []ActionLimit {
+	if p.limiter == nil {
+		return nil
+	}
+	return p.limiter.limits
+}
+
+// Pipeline returns the feature pipeline of the policy, or nil if it has none.
+func (p *Policy) Pipeline() *Pipeline {
+	return p.pipeline
+}
+
+// Reset clears per-episode state, such as lag features and recent actions.
+// Call it at the start of every episode when the policy has a pipeline or
+// action limits.
+func (p *Policy) Reset() {
+	if p.pipeline != nil {
+		p.pipeline.Reset()
+	}
+	if p.limiter != nil {
+		p.limiter.Reset()
+	}
+}
+
+// Act returns the action with the highest Q-value for the next raw state of
+// the episode among those allowed by the action limits, advancing lag
+// features and the recent actions.
+func (p *Policy) Act(state []float64) int {
+	if p.limiter == nil {
+		return Argmax(p.step(state))
+	}
+	action := MaskedArgmax(p.step(state), p.limiter.Mask())
+	p.limiter.Record(action)
+	return action
+}
+
+// QValues returns the Q-values for a given raw state. It does not advance
+// lag features, so it can be called before Act on the same state.
+func (p *Policy) QValues(state []float64) []float64 {
+	if p.pipeline != nil {
+		state = p.pipeline.Peek(state)
+	}
+	return p.qNetwork.Predict(state)
+}
+
+// step returns the Q-values for the next raw state of the episode,
+// advancing lag features like Act.
+func (p *Policy) step(state []float64) []float64 {
+	if p.pipeline != nil {
+		state = p.pipeline.Transform(state)
+	}
+	return p.qNetwork.Predict(state)
+}
+
+// policyState is the serialized form of a Policy.
+type policyState struct {
+	Network      networkState
+	Pipeline     *Pipeline
+	ActionLimits []ActionLimit
+}
+
+// Save writes the policy, including its feature pipeline and action limits, to w.
+func (p *Policy) Save(w io.Writer) error {
+	network, err := p.qNetwork.state()
+	if err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(policyState{Network: network, Pipeline: p.pipeline, ActionLimits: p.ActionLimits()})
+}
+
+// LoadPolicy reads a policy written by Policy.Save.
+func LoadPolicy(r io.Reader) (*Policy, error) {
+	var state policyState
+	if err := gob.NewDecoder(r).Decode(&state); err != nil {
+		return nil, err
+	}
+	qNetwork, err := networkFromState(state.Network)
+	if err != nil {
+		return nil, err
+	}
+	for _, limit := range state.ActionLimits {
+		if limit.check(qNetwork.outputSize) != "" {
+			return nil, fmt.Errorf("dqn: invalid action limit %+v", limit)
+		}
+	}
+	return (&Policy{qNetwork: qNetwork, pipeline: state.Pipeline}).WithActionLimits(state.ActionLimits...), nil
+}
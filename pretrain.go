@@ -0,0 +1,84 @@
+// pretrain.go
+package dqn
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// ObservationSequences splits experiences, e.g. from DQN.ReplaySnapshot, into
+// sequences of consecutive observations. A sequence ends at a terminal
+// transition or where an experience does not start from the previous next
+// state, as happens after sub-sampling or eviction, and includes the final
+// next state.
+func ObservationSequences(experiences []Experience) [][][]float64 {
+	var sequences [][][]float64
+	var current [][]float64
+	for i, exp := range experiences {
+		current = append(current, exp.State)
+		last := i == len(experiences)-1
+		if exp.Done || last || !slices.Equal(experiences[i+1].State, exp.NextState) {
+			current = append(current, exp.NextState)
+			sequences = append(sequences, current)
+			current = nil
+		}
+	}
+	return sequences
+}
+
+// WriteObservationsCSV writes the observation sequences of experiences as CSV
+// for unsupervised pretraining, one row per observation with the columns
+// sequence, step and s0 to sN.
+func WriteObservationsCSV(w io.Writer, experiences []Experience) error {
+	cw := csv.NewWriter(w)
+	sequences := ObservationSequences(experiences)
+	if len(sequences) > 0 {
+		header := []string{"sequence", "step"}
+		for i := range sequences[0][0] {
+			header = append(header, "s"+strconv.Itoa(i))
+		}
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+	for s, sequence := range sequences {
+		for step, obs := range sequence {
+			row := []string{strconv.Itoa(s), strconv.Itoa(step)}
+			for _, v := range obs {
+				row = append(row, strconv.FormatFloat(v, 'g', -1, 64))
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Encoder holds the weights of a pretrained encoder layer, one row per
+// hidden unit, as exported by an external pretraining script.
+type Encoder struct {
+	Weights [][]float64 `json:"weights"`
+	Biases  []float64   `json:"biases"`
+}
+
+// ReadEncoderJSON reads an encoder from a JSON object with the fields weights and biases.
+func ReadEncoderJSON(r io.Reader) (Encoder, error) {
+	var e Encoder
+	err := json.NewDecoder(r).Decode(&e)
+	return e, err
+}
+
+// LoadEncoder initializes the hidden layer of the network with a pretrained
+// encoder. The encoder must have been trained with the same activation as
+// the network's hidden layer; the output layer is left untouched.
+func (q *QNetwork) LoadEncoder(e Encoder) error {
+	if err := q.SetWeights(0, e.Weights); err != nil {
+		return err
+	}
+	return q.SetBiases(0, e.Biases)
+}
@@ -0,0 +1,66 @@
+// probes.go
+package dqn
+
+import (
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// ProbeRecord holds the Q-values of the probe states after a given number of updates.
+type ProbeRecord struct {
+	Update      int
+	QValues     [][]float64
+	AverageMaxQ float64
+}
+
+// SetProbeStates registers a fixed set of held-out states whose Q-values are
+// recorded every n updates.
+func (d *DQN) SetProbeStates(states [][]float64, every int) {
+	d.probeStates = states
+	d.probeEvery = every
+}
+
+// ProbeHistory returns the recorded probe Q-values.
+func (d *DQN) ProbeHistory() []ProbeRecord {
+	return d.probeHistory
+}
+
+// recordProbes records the probe Q-values if a recording is due.
+func (d *DQN) recordProbes() {
+	if len(d.probeStates) == 0 || d.probeEvery <= 0 || d.updates%d.probeEvery != 0 {
+		return
+	}
+
+	record := ProbeRecord{Update: d.updates, QValues: make([][]float64, len(d.probeStates))}
+	for i, state := range d.probeStates {
+		record.QValues[i] = d.qNetwork.Predict(state)
+		record.AverageMaxQ += Max(record.QValues[i])
+	}
+	record.AverageMaxQ /= float64(len(d.probeStates))
+	d.probeHistory = append(d.probeHistory, record)
+}
+
+// PlotProbeHistory plots the average max Q-value on the probe states over
+// updates and saves it to path. The image format is taken from the file extension.
+func PlotProbeHistory(records []ProbeRecord, path string) error {
+	p := plot.New()
+
+	p.Title.Text = "Average Max Q on Probe States"
+	p.X.Label.Text = "Update"
+	p.Y.Label.Text = "Average Max Q"
+
+	data := make(plotter.XYs, len(records))
+	for i, record := range records {
+		data[i].X = float64(record.Update)
+		data[i].Y = record.AverageMaxQ
+	}
+
+	line, err := plotter.NewLine(data)
+	if err != nil {
+		return err
+	}
+	p.Add(line)
+
+	return p.Save(8*vg.Inch, 4*vg.Inch, path)
+}
@@ -0,0 +1,101 @@
+// profile.go
+package dqn
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Phases of an update timed by the profiler
+const (
+	phaseReplay    = iota // storing transitions, including priority updates
+	phaseSample           // sampling batches from the replay buffer
+	phaseForward          // predicting Q-values of the current states
+	phaseTarget           // computing bootstrapped targets
+	phaseBackward         // computing gradients
+	phaseOptimizer        // optimizer step and weight update
+	numPhases
+)
+
+var phaseNames = [numPhases]string{"replay", "sample", "forward", "target", "backward", "optimizer"}
+
+// profiler accumulates the time spent in each phase of training. A nil
+// profiler records nothing.
+type profiler struct {
+	totals  [numPhases]time.Duration
+	calls   [numPhases]int
+	updates int
+}
+
+// start returns the start time of a phase, or the zero time when profiling is off.
+func (p *profiler) start() time.Time {
+	if p == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// stop adds the time since start to phase.
+func (p *profiler) stop(phase int, start time.Time) {
+	if p == nil {
+		return
+	}
+	p.totals[phase] += time.Since(start)
+	p.calls[phase]++
+}
+
+// PhaseTiming is the time spent in one phase of training.
+type PhaseTiming struct {
+	Phase     string
+	Total     time.Duration
+	Calls     int
+	PerUpdate time.Duration
+	Share     float64 // fraction of the profiled time
+}
+
+// ProfileReport breaks down the time spent training by phase: replay
+// storage, batch sampling, forward pass, target computation, backward pass
+// and optimizer step.
+type ProfileReport struct {
+	Updates int
+	Total   time.Duration
+	Phases  []PhaseTiming // slowest first
+}
+
+// Profile returns the timing breakdown accumulated so far. It is empty
+// unless profiling was enabled with WithProfiling.
+func (d *DQN) Profile() ProfileReport {
+	p := d.profiler
+	if p == nil {
+		return ProfileReport{}
+	}
+	report := ProfileReport{Updates: p.updates}
+	for _, total := range p.totals {
+		report.Total += total
+	}
+	for phase, total := range p.totals {
+		timing := PhaseTiming{Phase: phaseNames[phase], Total: total, Calls: p.calls[phase]}
+		if p.updates > 0 {
+			timing.PerUpdate = total / time.Duration(p.updates)
+		}
+		if report.Total > 0 {
+			timing.Share = float64(total) / float64(report.Total)
+		}
+		report.Phases = append(report.Phases, timing)
+	}
+	sort.SliceStable(report.Phases, func(i, j int) bool { return report.Phases[i].Total > report.Phases[j].Total })
+	return report
+}
+
+// String formats the report as a table.
+func (r ProfileReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Updates: %d, profiled time: %s\n", r.Updates, r.Total)
+	fmt.Fprintf(&b, "%-10s %12s %10s %12s %6s\n", "phase", "total", "calls", "per update", "share")
+	for _, p := range r.Phases {
+		fmt.Fprintf(&b, "%-10s %12s %10d %12s %5.1f%%\n", p.Phase, p.Total.Round(time.Microsecond), p.Calls, p.PerUpdate, 100*p.Share)
+	}
+	return b.String()
+}
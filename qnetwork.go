@@ -21,27 +21,31 @@ type QNetwork struct {
 	w2         *mat.Dense
 	b2         *mat.VecDense
 	activation Activation
+	// initializer is the registered name of the weight initializer
+	initializer string
+	// Regularization, applied only while training
+	dropout     float64
+	weightDecay float64
 }
 
 // NewQNetwork initializes a new QNetwork with random weights.
 func NewQNetwork(inputSize, hiddenSize, outputSize int, activation Activation) *QNetwork {
+	return newQNetwork(inputSize, hiddenSize, outputSize, activation, "xavier", rand.Float64)
+}
+
+// newQNetwork initializes a new QNetwork with the named initializer and
+// weights drawn using uniform, a source of uniform numbers in [0, 1).
+func newQNetwork(inputSize, hiddenSize, outputSize int, activation Activation, initializer string, uniform func() float64) *QNetwork {
+	init, ok := initializers[initializer]
+	if !ok {
+		panic("Unknown initializer " + initializer)
+	}
 	w1 := mat.NewDense(hiddenSize, inputSize, nil)
 	b1 := mat.NewVecDense(hiddenSize, nil)
 	w2 := mat.NewDense(outputSize, hiddenSize, nil)
 	b2 := mat.NewVecDense(outputSize, nil)
-
-	// Xavier initialization
-	bound1 := math.Sqrt(6.0 / float64(inputSize+hiddenSize))
-	bound2 := math.Sqrt(6.0 / float64(hiddenSize+outputSize))
-
-	w1.Apply(func(_, _ int, _ float64) float64 { return rand.Float64()*2*bound1 - bound1 }, w1)
-	for i := 0; i < hiddenSize; i++ {
-		b1.SetVec(i, rand.Float64()*2*bound1-bound1)
-	}
-	w2.Apply(func(_, _ int, _ float64) float64 { return rand.Float64()*2*bound2 - bound2 }, w2)
-	for i := 0; i < outputSize; i++ {
-		b2.SetVec(i, rand.Float64()*2*bound2-bound2)
-	}
+	init(w1, b1, uniform)
+	init(w2, b2, uniform)
 
 	return &QNetwork{
 		inputSize:  inputSize,
@@ -52,11 +56,96 @@ func NewQNetwork(inputSize, hiddenSize, outputSize int, activation Activation) *
 		w2:         w2,
 		b2:         b2,
 		activation: activation,
+
+		initializer: initializer,
+	}
+}
+
+// Clone returns a deep copy of the network.
+func (q *QNetwork) Clone() *QNetwork {
+	return &QNetwork{
+		inputSize:  q.inputSize,
+		hiddenSize: q.hiddenSize,
+		outputSize: q.outputSize,
+		w1:         mat.DenseCopyOf(q.w1),
+		b1:         mat.VecDenseCopyOf(q.b1),
+		w2:         mat.DenseCopyOf(q.w2),
+		b2:         mat.VecDenseCopyOf(q.b2),
+		activation: q.activation,
+
+		initializer: q.initializer,
+		dropout:     q.dropout,
+		weightDecay: q.weightDecay,
+	}
+}
+
+// perturbed returns a copy of the network with Gaussian noise added to every
+// weight and bias, with a standard deviation given by the fan-in of the layer.
+func (q *QNetwork) perturbed(rng *rand.Rand, stddev func(fanIn int) float64) *QNetwork {
+	p := q.Clone()
+	s1, s2 := stddev(q.inputSize), stddev(q.hiddenSize)
+	noise := func(s float64) func(_, _ int, v float64) float64 {
+		return func(_, _ int, v float64) float64 { return v + rng.NormFloat64()*s }
 	}
+	p.w1.Apply(noise(s1), p.w1)
+	p.w2.Apply(noise(s2), p.w2)
+	for i := 0; i < q.hiddenSize; i++ {
+		p.b1.SetVec(i, p.b1.AtVec(i)+rng.NormFloat64()*s1)
+	}
+	for i := 0; i < q.outputSize; i++ {
+		p.b2.SetVec(i, p.b2.AtVec(i)+rng.NormFloat64()*s2)
+	}
+	return p
+}
+
+// SetDropout sets the probability of dropping each hidden unit during
+// training. Predict never applies dropout.
+func (q *QNetwork) SetDropout(p float64) {
+	q.dropout = p
+}
+
+// SetWeightDecay sets the L2 penalty applied to the weights in every update.
+func (q *QNetwork) SetWeightDecay(lambda float64) {
+	q.weightDecay = lambda
 }
 
 // Predict returns Q-values for a given state.
 func (q *QNetwork) Predict(state []float64) []float64 {
+	return q.forward(state, nil)
+}
+
+// dropoutMask samples an inverted dropout mask for the hidden layer from
+// uniform, or returns nil when dropout is disabled.
+func (q *QNetwork) dropoutMask(uniform func() float64) *mat.VecDense {
+	if q.dropout <= 0 {
+		return nil
+	}
+	mask := mat.NewVecDense(q.hiddenSize, nil)
+	for i := 0; i < q.hiddenSize; i++ {
+		if uniform() >= q.dropout {
+			mask.SetVec(i, 1/(1-q.dropout))
+		}
+	}
+	return mask
+}
+
+// forward computes Q-values with an optional dropout mask on the hidden layer.
+func (q *QNetwork) forward(state []float64, mask *mat.VecDense) []float64 {
+	h := q.hidden(state)
+	if mask != nil {
+		h.MulElemVec(h, mask)
+	}
+
+	// Output layer
+	out := mat.NewVecDense(q.outputSize, nil)
+	out.MulVec(q.w2, h)
+	out.AddVec(out, q.b2)
+
+	return out.RawVector().Data
+}
+
+// hidden computes the activations of the hidden layer.
+func (q *QNetwork) hidden(state []float64) *mat.VecDense {
 	if len(state) != q.inputSize {
 		panic("Input state size does not match network input size")
 	}
@@ -73,13 +162,7 @@ func (q *QNetwork) Predict(state []float64) []float64 {
 	for i := 0; i < h.Len(); i++ {
 		h.SetVec(i, q.activation(h.AtVec(i)))
 	}
-
-	// Output layer
-	out := mat.NewVecDense(q.outputSize, nil)
-	out.MulVec(q.w2, h)
-	out.AddVec(out, q.b2)
-
-	return out.RawVector().Data
+	return h
 }
 
 // Loss computes the mean squared error loss.
@@ -96,8 +179,23 @@ func (q *QNetwork) Loss(predictions, targets []float64) float64 {
 	return loss / float64(len(predictions))
 }
 
-// Backward computes gradients and updates the network weights.
-func (q *QNetwork) Backward(state, prediction, target []float64, learningRate float64) {
+// Backward computes gradients and updates the network weights. It returns
+// the L2 norm of the gradient over all weights and biases.
+func (q *QNetwork) Backward(state, prediction, target []float64, learningRate float64) float64 {
+	g := q.gradients(state, prediction, target, nil)
+	q.apply(g, learningRate)
+	return g.norm()
+}
+
+// gradients holds the gradient of the loss with respect to every parameter.
+type gradients struct {
+	w1, w2 *mat.Dense
+	b1, b2 *mat.VecDense
+}
+
+// gradients computes the gradients of the squared error between prediction
+// and target. mask is the dropout mask the prediction was computed with, if any.
+func (q *QNetwork) gradients(state, prediction, target []float64, mask *mat.VecDense) *gradients {
 	// Convert inputs to matrices
 	x := mat.NewVecDense(len(state), state)
 	y := mat.NewVecDense(len(target), target)
@@ -112,6 +210,11 @@ func (q *QNetwork) Backward(state, prediction, target []float64, learningRate fl
 	for i := 0; i < h.Len(); i++ {
 		h.SetVec(i, q.activation(h.AtVec(i)))
 	}
+	derivative := applyDerivative(h, q.activation)
+	if mask != nil {
+		h.MulElemVec(h, mask)
+		derivative.MulElemVec(derivative, mask)
+	}
 
 	// Compute gradients
 	dOut := mat.NewVecDense(q.outputSize, nil)
@@ -120,27 +223,71 @@ func (q *QNetwork) Backward(state, prediction, target []float64, learningRate fl
 	dW2 := mat.NewDense(q.outputSize, q.hiddenSize, nil)
 	dW2.Outer(1, dOut, h)
 
-	dB2 := dOut
-
 	dH := mat.NewVecDense(q.hiddenSize, nil)
 	dH.MulVec(q.w2.T(), dOut)
-	dH.MulElemVec(dH, applyDerivative(h, q.activation))
+	dH.MulElemVec(dH, derivative)
 
 	dW1 := mat.NewDense(q.hiddenSize, q.inputSize, nil)
 	dW1.Outer(1, dH, x)
 
-	dB1 := dH
+	return &gradients{w1: dW1, b1: dH, w2: dW2, b2: dOut}
+}
+
+// clone returns a deep copy of g.
+func (g *gradients) clone() *gradients {
+	return &gradients{
+		w1: mat.DenseCopyOf(g.w1),
+		b1: mat.VecDenseCopyOf(g.b1),
+		w2: mat.DenseCopyOf(g.w2),
+		b2: mat.VecDenseCopyOf(g.b2),
+	}
+}
 
-	// Update weights and biases
-	q.w2.Scale(-learningRate, dW2)
-	q.w2.Add(q.w2, dW2)
+// add accumulates other into g.
+func (g *gradients) add(other *gradients) {
+	g.w1.Add(g.w1, other.w1)
+	g.b1.AddVec(g.b1, other.b1)
+	g.w2.Add(g.w2, other.w2)
+	g.b2.AddVec(g.b2, other.b2)
+}
 
-	q.b2.AddScaledVec(q.b2, -learningRate, dB2)
+// scale multiplies every gradient by f.
+func (g *gradients) scale(f float64) {
+	g.w1.Scale(f, g.w1)
+	g.b1.ScaleVec(f, g.b1)
+	g.w2.Scale(f, g.w2)
+	g.b2.ScaleVec(f, g.b2)
+}
 
-	q.w1.Scale(-learningRate, dW1)
-	q.w1.Add(q.w1, dW1)
+// norm returns the L2 norm of the gradient over all parameters.
+func (g *gradients) norm() float64 {
+	w1, w2 := mat.Norm(g.w1, 2), mat.Norm(g.w2, 2)
+	return math.Sqrt(w1*w1 + mat.Dot(g.b1, g.b1) + w2*w2 + mat.Dot(g.b2, g.b2))
+}
+
+// dot returns the inner product of g and other over all parameters.
+func (g *gradients) dot(other *gradients) float64 {
+	var w1, w2 mat.Dense
+	w1.MulElem(g.w1, other.w1)
+	w2.MulElem(g.w2, other.w2)
+	return mat.Sum(&w1) + mat.Dot(g.b1, other.b1) + mat.Sum(&w2) + mat.Dot(g.b2, other.b2)
+}
 
-	q.b1.AddScaledVec(q.b1, -learningRate, dB1)
+// apply takes a gradient descent step, including weight decay on the weights.
+func (q *QNetwork) apply(g *gradients, learningRate float64) {
+	decay := 1 - learningRate*q.weightDecay
+
+	var step mat.Dense
+	step.Scale(learningRate, g.w2)
+	q.w2.Scale(decay, q.w2)
+	q.w2.Sub(q.w2, &step)
+	q.b2.AddScaledVec(q.b2, -learningRate, g.b2)
+
+	step.Reset()
+	step.Scale(learningRate, g.w1)
+	q.w1.Scale(decay, q.w1)
+	q.w1.Sub(q.w1, &step)
+	q.b1.AddScaledVec(q.b1, -learningRate, g.b1)
 }
 
 // applyDerivative applies the derivative of the activation function element-wise
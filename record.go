@@ -0,0 +1,79 @@
+// record.go
+package dqn
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Renderer is implemented by environments that can draw their current state.
+type Renderer interface {
+	Render() image.Image
+}
+
+// annotationHeight is the height in pixels of the text band added below each frame.
+const annotationHeight = 32
+
+// RecordEpisode runs one greedy episode of policy on env and writes it to w
+// as an animated GIF. Each frame is annotated with the step, the chosen
+// action and the Q-values the policy computed for that state. delay is the
+// time between frames in hundredths of a second.
+func RecordEpisode(w io.Writer, policy *Policy, env Environment, delay int) error {
+	renderer, ok := env.(Renderer)
+	if !ok {
+		return fmt.Errorf("dqn: environment does not implement Renderer")
+	}
+
+	anim := &gif.GIF{}
+	policy.Reset()
+	state := env.Reset()
+	for step, done := 0, false; !done; step++ {
+		qValues := policy.step(state)
+		action := Argmax(qValues)
+		anim.Image = append(anim.Image, annotate(renderer.Render(), step, action, qValues))
+		anim.Delay = append(anim.Delay, delay)
+		state, _, done = env.Step(action)
+	}
+	anim.Image = append(anim.Image, annotate(renderer.Render(), len(anim.Image), -1, nil))
+	anim.Delay = append(anim.Delay, delay)
+
+	return gif.EncodeAll(w, anim)
+}
+
+// annotate draws frame above a text band showing the step, action and Q-values.
+// An action of -1 marks the final frame.
+func annotate(frame image.Image, step, action int, qValues []float64) *image.Paletted {
+	bounds := frame.Bounds()
+	out := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()+annotationHeight), palette.Plan9)
+	draw.Draw(out, out.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.FloydSteinberg.Draw(out, image.Rect(0, 0, bounds.Dx(), bounds.Dy()), frame, bounds.Min)
+
+	line1 := fmt.Sprintf("step %d  action %d", step, action)
+	if action < 0 {
+		line1 = fmt.Sprintf("step %d  done", step)
+	}
+	line2 := "Q:"
+	for a, q := range qValues {
+		if a == action {
+			line2 += fmt.Sprintf(" [%.2f]", q)
+		} else {
+			line2 += fmt.Sprintf(" %.2f", q)
+		}
+	}
+
+	drawer := &font.Drawer{Dst: out, Src: image.NewUniform(color.Black), Face: basicfont.Face7x13}
+	for i, line := range []string{line1, line2} {
+		drawer.Dot = fixed.P(4, bounds.Dy()+13*(i+1))
+		drawer.DrawString(line)
+	}
+	return out
+}
@@ -0,0 +1,293 @@
+// gym.go
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Client talks to a Python Gymnasium server speaking the gym-http-api protocol.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	transport  *http.Transport
+	info       *ServerInfo // negotiated protocol, see Negotiate
+	retry      RetryPolicy
+}
+
+// NewClient initializes a new Client for the server at baseURL, e.g. "http://127.0.0.1:5000".
+func NewClient(baseURL string) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Transport: transport},
+		transport:  transport,
+	}
+}
+
+// space describes an action or observation space as reported by the server.
+type space struct {
+	Name  string `json:"name"`
+	N     int    `json:"n"`
+	Shape []int  `json:"shape"`
+}
+
+// Env is a remote environment implementing the dqn.Environment interface.
+//
+// Since Environment methods cannot return errors, a failed request ends the
+// episode and the first such error is kept and reported by Err. If the instance
+// is lost on the server, e.g. after a restart, a new instance is created:
+// Reset then proceeds on it, while Step ends the interrupted episode and
+// reports it with Interrupted, so that trainers do not learn from it.
+type Env struct {
+	client      *Client
+	envID       string
+	instanceID  string
+	reconnects  int
+	observation space
+	numActions  int
+	state       []float64
+	err         error
+	interrupted bool
+	// Negotiated features
+	capabilities Capabilities
+	seed         *int64
+	truncated    bool
+}
+
+// Make creates a new remote environment instance of envID, e.g. "CartPole-v1".
+// Only discrete action spaces are supported.
+func (c *Client) Make(envID string) (*Env, error) {
+	info, err := c.Negotiate()
+	if err != nil {
+		return nil, err
+	}
+
+	var created struct {
+		InstanceID string `json:"instance_id"`
+	}
+	if err := c.do(http.MethodPost, "/v1/envs/", map[string]string{"env_id": envID}, &created); err != nil {
+		return nil, err
+	}
+
+	env := &Env{client: c, envID: envID, instanceID: created.InstanceID, capabilities: info.Capabilities}
+
+	var actionSpace, observationSpace struct {
+		Info space `json:"info"`
+	}
+	if err := c.do(http.MethodGet, env.path("action_space"), nil, &actionSpace); err != nil {
+		return nil, err
+	}
+	if actionSpace.Info.Name != "Discrete" {
+		return nil, fmt.Errorf("remote: unsupported action space %q", actionSpace.Info.Name)
+	}
+	env.numActions = actionSpace.Info.N
+
+	if err := c.do(http.MethodGet, env.path("observation_space"), nil, &observationSpace); err != nil {
+		return nil, err
+	}
+	env.observation = observationSpace.Info
+	if env.StateSize() == 0 {
+		return nil, fmt.Errorf("remote: unsupported observation space %q", env.observation.Name)
+	}
+
+	return env, nil
+}
+
+// path returns the URL path of an instance endpoint.
+func (e *Env) path(endpoint string) string {
+	return "/v1/envs/" + e.instanceID + "/" + endpoint + "/"
+}
+
+// StateSize returns the length of the state vector. Discrete observations
+// are one-hot encoded.
+func (e *Env) StateSize() int {
+	switch e.observation.Name {
+	case "Discrete":
+		return e.observation.N
+	case "Box":
+		size := 1
+		for _, dim := range e.observation.Shape {
+			size *= dim
+		}
+		return size
+	}
+	return 0
+}
+
+// NumActions returns the number of discrete actions.
+func (e *Env) NumActions() int {
+	return e.numActions
+}
+
+// Capabilities returns the optional features supported by the server.
+func (e *Env) Capabilities() Capabilities {
+	return e.capabilities
+}
+
+// Seed seeds the environment at the next Reset. It has no effect if the
+// server does not support seeding.
+func (e *Env) Seed(seed int64) {
+	if e.capabilities.Seed {
+		e.seed = &seed
+	}
+}
+
+// Reset starts a new episode and returns the initial state.
+func (e *Env) Reset() []float64 {
+	var resp struct {
+		Observation json.RawMessage `json:"observation"`
+	}
+	var req interface{}
+	if e.seed != nil {
+		req = map[string]int64{"seed": *e.seed}
+		e.seed = nil
+	}
+	e.truncated, e.interrupted = false, false
+	err := e.client.do(http.MethodPost, e.path("reset"), req, &resp)
+	if notFound(err) {
+		if err = e.reconnect(); err == nil {
+			err = e.client.do(http.MethodPost, e.path("reset"), req, &resp)
+		}
+	}
+	if err != nil {
+		e.fail(err)
+		return make([]float64, e.StateSize())
+	}
+	e.state = e.decode(resp.Observation)
+	return e.state
+}
+
+// Step applies an action and returns the next state, the reward and whether
+// the episode is done, either terminated or truncated.
+func (e *Env) Step(action int) ([]float64, float64, bool) {
+	var resp struct {
+		Observation json.RawMessage `json:"observation"`
+		Reward      float64         `json:"reward"`
+		Done        bool            `json:"done"`
+		Truncated   bool            `json:"truncated"`
+	}
+	req := map[string]interface{}{"action": action, "render": false}
+	e.interrupted = false
+	if err := e.client.do(http.MethodPost, e.path("step"), req, &resp); err != nil {
+		if notFound(err) {
+			err = e.reconnect()
+		}
+		if err != nil {
+			e.fail(err)
+		}
+		e.interrupted = true
+		return e.state, 0, true
+	}
+	e.state = e.decode(resp.Observation)
+	e.truncated = e.capabilities.Truncation && resp.Truncated
+	return e.state, resp.Reward, resp.Done || e.truncated
+}
+
+// Truncated reports whether the last step ended the episode by truncation,
+// e.g. a time limit, rather than termination, so that trainers bootstrap
+// from its final state. It is always false if the server does not report
+// truncation.
+func (e *Env) Truncated() bool {
+	return e.truncated
+}
+
+// Err returns the first error encountered by Reset or Step, if any.
+func (e *Env) Err() error {
+	return e.err
+}
+
+// Interrupted reports whether the last Step failed or lost the instance, so
+// that the episode ended without a real final transition.
+func (e *Env) Interrupted() bool {
+	return e.interrupted
+}
+
+// fail records err unless an earlier error is still pending.
+func (e *Env) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// Close shuts down the remote environment instance.
+func (e *Env) Close() error {
+	return e.client.do(http.MethodPost, e.path("close"), nil, nil)
+}
+
+// decode converts a raw observation into a state vector.
+func (e *Env) decode(raw json.RawMessage) []float64 {
+	if e.observation.Name == "Discrete" {
+		var index int
+		if err := json.Unmarshal(raw, &index); err != nil {
+			e.fail(err)
+		}
+		state := make([]float64, e.observation.N)
+		if index >= 0 && index < len(state) {
+			state[index] = 1
+		}
+		return state
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		e.fail(err)
+	}
+	return flatten(value, make([]float64, 0, e.StateSize()))
+}
+
+// flatten appends the numbers of a nested JSON array to state.
+func flatten(value interface{}, state []float64) []float64 {
+	switch v := value.(type) {
+	case float64:
+		state = append(state, v)
+	case []interface{}:
+		for _, item := range v {
+			state = flatten(item, state)
+		}
+	}
+	return state
+}
+
+// doOnce sends a JSON request and decodes the JSON response into out.
+func (c *Client) doOnce(method, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return &statusError{method: method, path: path, status: resp.Status, code: resp.StatusCode}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// statusError is returned by do for unsuccessful responses.
+type statusError struct {
+	method, path, status string
+	code                 int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("remote: %s %s: %s", e.method, e.path, e.status)
+}
@@ -0,0 +1,233 @@
+// gym_test.go
+package remote
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iampaapa/dqn"
+)
+
+// flaky wraps a handler so that the next failures requests fail with status.
+func flaky(handler http.Handler, failures, status *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if *failures > 0 {
+			*failures--
+			w.WriteHeader(*status)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// newFakeServer returns an unstarted gym-http-api server with a single two-action
+// environment whose episodes last three steps. A versioned server supports
+// every capability, records the last seed and truncates episodes after two steps.
+func newFakeServer(t *testing.T, versioned bool, seed *int64) *httptest.Server {
+	steps := 0
+	mux := http.NewServeMux()
+	reply := func(w http.ResponseWriter, v interface{}) {
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			t.Error(err)
+		}
+	}
+	mux.HandleFunc("/v1/envs/", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]string{"instance_id": "abc"})
+	})
+	mux.HandleFunc("/v1/envs/abc/action_space/", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]interface{}{"info": map[string]interface{}{"name": "Discrete", "n": 2}})
+	})
+	mux.HandleFunc("/v1/envs/abc/observation_space/", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]interface{}{"info": map[string]interface{}{"name": "Box", "shape": []int{2, 2}}})
+	})
+	if versioned {
+		mux.HandleFunc("/v1/version/", func(w http.ResponseWriter, r *http.Request) {
+			reply(w, map[string]interface{}{
+				"version":      2,
+				"capabilities": map[string]bool{"render": true, "seed": true, "truncation": true},
+			})
+		})
+	}
+	mux.HandleFunc("/v1/envs/abc/reset/", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Seed *int64 `json:"seed"`
+		}
+		if json.NewDecoder(r.Body).Decode(&req) == nil && req.Seed != nil {
+			*seed = *req.Seed
+		}
+		steps = 0
+		reply(w, map[string]interface{}{"observation": [][]float64{{0, 0}, {0, 0}}})
+	})
+	mux.HandleFunc("/v1/envs/abc/step/", func(w http.ResponseWriter, r *http.Request) {
+		steps++
+		reply(w, map[string]interface{}{
+			"observation": [][]float64{{1, 2}, {3, 4}},
+			"reward":      1.0,
+			"done":        steps >= 3,
+			"truncated":   versioned && steps >= 2,
+		})
+	})
+	return httptest.NewUnstartedServer(mux)
+}
+
+func TestRemoteEnv(t *testing.T) {
+	server := newFakeServer(t, false, nil)
+	server.Start()
+	defer server.Close()
+
+	env, err := NewClient(server.URL).Make("Fake-v0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var _ dqn.Environment = env
+
+	if env.StateSize() != 4 || env.NumActions() != 2 {
+		t.Errorf("Unexpected dimensions %d, %d", env.StateSize(), env.NumActions())
+	}
+	returns := dqn.Evaluate(dqn.NewDQNForEnv(env), env, 2)
+	if returns[0] != 3 || returns[1] != 3 {
+		t.Errorf("Expected returns of 3, got %v", returns)
+	}
+	if env.Err() != nil {
+		t.Error(env.Err())
+	}
+}
+
+func TestNegotiation(t *testing.T) {
+	legacy := newFakeServer(t, false, nil)
+	legacy.Start()
+	defer legacy.Close()
+	info, err := NewClient(legacy.URL).Negotiate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != 0 || info.Capabilities != (Capabilities{}) {
+		t.Errorf("Expected a legacy server, got %+v", info)
+	}
+
+	var seed int64
+	server := newFakeServer(t, true, &seed)
+	server.Start()
+	defer server.Close()
+	env, err := NewClient(server.URL).Make("Fake-v0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !env.Capabilities().Seed || !env.Capabilities().Truncation {
+		t.Errorf("Expected negotiated capabilities, got %+v", env.Capabilities())
+	}
+	if info, _ := env.client.Negotiate(); info.Version != ProtocolVersion {
+		t.Errorf("Expected protocol version %d, got %d", ProtocolVersion, info.Version)
+	}
+
+	env.Seed(42)
+	env.Reset()
+	if seed != 42 {
+		t.Errorf("Expected the seed to be sent, got %d", seed)
+	}
+	env.Step(0)
+	if _, _, done := env.Step(0); !done || !env.Truncated() {
+		t.Errorf("Expected the episode to be truncated after two steps")
+	}
+}
+
+func TestRetryAndReconnect(t *testing.T) {
+	failures := 0
+	status := http.StatusServiceUnavailable
+	server := newFakeServer(t, false, nil)
+	server.Config.Handler = flaky(server.Config.Handler, &failures, &status)
+	server.Start()
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	client.SetRetryPolicy(RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond, Multiplier: 2})
+	failures = 2
+	env, err := client.Make("Fake-v0")
+	if err != nil {
+		t.Fatalf("Expected transient failures to be retried, got %v", err)
+	}
+
+	// A lost instance ends the episode and is replaced
+	env.Reset()
+	failures, status = 1, http.StatusNotFound
+	if _, _, done := env.Step(0); !done || !env.Interrupted() || env.Err() != nil || env.Reconnects() != 1 {
+		t.Errorf("Expected an interrupted episode and a reconnect, got done %v, error %v", done, env.Err())
+	}
+	if returns := dqn.Evaluate(dqn.NewDQNForEnv(env), env, 1); returns[0] != 3 {
+		t.Errorf("Expected a full episode after reconnecting, got return %v", returns[0])
+	}
+
+	failures, status = 4, http.StatusServiceUnavailable
+	env.Reset()
+	first := env.Err()
+	if first == nil {
+		t.Errorf("Expected an error once retries are exhausted")
+	}
+	failures, status = 1, http.StatusBadRequest
+	env.Step(0)
+	if env.Err() != first {
+		t.Errorf("Expected the first error to be kept, got %v", env.Err())
+	}
+}
+
+func TestMakeVec(t *testing.T) {
+	var mu sync.Mutex
+	connections := 0
+	server := newFakeServer(t, false, nil)
+	handler := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		handler.ServeHTTP(w, r)
+	})
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			connections++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	vec, instances, err := client.MakeVec("Fake-v0", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.transport.MaxConnsPerHost != 0 {
+		t.Errorf("Expected the pool of the client to be left untouched")
+	}
+	if vec.Len() != 8 || len(instances.All()) != 8 {
+		t.Fatalf("Expected 8 environments, got %d", vec.Len())
+	}
+	vec.Reset()
+	for i := 0; i < 20; i++ {
+		vec.Step(make([]int, 8))
+	}
+
+	// A failed worker is replaced with a new pooled instance
+	replacement := dqn.NewWatchdogEnv(instances.factory, time.Second)
+	if replacement.Environment == instances.All()[0] || len(instances.All()) != 9 {
+		t.Errorf("Expected a new instance for the replacement, got %d instances", len(instances.All()))
+	}
+	replacement.Reset()
+	if _, _, done := replacement.Step(0); done {
+		t.Errorf("Expected the replacement to step")
+	}
+	for _, env := range instances.All() {
+		if env.Err() != nil {
+			t.Error(env.Err())
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if connections > 8 {
+		t.Errorf("Expected at most 8 pooled connections, got %d", connections)
+	}
+}
@@ -0,0 +1,93 @@
+// pool.go
+package remote
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/iampaapa/dqn"
+)
+
+// SetPoolSize sets the number of connections kept open to the server. The
+// default keeps only two idle connections, so environments stepped
+// concurrently, e.g. by a dqn.VecEnv, would otherwise keep reconnecting.
+// It must be called before the client is first used, since the connection
+// pool cannot be reconfigured while requests are in flight.
+func (c *Client) SetPoolSize(n int) {
+	c.transport.MaxIdleConns = max(c.transport.MaxIdleConns, n)
+	c.transport.MaxIdleConnsPerHost = n
+	c.transport.MaxConnsPerHost = n
+}
+
+// withPoolSize returns a copy of the client with its own pool of n
+// connections, leaving the pool of c untouched.
+func (c *Client) withPoolSize(n int) *Client {
+	clone := *c
+	clone.transport = c.transport.Clone()
+	httpClient := *c.httpClient
+	httpClient.Transport = clone.transport
+	clone.httpClient = &httpClient
+	clone.SetPoolSize(n)
+	return &clone
+}
+
+// Instances tracks the remote instances stepped by a VecEnv made by
+// MakeVec, including those created to replace failed workers.
+type Instances struct {
+	client *Client
+	envID  string
+
+	mu     sync.Mutex
+	envs   []*Env
+	handed int // number of instances handed to the VecEnv
+}
+
+// All returns every instance created so far, to check their errors and
+// close them.
+func (s *Instances) All() []*Env {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Env(nil), s.envs...)
+}
+
+// make creates a new instance and tracks it.
+func (s *Instances) make() (*Env, error) {
+	env, err := s.client.Make(s.envID)
+	if err != nil {
+		return nil, err
+	}
+	s.envs = append(s.envs, env)
+	return env, nil
+}
+
+// factory hands out the instances created by MakeVec and then creates new
+// ones, which the VecEnv or a WatchdogEnv asks for to replace a failed
+// worker. It may be called concurrently.
+func (s *Instances) factory() dqn.Environment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handed < len(s.envs) {
+		s.handed++
+		return s.envs[s.handed-1]
+	}
+	env, err := s.make()
+	if err != nil {
+		panic(fmt.Sprintf("Cannot create a replacement remote environment: %v", err))
+	}
+	s.handed++
+	return env
+}
+
+// MakeVec creates n remote instances of envID and returns them as a
+// dqn.VecEnv, which steps them concurrently over a dedicated pool of n
+// connections so that their requests are in flight together. Workers that
+// fail are replaced with new instances from the same pool.
+func (c *Client) MakeVec(envID string, n int) (*dqn.VecEnv, *Instances, error) {
+	instances := &Instances{client: c.withPoolSize(max(n, c.transport.MaxIdleConnsPerHost)), envID: envID}
+	for i := 0; i < n; i++ {
+		if _, err := instances.make(); err != nil {
+			return nil, instances, err
+		}
+	}
+	return dqn.NewVecEnv(n, instances.factory), instances, nil
+}
@@ -0,0 +1,100 @@
+// retry.go
+package remote
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy configures how requests that failed with a transient error are
+// retried: network errors and 502, 503 and 504 responses. The delay before
+// retry i is InitialBackoff*Multiplier^i, capped at MaxBackoff.
+//
+// A retried step may be applied twice if the server processed the lost
+// request, so retries trade exactness for surviving network blips.
+type RetryPolicy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+}
+
+// DefaultRetryPolicy retries five times with exponential backoff from 100ms to 10s.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     5,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	Multiplier:     2,
+}
+
+// SetRetryPolicy makes the client retry transient failures. By default
+// requests are not retried.
+func (c *Client) SetRetryPolicy(p RetryPolicy) {
+	c.retry = p
+}
+
+// backoff returns the delay before the given retry, counting from zero.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := float64(p.InitialBackoff)
+	for i := 0; i < retry; i++ {
+		delay *= max(p.Multiplier, 1)
+	}
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(delay)
+}
+
+// transient reports whether a request error is worth retrying.
+func transient(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		switch status.code {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// notFound reports whether a request failed because the resource does not
+// exist, e.g. an environment instance lost in a server restart.
+func notFound(err error) bool {
+	var status *statusError
+	return errors.As(err, &status) && status.code == http.StatusNotFound
+}
+
+// do sends a JSON request, retrying transient failures according to the
+// retry policy, and decodes the JSON response into out.
+func (c *Client) do(method, path string, in, out interface{}) error {
+	err := c.doOnce(method, path, in, out)
+	for retry := 0; retry < c.retry.MaxRetries && transient(err); retry++ {
+		time.Sleep(c.retry.backoff(retry))
+		err = c.doOnce(method, path, in, out)
+	}
+	return err
+}
+
+// reconnect replaces a lost environment instance with a new one of the same
+// environment, so that training survives a restart of the server.
+func (e *Env) reconnect() error {
+	var created struct {
+		InstanceID string `json:"instance_id"`
+	}
+	if err := e.client.do(http.MethodPost, "/v1/envs/", map[string]string{"env_id": e.envID}, &created); err != nil {
+		return err
+	}
+	e.instanceID = created.InstanceID
+	e.reconnects++
+	return nil
+}
+
+// Reconnects returns how many times the environment instance was recreated
+// after being lost on the server.
+func (e *Env) Reconnects() int {
+	return e.reconnects
+}
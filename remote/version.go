@@ -0,0 +1,52 @@
+// version.go
+package remote
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ProtocolVersion is the newest protocol version spoken by this client.
+// Version 0 is the plain gym-http-api protocol without negotiation.
+const ProtocolVersion = 1
+
+// Capabilities lists the optional protocol features supported by a server.
+type Capabilities struct {
+	Render     bool `json:"render"`
+	Seed       bool `json:"seed"`
+	Truncation bool `json:"truncation"`
+}
+
+// ServerInfo is the outcome of protocol negotiation.
+type ServerInfo struct {
+	Version      int          `json:"version"`
+	Capabilities Capabilities `json:"capabilities"`
+}
+
+// Negotiate asks the server for its protocol version and capabilities and
+// returns the version both sides speak. Servers without the version endpoint
+// are treated as version 0 with no optional features, so that the client
+// keeps working against older bridges. The result is cached for the client
+// and used by every environment it makes.
+func (c *Client) Negotiate() (ServerInfo, error) {
+	if c.info != nil {
+		return *c.info, nil
+	}
+	var info ServerInfo
+	err := c.do(http.MethodGet, "/v1/version/", nil, &info)
+	var status *statusError
+	switch {
+	case errors.As(err, &status) && status.code == http.StatusNotFound:
+		info = ServerInfo{}
+	case err != nil:
+		return ServerInfo{}, err
+	}
+	if info.Version > ProtocolVersion {
+		info.Version = ProtocolVersion
+	}
+	if info.Version == 0 {
+		info.Capabilities = Capabilities{}
+	}
+	c.info = &info
+	return info, nil
+}
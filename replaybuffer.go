@@ -1,19 +1,66 @@
 // replaybuffer.go
 package dqn
 
-import "math/rand"
+import (
+    "bytes"
+    "encoding/binary"
+    "encoding/gob"
+    "hash/fnv"
+    "math"
+    "math/rand"
+)
 
 // Experience represents a single experience tuple.
 type Experience struct {
     State, NextState []float64
     Action, Reward   int
     Done             bool
+    // NextMask marks the actions legal in NextState; nil means all are legal.
+    NextMask []bool
+    // Truncated marks a done experience whose episode was cut short, e.g.
+    // by a time limit, rather than terminated, so that its target still
+    // bootstraps from NextState.
+    Truncated bool
+}
+
+// terminal reports whether the experience ends in a terminal state, whose
+// value is zero.
+func (e Experience) terminal() bool {
+    return e.Done && !e.Truncated
+}
+
+// Buffer stores the experiences an agent replays. ReplayBuffer implements
+// it; use WithReplayBuffer to train from another buffer.
+type Buffer interface {
+    Add(exp Experience)
+    Sample(batchSize int) []Experience
+    Snapshot() []Experience
+    Len() int
+    Cap() int
 }
 
 // ReplayBuffer stores experiences for training.
 type ReplayBuffer struct {
     buffer []Experience
     size   int
+    // With delta encoding, NextState is not stored in buffer. Instead,
+    // changed holds the indices at which it differs from State and values
+    // the next state values at those indices.
+    changed [][]int32
+    values  [][]float64
+    delta   bool
+    // Sub-sampling of incoming transitions, see SetSubsampling
+    every     int
+    minChange float64
+    offered   int
+    // Novelty priorities, see NewPrioritizedReplayBuffer. The priority of
+    // the n-th experience added is stored at n % size, and visits counts the
+    // experiences in the buffer by state hash.
+    priorities *sumTree
+    visits     map[uint64]int
+    added      int
+    // rng draws samples; nil uses the global source
+    rng *rand.Rand
 }
 
 // NewReplayBuffer initializes a new ReplayBuffer.
@@ -21,19 +68,237 @@ func NewReplayBuffer(size int) *ReplayBuffer {
     return &ReplayBuffer{size: size}
 }
 
+// NewDeltaReplayBuffer initializes a new ReplayBuffer that stores each next
+// state as the values that differ from its state, reconstructed exactly on
+// sampling. This roughly halves state memory when few dimensions change
+// between consecutive states, e.g. slowly changing sensors.
+func NewDeltaReplayBuffer(size int) *ReplayBuffer {
+    return &ReplayBuffer{size: size, delta: true}
+}
+
+// NewPrioritizedReplayBuffer initializes a new ReplayBuffer that samples
+// experiences in proportion to the novelty of their state at insertion time.
+// Novelty is 1/sqrt(n), where n is a hash-based pseudo-count of the
+// experiences in the buffer with the same state, so first visits are sampled
+// most often.
+func NewPrioritizedReplayBuffer(size int) *ReplayBuffer {
+    return &ReplayBuffer{size: size, priorities: newSumTree(size), visits: map[uint64]int{}}
+}
+
+// stateHash hashes the exact values of a state.
+func stateHash(state []float64) uint64 {
+    h := fnv.New64a()
+    var buf [8]byte
+    for _, v := range state {
+        binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+        h.Write(buf[:])
+    }
+    return h.Sum64()
+}
+
+// SetSubsampling makes Add keep only every k-th transition and skip
+// transitions whose state changes by less than minChange in every dimension.
+// Terminal transitions are always kept. Zero values disable either filter.
+func (rb *ReplayBuffer) SetSubsampling(k int, minChange float64) {
+    rb.every = k
+    rb.minChange = minChange
+}
+
+// keep reports whether an offered experience passes the sub-sampling filters.
+func (rb *ReplayBuffer) keep(exp Experience) bool {
+    if exp.Done {
+        return true
+    }
+    rb.offered++
+    if rb.every > 1 && (rb.offered-1)%rb.every != 0 {
+        return false
+    }
+    if rb.minChange > 0 {
+        var change float64
+        for i := range exp.State {
+            change = math.Max(change, math.Abs(exp.NextState[i]-exp.State[i]))
+        }
+        if change < rb.minChange {
+            return false
+        }
+    }
+    return true
+}
+
 // Add adds a new experience to the buffer.
 func (rb *ReplayBuffer) Add(exp Experience) {
+    if rb.keep(exp) {
+        rb.insert(exp)
+    }
+}
+
+// insert stores an experience, evicting the oldest one if the buffer is full.
+func (rb *ReplayBuffer) insert(exp Experience) {
     if len(rb.buffer) >= rb.size {
+        if rb.priorities != nil {
+            rb.forget(rb.buffer[0].State)
+        }
         rb.buffer = rb.buffer[1:]
+        if rb.delta {
+            rb.changed = rb.changed[1:]
+            rb.values = rb.values[1:]
+        }
+    }
+    if rb.priorities != nil {
+        // The new experience takes the slot of the one evicted, if any
+        key := stateHash(exp.State)
+        rb.visits[key]++
+        rb.priorities.set(rb.added%rb.size, 1/math.Sqrt(float64(rb.visits[key])))
+    }
+    rb.added++
+    if rb.delta {
+        var changed []int32
+        var values []float64
+        for i, v := range exp.NextState {
+            if v != exp.State[i] {
+                changed = append(changed, int32(i))
+                values = append(values, v)
+            }
+        }
+        rb.changed = append(rb.changed, changed)
+        rb.values = append(rb.values, values)
+        exp.NextState = nil
     }
     rb.buffer = append(rb.buffer, exp)
 }
 
+// forget removes an evicted state from the visit counts.
+func (rb *ReplayBuffer) forget(state []float64) {
+    key := stateHash(state)
+    if rb.visits[key]--; rb.visits[key] <= 0 {
+        delete(rb.visits, key)
+    }
+}
+
+// get returns the experience at index i, reconstructing its states if needed.
+func (rb *ReplayBuffer) get(i int) Experience {
+    exp := rb.buffer[i]
+    if rb.delta {
+        exp.NextState = append([]float64(nil), exp.State...)
+        for j, idx := range rb.changed[i] {
+            exp.NextState[idx] = rb.values[i][j]
+        }
+    }
+    return exp
+}
+
+// stateBytes returns the memory used by the stored states and next states.
+func (rb *ReplayBuffer) stateBytes() int {
+    n := 0
+    for i, exp := range rb.buffer {
+        n += 8 * (len(exp.State) + len(exp.NextState))
+        if rb.delta {
+            n += 4*len(rb.changed[i]) + 8*len(rb.values[i])
+        }
+    }
+    return n
+}
+
+// AddAll adds experiences to the buffer in order, e.g. those read from historical logs.
+func (rb *ReplayBuffer) AddAll(exps []Experience) {
+    for _, exp := range exps {
+        rb.Add(exp)
+    }
+}
+
+// Len returns the number of experiences in the buffer.
+func (rb *ReplayBuffer) Len() int {
+    return len(rb.buffer)
+}
+
+// Cap returns the capacity of the buffer.
+func (rb *ReplayBuffer) Cap() int {
+    return rb.size
+}
+
+// Snapshot returns a copy of the experiences currently in the buffer, oldest first.
+func (rb *ReplayBuffer) Snapshot() []Experience {
+    snapshot := make([]Experience, len(rb.buffer))
+    for i := range snapshot {
+        snapshot[i] = rb.get(i)
+    }
+    return snapshot
+}
+
+// replayState is the serialized form of the contents of a ReplayBuffer.
+type replayState struct {
+    Experiences    []Experience
+    Offered, Added int
+    // Priorities holds the priority of every experience, oldest first
+    Priorities []float64
+}
+
+// MarshalBinary encodes the experiences in the buffer and its sampling
+// state, so that checkpoints restore the buffer exactly.
+func (rb *ReplayBuffer) MarshalBinary() ([]byte, error) {
+    state := replayState{Experiences: rb.Snapshot(), Offered: rb.offered, Added: rb.added}
+    if rb.priorities != nil {
+        oldest := rb.added - len(rb.buffer)
+        state.Priorities = make([]float64, len(rb.buffer))
+        for i := range state.Priorities {
+            state.Priorities[i] = rb.priorities.get((oldest + i) % rb.size)
+        }
+    }
+    var buf bytes.Buffer
+    err := gob.NewEncoder(&buf).Encode(state)
+    return buf.Bytes(), err
+}
+
+// UnmarshalBinary replaces the contents of the buffer with those encoded by
+// MarshalBinary. The buffer keeps its own capacity and settings.
+func (rb *ReplayBuffer) UnmarshalBinary(data []byte) error {
+    var state replayState
+    if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+        return err
+    }
+    rb.buffer, rb.changed, rb.values = nil, nil, nil
+    rb.added = 0
+    if rb.priorities != nil {
+        rb.priorities = newSumTree(rb.size)
+        rb.visits = map[uint64]int{}
+    }
+    for _, exp := range state.Experiences {
+        rb.insert(exp)
+    }
+    rb.offered = state.Offered
+    if rb.priorities == nil {
+        rb.added = state.Added
+    } else if len(state.Priorities) == len(rb.buffer) {
+        // Move the priorities to the slots the experiences had when saved
+        rb.priorities = newSumTree(rb.size)
+        rb.added = state.Added
+        oldest := rb.added - len(rb.buffer)
+        for i, priority := range state.Priorities {
+            rb.priorities.set((oldest+i)%rb.size, priority)
+        }
+    }
+    return nil
+}
+
 // Sample returns a batch of experiences.
 func (rb *ReplayBuffer) Sample(batchSize int) []Experience {
     sample := make([]Experience, batchSize)
     for i := range sample {
-        sample[i] = rb.buffer[rand.Intn(len(rb.buffer))]
+        sample[i] = rb.get(rb.index())
     }
     return sample
 }
+
+// index draws a buffer index, uniformly or in proportion to the priorities.
+func (rb *ReplayBuffer) index() int {
+    intn, float := rand.Intn, rand.Float64
+    if rb.rng != nil {
+        intn, float = rb.rng.Intn, rb.rng.Float64
+    }
+    if rb.priorities == nil {
+        return intn(len(rb.buffer))
+    }
+    slot := rb.priorities.find(float() * rb.priorities.total())
+    oldest := (rb.added - len(rb.buffer)) % rb.size
+    return (slot - oldest + rb.size) % rb.size
+}
@@ -0,0 +1,51 @@
+// returns.go
+package dqn
+
+// DiscountedReturns returns the discounted return of every step of an
+// episode: G[t] = rewards[t] + gamma*G[t+1].
+func DiscountedReturns(rewards []float64, gamma float64) []float64 {
+	returns := make([]float64, len(rewards))
+	var g float64
+	for t := len(rewards) - 1; t >= 0; t-- {
+		g = rewards[t] + gamma*g
+		returns[t] = g
+	}
+	return returns
+}
+
+// TrajectoryReturns returns the discounted return of every experience of
+// recorded trajectories, with episodes separated by terminal experiences.
+// Returns of a trailing unfinished episode are truncated at its last step.
+func TrajectoryReturns(experiences []Experience, gamma float64) []float64 {
+	returns := make([]float64, len(experiences))
+	var g float64
+	for t := len(experiences) - 1; t >= 0; t-- {
+		if experiences[t].Done {
+			g = 0
+		}
+		g = float64(experiences[t].Reward) + gamma*g
+		returns[t] = g
+	}
+	return returns
+}
+
+// Advantages returns generalized advantage estimates for an episode, given
+// the rewards, the baseline values V(s[t]) of every step and the value of
+// the state after the last step, zero if the episode terminated. lambda
+// trades bias for variance: 0 gives one-step TD errors and 1 the discounted
+// return minus the baseline.
+func Advantages(rewards, values []float64, lastValue, gamma, lambda float64) []float64 {
+	if len(values) != len(rewards) {
+		panic("Rewards and values must have the same length")
+	}
+	advantages := make([]float64, len(rewards))
+	var a float64
+	next := lastValue
+	for t := len(rewards) - 1; t >= 0; t-- {
+		delta := rewards[t] + gamma*next - values[t]
+		a = delta + gamma*lambda*a
+		advantages[t] = a
+		next = values[t]
+	}
+	return advantages
+}
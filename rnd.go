@@ -0,0 +1,129 @@
+// rnd.go
+package dqn
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+	"math/rand"
+)
+
+// RND is a Random Network Distillation exploration bonus. A predictor network
+// is trained to match the output of a fixed random target network, and its
+// prediction error, high for rarely visited states, is used as an intrinsic
+// reward.
+type RND struct {
+	target       *QNetwork
+	predictor    *QNetwork
+	learningRate float64
+	// scale multiplies the normalized error and is multiplied by decay after
+	// every bonus, fading exploration out over training.
+	scale float64
+	decay float64
+	// seed is the seed the networks were initialized from, zero if random
+	seed                              int64
+	stateSize, hiddenSize, outputSize int
+	// Running statistics of the prediction error used for normalization
+	count    int
+	mean, m2 float64
+}
+
+// NewRND initializes a new RND module for states of the given size. The bonus
+// starts at scale times the normalized prediction error and is multiplied by
+// decay after every call to Bonus.
+func NewRND(stateSize, hiddenSize, outputSize int, learningRate, scale, decay float64) *RND {
+	r := &RND{
+		learningRate: learningRate,
+		scale:        scale,
+		decay:        decay,
+		stateSize:    stateSize,
+		hiddenSize:   hiddenSize,
+		outputSize:   outputSize,
+	}
+	r.Seed(0)
+	return r
+}
+
+// Seed reinitializes the target and predictor networks from seed, or from a
+// random seed if seed is zero. A Trainer seeds an RND that has not been
+// seeded explicitly from its Config.Seed.
+func (r *RND) Seed(seed int64) {
+	initRand := rand.New(newPCGSource(seed)).Float64
+	r.target = newQNetwork(r.stateSize, r.hiddenSize, r.outputSize, ReLU, "xavier", initRand)
+	r.predictor = newQNetwork(r.stateSize, r.hiddenSize, r.outputSize, ReLU, "xavier", initRand)
+	r.seed = seed
+}
+
+// Error returns the predictor's mean squared error on state without training it.
+func (r *RND) Error(state []float64) float64 {
+	return r.predictor.Loss(r.predictor.Predict(state), r.target.Predict(state))
+}
+
+// Bonus returns the exploration bonus for visiting state and trains the
+// predictor on it. The error is divided by its running standard deviation so
+// the bonus stays on a stable scale as the predictor improves.
+func (r *RND) Bonus(state []float64) float64 {
+	prediction := r.predictor.Predict(state)
+	target := r.target.Predict(state)
+	err := r.predictor.Loss(prediction, target)
+	r.predictor.Backward(state, prediction, target, r.learningRate)
+
+	// Welford's online variance
+	r.count++
+	delta := err - r.mean
+	r.mean += delta / float64(r.count)
+	r.m2 += delta * (err - r.mean)
+
+	normalized := err
+	if r.count > 1 {
+		if std := math.Sqrt(r.m2 / float64(r.count-1)); std > 0 {
+			normalized = err / std
+		}
+	}
+	bonus := r.scale * normalized
+	r.scale *= r.decay
+	return bonus
+}
+
+// rndState is the serialized form of an RND module.
+type rndState struct {
+	Target, Predictor networkState
+	Scale             float64
+	Count             int
+	Mean, M2          float64
+}
+
+// MarshalBinary encodes the networks and the bonus statistics, so that
+// checkpoints resume the same exploration bonus.
+func (r *RND) MarshalBinary() ([]byte, error) {
+	state := rndState{Scale: r.scale, Count: r.count, Mean: r.mean, M2: r.m2}
+	var err error
+	if state.Target, err = r.target.state(); err != nil {
+		return nil, err
+	}
+	if state.Predictor, err = r.predictor.state(); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = gob.NewEncoder(&buf).Encode(state)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary restores the state encoded by MarshalBinary.
+func (r *RND) UnmarshalBinary(data []byte) error {
+	var state rndState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+		return err
+	}
+	target, err := networkFromState(state.Target)
+	if err != nil {
+		return err
+	}
+	predictor, err := networkFromState(state.Predictor)
+	if err != nil {
+		return err
+	}
+	r.target, r.predictor = target, predictor
+	r.scale, r.count, r.mean, r.m2 = state.Scale, state.Count, state.Mean, state.M2
+	return nil
+}
@@ -0,0 +1,59 @@
+// rng.go
+package dqn
+
+import (
+	"math/rand"
+	randv2 "math/rand/v2"
+)
+
+// pcgSource is a math/rand Source backed by a PCG generator, whose state
+// can be saved in checkpoints.
+type pcgSource struct {
+	pcg  *randv2.PCG
+	seed int64
+}
+
+// newPCGSource returns a pcgSource seeded with seed, or with a random seed if seed is zero.
+func newPCGSource(seed int64) *pcgSource {
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+	return &pcgSource{pcg: randv2.NewPCG(uint64(seed), 0), seed: seed}
+}
+
+func (s *pcgSource) Int63() int64    { return int64(s.pcg.Uint64() >> 1) }
+func (s *pcgSource) Uint64() uint64  { return s.pcg.Uint64() }
+func (s *pcgSource) Seed(seed int64) { s.pcg.Seed(uint64(seed), 0); s.seed = seed }
+
+// Random streams derived from Config.Seed
+const (
+	initStream = iota + 1
+	replayStream
+	envStream
+	evalStream
+	paramStream
+	dropoutStream
+	curiosityStream
+	bootstrapStream
+	tabularStream
+)
+
+// subsystemSeed returns explicit if set, and otherwise a seed for stream
+// derived from Seed with SplitMix64, or zero if Seed is zero.
+func (c Config) subsystemSeed(explicit int64, stream uint64) int64 {
+	if explicit != 0 || c.Seed == 0 {
+		return explicit
+	}
+	z := uint64(c.Seed) + stream*0x9e3779b97f4a7c15
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return int64(z ^ z>>31)
+}
+
+// BootstrapSeed returns a seed for CompareSeeded derived from Seed, or zero
+// if Seed is zero.
+func (c Config) BootstrapSeed() int64 { return c.subsystemSeed(0, bootstrapStream) }
+
+// TabularSeed returns a seed for a tabular baseline agent derived from Seed,
+// or zero if Seed is zero.
+func (c Config) TabularSeed() int64 { return c.subsystemSeed(0, tabularStream) }
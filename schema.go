@@ -0,0 +1,152 @@
+// schema.go
+package dqn
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ExperienceSchemaVersion is the version of the experience schema written
+// by this release. Readers accept every version up to it:
+//
+//	1: state, action, reward, next state and done
+//	2: adds the next-state action mask; absent means every action is legal
+//	3: adds the truncation flag; absent means a done episode terminated
+//
+// A new field bumps the version and adds an upgrade from the previous
+// version that fills it in for older records.
+const ExperienceSchemaVersion = 3
+
+// experienceUpgrades[v] upgrades a record from version v to v+1.
+var experienceUpgrades = map[int]func(*experienceRecord){
+	1: func(*experienceRecord) {}, // a nil NextMask already marks every action as legal
+	2: func(*experienceRecord) {}, // Truncated is already false
+}
+
+// experienceRecord is the stored form of an Experience, shared by JSON Lines
+// datasets and binary experience files. Rewards are stored as floats.
+type experienceRecord struct {
+	Version   int       `json:"version,omitempty"`
+	State     []float64 `json:"state"`
+	Action    int       `json:"action"`
+	Reward    float64   `json:"reward"`
+	NextState []float64 `json:"next_state"`
+	Done      bool      `json:"done"`
+	NextMask  []bool    `json:"next_mask,omitempty"`
+	Truncated bool      `json:"truncated,omitempty"`
+}
+
+// newExperienceRecord returns the stored form of exp at the current version.
+func newExperienceRecord(exp Experience) experienceRecord {
+	return experienceRecord{
+		Version:   ExperienceSchemaVersion,
+		State:     exp.State,
+		Action:    exp.Action,
+		Reward:    float64(exp.Reward),
+		NextState: exp.NextState,
+		Done:      exp.Done,
+		NextMask:  exp.NextMask,
+		Truncated: exp.Truncated,
+	}
+}
+
+// checkSchemaVersion returns an error if records of the given version cannot be read.
+func checkSchemaVersion(version int) error {
+	if version < 1 {
+		return fmt.Errorf("invalid experience schema version %d", version)
+	}
+	if version > ExperienceSchemaVersion {
+		return fmt.Errorf("experience schema version %d is newer than the supported version %d", version, ExperienceSchemaVersion)
+	}
+	return nil
+}
+
+// upgrade brings a record written with the given version to the current version.
+func (r *experienceRecord) upgrade(version int) error {
+	if err := checkSchemaVersion(version); err != nil {
+		return err
+	}
+	for v := version; v < ExperienceSchemaVersion; v++ {
+		experienceUpgrades[v](r)
+	}
+	r.Version = ExperienceSchemaVersion
+	return nil
+}
+
+// experience converts the record to an Experience, multiplying the reward
+// by rewardScale and rounding it.
+func (r experienceRecord) experience(rewardScale float64) Experience {
+	return Experience{
+		State:     r.State,
+		NextState: r.NextState,
+		Action:    r.Action,
+		Reward:    int(math.Round(r.Reward * rewardScale)),
+		Done:      r.Done,
+		NextMask:  r.NextMask,
+		Truncated: r.Truncated,
+	}
+}
+
+// experienceHeader starts a binary experience file.
+type experienceHeader struct {
+	Schema  string
+	Version int
+	Count   int
+}
+
+// experienceSchema identifies binary experience files.
+const experienceSchema = "dqn.experience"
+
+// maxPreallocatedExperiences bounds the memory ReadExperiences reserves
+// before the records are actually read.
+const maxPreallocatedExperiences = 1 << 16
+
+// WriteExperiences writes experiences in a compact binary format, tagged
+// with the schema version, e.g. to keep the contents of a replay buffer on
+// disk with DQN.ReplaySnapshot.
+func WriteExperiences(w io.Writer, experiences []Experience) error {
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(experienceHeader{Schema: experienceSchema, Version: ExperienceSchemaVersion, Count: len(experiences)}); err != nil {
+		return err
+	}
+	for _, exp := range experiences {
+		if err := enc.Encode(newExperienceRecord(exp)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadExperiences reads experiences written by WriteExperiences with the
+// current or an older schema version, upgrading older records.
+func ReadExperiences(r io.Reader) ([]Experience, error) {
+	dec := gob.NewDecoder(r)
+	var header experienceHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, err
+	}
+	if header.Schema != experienceSchema {
+		return nil, fmt.Errorf("dqn: not an experience file")
+	}
+	if err := checkSchemaVersion(header.Version); err != nil {
+		return nil, fmt.Errorf("dqn: %w", err)
+	}
+	if header.Count < 0 {
+		return nil, fmt.Errorf("dqn: invalid experience count %d", header.Count)
+	}
+	// The count comes from the file, so it only bounds the preallocation
+	experiences := make([]Experience, 0, min(header.Count, maxPreallocatedExperiences))
+	for i := 0; i < header.Count; i++ {
+		var rec experienceRecord
+		if err := dec.Decode(&rec); err != nil {
+			return experiences, fmt.Errorf("dqn: experience %d: %w", i, err)
+		}
+		if err := rec.upgrade(header.Version); err != nil {
+			return nil, fmt.Errorf("dqn: %w", err)
+		}
+		experiences = append(experiences, rec.experience(1))
+	}
+	return experiences, nil
+}
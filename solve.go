@@ -0,0 +1,88 @@
+// solve.go
+package dqn
+
+import (
+	"context"
+	"fmt"
+)
+
+// Report summarizes a Solve run.
+type Report struct {
+	Training   []EpisodeStats
+	Evaluation ReturnReport
+	// Baselines holds the returns of every registered baseline, evaluated
+	// on the same seeds as the returned policy.
+	Baselines map[string]ReturnReport
+	// Profile is the timing breakdown of training, if profiling is enabled
+	Profile ProfileReport
+}
+
+// Solve builds an agent with defaults picked for env, trains it, evaluates the
+// returned policy against the registered baselines and optionally saves it to
+// the checkpoint path. Options override the defaults.
+func Solve(env Environment, opts ...Option) (*Policy, Report, error) {
+	return SolveContext(context.Background(), env, opts...)
+}
+
+// SolveContext is like Solve but stops training when ctx is cancelled.
+func SolveContext(ctx context.Context, env Environment, opts ...Option) (*Policy, Report, error) {
+	cfg := DefaultConfig(env.StateSize(), env.NumActions())
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.Pipeline != nil {
+		cfg.StateSize = cfg.Pipeline.OutputSize(cfg.StateSize)
+	}
+	if cfg.StateSize <= 0 || cfg.NumActions <= 0 {
+		return nil, Report{}, fmt.Errorf("dqn: environment has no states or actions")
+	}
+
+	agent := NewDQNFromConfig(cfg)
+	trainer := NewTrainer(agent, env, opts...)
+	// rawEnv yields raw observations, with the configured parameters
+	rawEnv := trainer.rawEnv()
+
+	var report Report
+	var err error
+	report.Training, err = trainer.Run(ctx)
+	report.Profile = agent.Profile()
+	if err != nil {
+		return nil, report, err
+	}
+	if err := envErr(rawEnv); err != nil {
+		return nil, report, err
+	}
+
+	// The returned policy is the best one seen if early stopping evaluated any
+	policy, _ := trainer.Best()
+	if policy == nil {
+		policy = trainer.policy()
+	}
+
+	if cfg.EvalEpisodes > 0 {
+		seed := trainer.cfg.subsystemSeed(0, evalStream)
+		trainer.setEvaluating(true)
+		report.Evaluation = NewReturnReport(EvaluatePolicySeeded(policy, rawEnv, cfg.EvalEpisodes, seed), 0.1, 10)
+		if report.Baselines, err = EvaluateBaselines(rawEnv, cfg.EvalEpisodes, seed); err != nil {
+			return nil, report, err
+		}
+		if err := envErr(rawEnv); err != nil {
+			return nil, report, err
+		}
+	}
+
+	return policy, report, nil
+}
+
+// envErr returns the error reported by environments that keep one, such as remote environments.
+func envErr(env any) error {
+	if e, ok := env.(interface{ Err() error }); ok {
+		return e.Err()
+	}
+	return nil
+}
+
+// savePolicy saves a policy to a file, atomically
+func savePolicy(policy *Policy, path string) error {
+	return writeFileAtomic(path, policy.Save)
+}
@@ -0,0 +1,55 @@
+// sumtree.go
+package dqn
+
+// sumTree stores non-negative values in its leaves and the sum of every
+// subtree in its inner nodes, so that a value can be updated, and an index
+// drawn in proportion to the values, in O(log n).
+type sumTree struct {
+	leaves int       // number of leaves, a power of two
+	nodes  []float64 // nodes[1] is the root and the children of i are 2i and 2i+1
+}
+
+// newSumTree returns a sum tree with room for n values, all zero.
+func newSumTree(n int) *sumTree {
+	leaves := 1
+	for leaves < n {
+		leaves *= 2
+	}
+	return &sumTree{leaves: leaves, nodes: make([]float64, 2*leaves)}
+}
+
+// set sets the value at index i.
+func (t *sumTree) set(i int, value float64) {
+	i += t.leaves
+	t.nodes[i] = value
+	for i /= 2; i > 0; i /= 2 {
+		t.nodes[i] = t.nodes[2*i] + t.nodes[2*i+1]
+	}
+}
+
+// get returns the value at index i.
+func (t *sumTree) get(i int) float64 {
+	return t.nodes[i+t.leaves]
+}
+
+// total returns the sum of all values.
+func (t *sumTree) total() float64 {
+	return t.nodes[1]
+}
+
+// find returns the index at which the running sum of the values first
+// exceeds r, for r in [0, total).
+func (t *sumTree) find(r float64) int {
+	i := 1
+	for i < t.leaves {
+		left := t.nodes[2*i]
+		// Rounding can leave r at the total; never descend into an empty subtree
+		if r < left || t.nodes[2*i+1] <= 0 {
+			i = 2 * i
+		} else {
+			r -= left
+			i = 2*i + 1
+		}
+	}
+	return i - t.leaves
+}
@@ -0,0 +1,81 @@
+// sweep.go
+package dqn
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+// CheckpointEvaluation is the evaluation of the policy stored in one checkpoint.
+type CheckpointEvaluation struct {
+	Path     string
+	Episodes int // training episodes completed at the checkpoint
+	Steps    int // environment steps taken by the agent at the checkpoint
+	Report   ReturnReport
+}
+
+// EvaluateCheckpoints loads every checkpoint in a run directory, oldest
+// first, and evaluates its greedy policy, with the pipeline and action limits
+// of the run, on env for the given number of episodes, so that the best
+// checkpoint can be picked after training. Every checkpoint is evaluated with
+// EvaluateSeeded on the same seed, so that all of them face the same
+// episodes. The registered baselines are evaluated on those episodes too, as
+// a reference.
+func EvaluateCheckpoints(dir string, env Environment, episodes int, seed int64) ([]CheckpointEvaluation, map[string]ReturnReport, error) {
+	paths, err := checkpointPaths(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("dqn: no checkpoint found in %s", dir)
+	}
+	baselines, err := EvaluateBaselines(env, episodes, seed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	evaluations := make([]CheckpointEvaluation, 0, len(paths))
+	for _, path := range paths {
+		state, err := readCheckpoint(path)
+		if err != nil {
+			return evaluations, baselines, fmt.Errorf("dqn: %s: %w", path, err)
+		}
+		network, err := LoadQNetwork(bytes.NewReader(state.Network))
+		if err != nil {
+			return evaluations, baselines, fmt.Errorf("dqn: %s: %w", path, err)
+		}
+		policy := (&Policy{qNetwork: network, pipeline: state.Pipeline}).WithActionLimits(state.ActionLimits...)
+		returns := EvaluatePolicySeeded(policy, env, episodes, seed)
+		evaluations = append(evaluations, CheckpointEvaluation{
+			Path:     path,
+			Episodes: len(state.History),
+			Steps:    state.Steps,
+			Report:   NewReturnReport(returns, 0.1, 10),
+		})
+	}
+	return evaluations, baselines, nil
+}
+
+// BestCheckpoint returns the evaluation with the highest mean return.
+func BestCheckpoint(evaluations []CheckpointEvaluation) CheckpointEvaluation {
+	var best CheckpointEvaluation
+	for i, e := range evaluations {
+		if i == 0 || e.Report.Mean > best.Report.Mean {
+			best = e
+		}
+	}
+	return best
+}
+
+// readCheckpoint decodes the trainer checkpoint at path, returning
+// ErrChecksum if the file is corrupted.
+func readCheckpoint(path string) (trainerState, error) {
+	var state trainerState
+	r, err := readChecked(path)
+	if err != nil {
+		return state, err
+	}
+	err = gob.NewDecoder(r).Decode(&state)
+	return state, err
+}
@@ -0,0 +1,151 @@
+// agent.go
+package tabular
+
+import (
+	"math/rand"
+	"slices"
+
+	"github.com/iampaapa/dqn"
+)
+
+// Method selects the update rule used by an Agent.
+type Method int
+
+const (
+	// QLearning bootstraps from the greedy next action.
+	QLearning Method = iota
+	// SARSA bootstraps from the next action actually taken.
+	SARSA
+	// ExpectedSARSA bootstraps from the expected value under the epsilon-greedy policy.
+	ExpectedSARSA
+)
+
+// Agent implements tabular temporal-difference control over a discretized state space.
+type Agent struct {
+	method      Method
+	discretizer Discretizer
+	qTable      map[int][]float64
+	alpha       float64
+	gamma       float64
+	epsilon     float64
+	numActions  int
+	nextAction  int
+	nextState   []float64
+	hasNext     bool
+	rng         *rand.Rand
+}
+
+// NewAgent initializes a new tabular Agent.
+func NewAgent(method Method, discretizer Discretizer, numActions int, alpha, gamma, epsilon float64) *Agent {
+	return &Agent{
+		method:      method,
+		discretizer: discretizer,
+		qTable:      make(map[int][]float64),
+		alpha:       alpha,
+		gamma:       gamma,
+		epsilon:     epsilon,
+		numActions:  numActions,
+		rng:         rand.New(rand.NewSource(rand.Int63())),
+	}
+}
+
+// Seed seeds the exploration random source. dqn.Config.TabularSeed derives a
+// seed from a DQN agent's seed for comparable baselines.
+func (a *Agent) Seed(seed int64) {
+	a.rng = rand.New(rand.NewSource(seed))
+}
+
+// NewQLearning initializes a new Q-learning Agent.
+func NewQLearning(discretizer Discretizer, numActions int, alpha, gamma, epsilon float64) *Agent {
+	return NewAgent(QLearning, discretizer, numActions, alpha, gamma, epsilon)
+}
+
+// NewSARSA initializes a new SARSA Agent.
+func NewSARSA(discretizer Discretizer, numActions int, alpha, gamma, epsilon float64) *Agent {
+	return NewAgent(SARSA, discretizer, numActions, alpha, gamma, epsilon)
+}
+
+// NewExpectedSARSA initializes a new Expected SARSA Agent.
+func NewExpectedSARSA(discretizer Discretizer, numActions int, alpha, gamma, epsilon float64) *Agent {
+	return NewAgent(ExpectedSARSA, discretizer, numActions, alpha, gamma, epsilon)
+}
+
+// row returns the Q-values for a table entry, allocating it if needed.
+func (a *Agent) row(index int) []float64 {
+	if _, ok := a.qTable[index]; !ok {
+		a.qTable[index] = make([]float64, a.numActions)
+	}
+	return a.qTable[index]
+}
+
+// QValues returns the Q-values of a state, summed over its active table entries.
+func (a *Agent) QValues(state []float64) []float64 {
+	qValues := make([]float64, a.numActions)
+	for _, index := range a.discretizer.Discretize(state) {
+		for i, q := range a.row(index) {
+			qValues[i] += q
+		}
+	}
+	return qValues
+}
+
+// GetAction selects an action using epsilon-greedy strategy. For SARSA, if
+// state is the next state of the previous Update, the action chosen there is
+// returned so that it is the one the update bootstrapped from.
+func (a *Agent) GetAction(state []float64) int {
+	if a.hasNext {
+		a.hasNext = false
+		if slices.Equal(state, a.nextState) {
+			return a.nextAction
+		}
+	}
+	return a.epsilonGreedy(state)
+}
+
+// epsilonGreedy selects an action using epsilon-greedy strategy.
+func (a *Agent) epsilonGreedy(state []float64) int {
+	if a.rng.Float64() < a.epsilon {
+		return a.rng.Intn(a.numActions)
+	}
+	return dqn.Argmax(a.QValues(state))
+}
+
+// Update applies one temporal-difference update for the given transition.
+func (a *Agent) Update(state []float64, action int, reward float64, nextState []float64, done bool) {
+	target := reward
+	if !done {
+		target += a.gamma * a.bootstrap(nextState)
+	}
+
+	active := a.discretizer.Discretize(state)
+	tdError := target - a.QValues(state)[action]
+	step := a.alpha / float64(len(active))
+	for _, index := range active {
+		a.row(index)[action] += step * tdError
+	}
+}
+
+// bootstrap returns the value of nextState according to the agent's method.
+func (a *Agent) bootstrap(nextState []float64) float64 {
+	qValues := a.QValues(nextState)
+	switch a.method {
+	case SARSA:
+		a.nextAction = a.epsilonGreedy(nextState)
+		a.nextState = append(a.nextState[:0], nextState...)
+		a.hasNext = true
+		return qValues[a.nextAction]
+	case ExpectedSARSA:
+		greedy := dqn.Argmax(qValues)
+		var expected float64
+		for i, q := range qValues {
+			p := a.epsilon / float64(a.numActions)
+			if i == greedy {
+				p += 1 - a.epsilon
+			}
+			expected += p * q
+		}
+		return expected
+	default:
+		return dqn.Max(qValues)
+	}
+}
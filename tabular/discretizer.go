@@ -0,0 +1,80 @@
+// discretizer.go
+package tabular
+
+import "math"
+
+// Discretizer maps a continuous state to the indices of its active table entries.
+type Discretizer interface {
+	Discretize(state []float64) []int
+}
+
+// UniformBinning splits every state dimension into equally sized bins and
+// maps a state to a single table entry.
+type UniformBinning struct {
+	low, high []float64
+	bins      int
+}
+
+// NewUniformBinning initializes a new UniformBinning over the box [low, high].
+func NewUniformBinning(low, high []float64, bins int) *UniformBinning {
+	if len(low) != len(high) {
+		panic("Low and high bounds must have the same length")
+	}
+	return &UniformBinning{low: low, high: high, bins: bins}
+}
+
+// Discretize returns the index of the bin containing state.
+func (u *UniformBinning) Discretize(state []float64) []int {
+	return []int{binIndex(state, u.low, u.high, u.bins, 0)}
+}
+
+// TileCoding covers the state space with several offset grids (tilings) and
+// maps a state to one active tile per tiling.
+type TileCoding struct {
+	low, high []float64
+	tiles     int
+	tilings   int
+}
+
+// NewTileCoding initializes a new TileCoding over the box [low, high] with
+// the given number of tiles per dimension and number of tilings.
+func NewTileCoding(low, high []float64, tiles, tilings int) *TileCoding {
+	if len(low) != len(high) {
+		panic("Low and high bounds must have the same length")
+	}
+	return &TileCoding{low: low, high: high, tiles: tiles, tilings: tilings}
+}
+
+// Discretize returns the index of the active tile in every tiling.
+func (t *TileCoding) Discretize(state []float64) []int {
+	// Each tiling has one extra tile per dimension to absorb the offset
+	tilesPerTiling := int(math.Pow(float64(t.tiles+1), float64(len(state))))
+	active := make([]int, t.tilings)
+	for i := range active {
+		offset := float64(i) / float64(t.tilings)
+		active[i] = i*tilesPerTiling + binIndex(state, t.low, t.high, t.tiles, offset)
+	}
+	return active
+}
+
+// binIndex computes the flat index of the grid cell containing state, with
+// the grid shifted by offset (as a fraction of a cell).
+func binIndex(state, low, high []float64, bins int, offset float64) int {
+	if len(state) != len(low) {
+		panic("State size does not match discretizer bounds")
+	}
+
+	stride := bins
+	if offset > 0 {
+		stride++
+	}
+
+	index := 0
+	for i, val := range state {
+		width := (high[i] - low[i]) / float64(bins)
+		b := int(math.Floor((val-low[i])/width + offset))
+		b = max(0, min(b, stride-1))
+		index = index*stride + b
+	}
+	return index
+}
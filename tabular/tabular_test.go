@@ -0,0 +1,92 @@
+// tabular_test.go
+package tabular
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUniformBinning(t *testing.T) {
+	d := NewUniformBinning([]float64{0, 0}, []float64{1, 1}, 10)
+	if idx := d.Discretize([]float64{0.05, 0.95})[0]; idx != 9 {
+		t.Errorf("Expected bin 9, got %d", idx)
+	}
+	if idx := d.Discretize([]float64{2, -1})[0]; idx != 90 {
+		t.Errorf("Expected clipped bin 90, got %d", idx)
+	}
+}
+
+func TestTileCoding(t *testing.T) {
+	d := NewTileCoding([]float64{0}, []float64{1}, 4, 3)
+	active := d.Discretize([]float64{0.5})
+	if len(active) != 3 {
+		t.Errorf("Expected 3 active tiles, got %d", len(active))
+	}
+}
+
+func TestAgents(t *testing.T) {
+	d := NewUniformBinning([]float64{0}, []float64{1}, 2)
+	for _, agent := range []*Agent{
+		NewQLearning(d, 2, 0.5, 0.9, 0.1),
+		NewSARSA(d, 2, 0.5, 0.9, 0.1),
+		NewExpectedSARSA(d, 2, 0.5, 0.9, 0.1),
+	} {
+		agent.Update([]float64{0.2}, 1, 1, []float64{0.8}, true)
+		if q := agent.QValues([]float64{0.2})[1]; q != 0.5 {
+			t.Errorf("Expected Q-value 0.5, got %f", q)
+		}
+		agent.GetAction([]float64{0.8})
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	d := NewUniformBinning([]float64{0}, []float64{1}, 2)
+	for _, tc := range []struct {
+		agent *Agent
+		want  float64
+	}{
+		// 0.5 * (1 + 0.9 * 3)
+		{NewQLearning(d, 2, 0.5, 0.9, 0.2), 1.85},
+		// 0.5 * (1 + 0.9 * (0.1 * 1 + 0.9 * 3))
+		{NewExpectedSARSA(d, 2, 0.5, 0.9, 0.2), 1.76},
+	} {
+		tc.agent.qTable[1] = []float64{1, 3}
+		tc.agent.Update([]float64{0.2}, 1, 1, []float64{0.8}, false)
+		if q := tc.agent.QValues([]float64{0.2})[1]; math.Abs(q-tc.want) > 1e-9 {
+			t.Errorf("Expected Q-value %f, got %f", tc.want, q)
+		}
+	}
+
+	// SARSA bootstraps from the action it then takes
+	sarsa := NewSARSA(d, 2, 0.5, 0.9, 1)
+	sarsa.qTable[1] = []float64{1, 3}
+	sarsa.Update([]float64{0.2}, 1, 1, []float64{0.8}, false)
+	next := sarsa.GetAction([]float64{0.8})
+	if want, q := 0.5*(1+0.9*sarsa.qTable[1][next]), sarsa.QValues([]float64{0.2})[1]; math.Abs(q-want) > 1e-9 {
+		t.Errorf("Expected Q-value %f for next action %d, got %f", want, next, q)
+	}
+}
+
+func TestSARSAGetAction(t *testing.T) {
+	d := NewUniformBinning([]float64{0}, []float64{1}, 2)
+	sarsa := NewSARSA(d, 2, 0.5, 0.9, 0)
+	sarsa.qTable[0] = []float64{0, 5}
+	sarsa.qTable[1] = []float64{3, 1}
+	sarsa.Update([]float64{0.2}, 1, 1, []float64{0.8}, false)
+	// The action cached for the next state is not used for another state
+	if action := sarsa.GetAction([]float64{0.2}); action != 1 {
+		t.Errorf("Expected the greedy action 1, got %d", action)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	d := NewUniformBinning([]float64{0}, []float64{1}, 2)
+	a, b := NewQLearning(d, 4, 0.5, 0.9, 1), NewQLearning(d, 4, 0.5, 0.9, 1)
+	a.Seed(3)
+	b.Seed(3)
+	for i := 0; i < 10; i++ {
+		if x, y := a.GetAction([]float64{0.2}), b.GetAction([]float64{0.2}); x != y {
+			t.Fatalf("Expected identical actions for the same seed, got %d and %d", x, y)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// traces.go
+package dqn
+
+// traceGradients computes the Watkins's Q(λ) update for a transition. The
+// eligibility trace accumulates the gradient of Q(state, action), decayed by
+// gamma*lambda per step, and the update moves the network along the trace by
+// the TD error. Traces are cut when the action is not greedy, since later
+// returns no longer follow the greedy policy, and cleared at the end of an
+// episode.
+func (d *DQN) traceGradients(state, nextState []float64, action, reward int, done bool, nextMask []bool) (StepReport, *gradients) {
+	start := d.profiler.start()
+	targetValue := d.tdTarget(nextState, reward, done, nextMask)
+	d.profiler.stop(phaseTarget, start)
+
+	start = d.profiler.start()
+	mask := d.qNetwork.dropoutMask(d.dropoutRand.Float64)
+	currentQValues := d.qNetwork.forward(state, mask)
+	d.profiler.stop(phaseForward, start)
+	target := make([]float64, len(currentQValues))
+	copy(target, currentQValues)
+	target[action] = targetValue
+
+	// A target one below the prediction makes the loss gradient equal to the
+	// gradient of Q(state, action)
+	start = d.profiler.start()
+	unit := make([]float64, len(currentQValues))
+	copy(unit, currentQValues)
+	unit[action]--
+	grad := d.qNetwork.gradients(state, currentQValues, unit, mask)
+
+	if d.traces == nil || action != Argmax(currentQValues) {
+		d.traces = grad
+	} else {
+		d.traces.scale(d.gamma * d.lambda)
+		d.traces.add(grad)
+	}
+
+	report := StepReport{
+		Loss:       d.qNetwork.Loss(currentQValues, target),
+		TDError:    targetValue - currentQValues[action],
+		Target:     targetValue,
+		Prediction: currentQValues[action],
+	}
+	g := d.traces.clone()
+	g.scale(-report.TDError)
+	report.GradNorm = g.norm()
+	d.profiler.stop(phaseBackward, start)
+
+	if done {
+		d.traces = nil
+	}
+	return report, g
+}
+
+// ResetTraces clears the eligibility traces, e.g. when an episode is
+// truncated without a terminal transition.
+func (d *DQN) ResetTraces() {
+	d.traces = nil
+}
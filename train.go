@@ -2,57 +2,404 @@
 package dqn
 
 import (
+	"math"
 	"math/rand"
+
+	"gonum.org/v1/gonum/mat"
 )
 
 // DQN represents the Deep Q-Learning algorithm.
 type DQN struct {
 	qNetwork      *QNetwork
-	replayBuffer  *ReplayBuffer
+	replayBuffer  Buffer
 	gamma         float64
 	epsilon       float64
 	learningRate  float64
+	targetNetwork *QNetwork
+	targetSync    int
+	updates       int
+	lastLoss      float64
+	rngSource     *pcgSource
+	rng           *rand.Rand
+	replaySource  *pcgSource // draws replay samples
+	replayRand    *rand.Rand
+	dropoutSource *pcgSource // draws dropout masks
+	dropoutRand   *rand.Rand
+	probeStates   [][]float64
+	probeEvery    int
+	probeHistory  []ProbeRecord
+	lambda        float64
+	traces        *gradients
+	nSteps        int
+	discount      float64 // gamma^nSteps, applied to bootstrapped values
+	// Update schedule of Observe
+	batchSize     int
+	warmupSteps   int
+	trainEvery    int
+	gradientSteps int
+	steps         int
+	// optimizer is nil for plain SGD
+	optimizer   *adam
+	syncReset   bool
+	syncDamping float64
+	// exploration replaces epsilon-greedy acting in the Trainer if set
+	exploration Exploration
+	// profiler times the phases of training if profiling is enabled
+	profiler *profiler
 }
 
 // NewDQN initializes a new DQN instance.
 func NewDQN(inputSize, hiddenSize, outputSize, bufferSize int, gamma, epsilon, learningRate float64, activation Activation) *DQN {
-	return &DQN{
-		qNetwork:     NewQNetwork(inputSize, hiddenSize, outputSize, activation),
-		replayBuffer: NewReplayBuffer(bufferSize),
-		gamma:        gamma,
-		epsilon:      epsilon,
-		learningRate: learningRate,
+	return NewDQNFromConfig(Config{
+		StateSize:    inputSize,
+		NumActions:   outputSize,
+		HiddenSize:   hiddenSize,
+		BufferSize:   bufferSize,
+		Gamma:        gamma,
+		Epsilon:      epsilon,
+		LearningRate: learningRate,
+		Activation:   activation,
+	})
+}
+
+// NewDQNFromConfig initializes a new DQN instance from a Config.
+func NewDQNFromConfig(cfg Config) *DQN {
+	// Every subsystem seed derives from the seed actually used
+	if cfg.Seed == 0 {
+		cfg.Seed = rand.Int63()
+	}
+	initRand := rand.New(newPCGSource(cfg.subsystemSeed(cfg.InitSeed, initStream))).Float64
+	initializer := cfg.Initializer
+	if initializer == "" {
+		initializer = "xavier"
+	}
+	if cfg.Lambda > 0 && (cfg.BatchSize > 0 || cfg.TrainEvery > 1 || cfg.GradientStepsPerUpdate > 1) {
+		panic("Eligibility traces need exactly one update per transition, without replay batches")
+	}
+	qNetwork := newQNetwork(cfg.StateSize, cfg.HiddenSize, cfg.NumActions, cfg.Activation, initializer, initRand)
+	qNetwork.SetDropout(cfg.Dropout)
+	qNetwork.SetWeightDecay(cfg.WeightDecay)
+	replayBuffer := cfg.ReplayBuffer
+	if replayBuffer == nil && cfg.DeltaEncoding {
+		replayBuffer = NewDeltaReplayBuffer(cfg.BufferSize)
+	} else if replayBuffer == nil {
+		replayBuffer = NewReplayBuffer(cfg.BufferSize)
+	}
+	d := &DQN{
+		qNetwork:      qNetwork,
+		replayBuffer:  replayBuffer,
+		gamma:         cfg.Gamma,
+		epsilon:       cfg.Epsilon,
+		learningRate:  cfg.LearningRate,
+		targetSync:    cfg.TargetSyncInterval,
+		lambda:        cfg.Lambda,
+		nSteps:        max(1, cfg.NSteps),
+		discount:      math.Pow(cfg.Gamma, float64(max(1, cfg.NSteps))),
+		batchSize:     cfg.BatchSize,
+		warmupSteps:   cfg.WarmupSteps,
+		trainEvery:    max(1, cfg.TrainEvery),
+		gradientSteps: max(1, cfg.GradientStepsPerUpdate),
+		rngSource:     newPCGSource(cfg.Seed),
+		replaySource:  newPCGSource(cfg.subsystemSeed(cfg.ReplaySeed, replayStream)),
+		dropoutSource: newPCGSource(cfg.subsystemSeed(0, dropoutStream)),
+		syncReset:     cfg.SyncReset,
+		syncDamping:   cfg.SyncDamping,
+		exploration:   cfg.Exploration,
+	}
+	if cfg.Adam {
+		d.optimizer = newAdam()
+	}
+	if cfg.Profile {
+		d.profiler = &profiler{}
+	}
+	d.rng = rand.New(d.rngSource)
+	d.replayRand = rand.New(d.replaySource)
+	d.dropoutRand = rand.New(d.dropoutSource)
+	if rb, ok := d.replayBuffer.(*ReplayBuffer); ok {
+		rb.rng = d.replayRand
+		if cfg.SubsampleEvery != 0 || cfg.SubsampleMinChange != 0 {
+			rb.SetSubsampling(cfg.SubsampleEvery, cfg.SubsampleMinChange)
+		}
 	}
+	if d.targetSync > 0 {
+		d.targetNetwork = d.qNetwork.Clone()
+	}
+	return d
+}
+
+// NewDQNForEnv initializes a new DQN instance with hyperparameters picked
+// by DefaultConfig for the environment's dimensions.
+func NewDQNForEnv(env Environment) *DQN {
+	return NewDQNFromConfig(DefaultConfig(env.StateSize(), env.NumActions()))
+}
+
+// StepReport describes a single training update.
+type StepReport struct {
+	Loss       float64 // mean squared error over all Q-values
+	TDError    float64 // target minus the predicted Q-value of the action
+	Target     float64 // bootstrapped target for the action
+	Prediction float64 // predicted Q-value of the action before the update
+	GradNorm   float64 // L2 norm of the gradient
 }
 
 // Train trains the Q-network.
 func (d *DQN) Train(state, nextState []float64, action, reward int, done bool) {
-	nextQValues := d.qNetwork.Predict(nextState)
-	maxNextQValue := Max(nextQValues)
-	target := make([]float64, len(nextQValues))
-	copy(target, nextQValues)
-	target[action] = float64(reward)
+	d.TrainMasked(state, nextState, action, reward, done, nil)
+}
+
+// TrainStep trains the Q-network and reports on the update.
+func (d *DQN) TrainStep(state, nextState []float64, action, reward int, done bool) StepReport {
+	return d.TrainStepMasked(state, nextState, action, reward, done, nil)
+}
+
+// TrainMasked trains the Q-network, taking the max over next Q-values only for
+// actions that are legal in nextState. A nil mask marks every action as legal.
+func (d *DQN) TrainMasked(state, nextState []float64, action, reward int, done bool, nextMask []bool) {
+	d.TrainStepMasked(state, nextState, action, reward, done, nextMask)
+}
+
+// TrainStepMasked is like TrainMasked but reports on the update.
+func (d *DQN) TrainStepMasked(state, nextState []float64, action, reward int, done bool, nextMask []bool) StepReport {
+	tdGradients := d.tdGradients
+	if d.lambda > 0 {
+		tdGradients = d.traceGradients
+	}
+	report, g := tdGradients(state, nextState, action, reward, done, nextMask)
+	d.apply(g)
+	d.afterUpdate(report)
+	return report
+}
+
+// TrainBatch performs a single update on the mean gradient of a batch of
+// experiences and reports the mean over the batch. Batches are always
+// trained on one-step targets, without eligibility traces.
+func (d *DQN) TrainBatch(batch []Experience) StepReport {
+	if len(batch) == 0 {
+		panic("Cannot train on an empty batch")
+	}
+	var report StepReport
+	var sum *gradients
+	for _, exp := range batch {
+		r, g := d.tdGradients(exp.State, exp.NextState, exp.Action, exp.Reward, exp.terminal(), exp.NextMask)
+		if sum == nil {
+			sum = g
+		} else {
+			sum.add(g)
+		}
+		report.Loss += r.Loss
+		report.TDError += r.TDError
+		report.Target += r.Target
+		report.Prediction += r.Prediction
+	}
+
+	n := float64(len(batch))
+	sum.scale(1 / n)
+	report.Loss /= n
+	report.TDError /= n
+	report.Target /= n
+	report.Prediction /= n
+	report.GradNorm = sum.norm()
+
+	d.apply(sum)
+	d.afterUpdate(report)
+	return report
+}
+
+// Observe stores a transition in the replay buffer and trains according to
+// the update schedule: nothing during the first WarmupSteps transitions, then
+// GradientStepsPerUpdate updates every TrainEvery transitions. Each update
+// trains on a sampled batch of BatchSize experiences, or on the transition
+// itself when BatchSize is zero. It reports on the last update and whether
+// any update was made.
+func (d *DQN) Observe(exp Experience) (StepReport, bool) {
+	start := d.profiler.start()
+	d.replayBuffer.Add(exp)
+	d.profiler.stop(phaseReplay, start)
+	d.steps++
+	if d.steps <= d.warmupSteps || d.steps%d.trainEvery != 0 {
+		return StepReport{}, false
+	}
+
+	var report StepReport
+	for i := 0; i < d.gradientSteps; i++ {
+		if d.batchSize > 0 && d.replayBuffer.Len() > 0 {
+			start := d.profiler.start()
+			batch := d.replayBuffer.Sample(d.batchSize)
+			d.profiler.stop(phaseSample, start)
+			report = d.TrainBatch(batch)
+		} else {
+			report = d.TrainStepMasked(exp.State, exp.NextState, exp.Action, exp.Reward, exp.terminal(), exp.NextMask)
+			if exp.Truncated {
+				d.ResetTraces()
+			}
+		}
+	}
+	return report, true
+}
+
+// tdGradients computes the TD target for a transition and the gradients of
+// the resulting loss, without updating the network.
+func (d *DQN) tdGradients(state, nextState []float64, action, reward int, done bool, nextMask []bool) (StepReport, *gradients) {
+	return d.tdGradientsWith(d.qNetwork.dropoutMask(d.dropoutRand.Float64), state, nextState, action, reward, done, nextMask)
+}
+
+// tdGradientsWith is like tdGradients with the given dropout mask, nil for
+// none, so that diagnostics do not draw from the exploration stream.
+func (d *DQN) tdGradientsWith(mask *mat.VecDense, state, nextState []float64, action, reward int, done bool, nextMask []bool) (StepReport, *gradients) {
+	start := d.profiler.start()
+	targetValue := d.tdTarget(nextState, reward, done, nextMask)
+	d.profiler.stop(phaseTarget, start)
+
+	// Only the chosen action has a target; the others keep their prediction
+	start = d.profiler.start()
+	currentQValues := d.qNetwork.forward(state, mask)
+	d.profiler.stop(phaseForward, start)
+	target := make([]float64, len(currentQValues))
+	copy(target, currentQValues)
+	target[action] = targetValue
+
+	report := StepReport{
+		Loss:       d.qNetwork.Loss(currentQValues, target),
+		TDError:    targetValue - currentQValues[action],
+		Target:     targetValue,
+		Prediction: currentQValues[action],
+	}
+	start = d.profiler.start()
+	g := d.qNetwork.gradients(state, currentQValues, target, mask)
+	report.GradNorm = g.norm()
+	d.profiler.stop(phaseBackward, start)
+	return report, g
+}
+
+// tdTarget returns the bootstrapped target of a transition, which spans
+// nSteps environment steps unless it is done.
+func (d *DQN) tdTarget(nextState []float64, reward int, done bool, nextMask []bool) float64 {
+	targetValue := float64(reward)
 	if !done {
-		target[action] += d.gamma * maxNextQValue
+		targetValue += d.discount * MaskedMax(d.targetPredict(nextState), nextMask)
 	}
+	return targetValue
+}
 
-	currentQValues := d.qNetwork.Predict(state)
-	// loss := d.qNetwork.Loss(currentQValues, target)
+// apply updates the Q-network with g through the configured optimizer.
+func (d *DQN) apply(g *gradients) {
+	start := d.profiler.start()
+	if d.optimizer != nil {
+		g = d.optimizer.step(g)
+	}
+	d.qNetwork.apply(g, d.learningRate)
+	d.profiler.stop(phaseOptimizer, start)
+}
+
+// afterUpdate does the bookkeeping that follows every update.
+func (d *DQN) afterUpdate(report StepReport) {
+	d.lastLoss = report.Loss
+	d.updates++
+	if d.profiler != nil {
+		d.profiler.updates++
+	}
+	if d.targetNetwork != nil && d.updates%d.targetSync == 0 {
+		d.targetNetwork = d.qNetwork.Clone()
+		if d.optimizer != nil && d.syncReset {
+			d.optimizer.dampen(d.syncDamping)
+		}
+	}
+	d.recordProbes()
+}
 
-	d.qNetwork.Backward(state, currentQValues, target, d.learningRate)
+// LastLoss returns the loss of the most recent update.
+func (d *DQN) LastLoss() float64 {
+	return d.lastLoss
+}
+
+// ReplaySnapshot returns a copy of the experiences in the agent's replay
+// buffer, oldest first.
+func (d *DQN) ReplaySnapshot() []Experience {
+	return d.replayBuffer.Snapshot()
+}
+
+// targetPredict returns the Q-values used for bootstrapping, taken from the
+// target network when one is configured.
+func (d *DQN) targetPredict(state []float64) []float64 {
+	if d.targetNetwork != nil {
+		return d.targetNetwork.Predict(state)
+	}
+	return d.qNetwork.Predict(state)
 }
 
 // EpsilonGreedyPolicy selects an action using epsilon-greedy strategy.
 func (d *DQN) EpsilonGreedyPolicy(state []float64, numActions int) int {
-	if rand.Float64() < d.epsilon {
-		return rand.Intn(numActions)
+	if d.rng.Float64() < d.epsilon {
+		return d.rng.Intn(numActions)
 	}
+	return d.GreedyPolicy(state)
+}
+
+// GreedyPolicy selects the action with the highest Q-value.
+func (d *DQN) GreedyPolicy(state []float64) int {
 	qValues := d.qNetwork.Predict(state)
 	return Argmax(qValues)
 }
 
+// MaskedEpsilonGreedyPolicy selects an action using epsilon-greedy strategy,
+// never choosing an action whose mask entry is false.
+func (d *DQN) MaskedEpsilonGreedyPolicy(state []float64, mask []bool) int {
+	if d.rng.Float64() < d.epsilon {
+		legal := legalActions(mask)
+		if len(legal) == 0 {
+			panic("Action mask has no legal actions")
+		}
+		return legal[d.rng.Intn(len(legal))]
+	}
+	return d.MaskedGreedyPolicy(state, mask)
+}
+
+// MaskedGreedyPolicy selects the legal action with the highest Q-value.
+func (d *DQN) MaskedGreedyPolicy(state []float64, mask []bool) int {
+	qValues := d.qNetwork.Predict(state)
+	return MaskedArgmax(qValues, mask)
+}
+
 // Helper functions
 
+// legalActions returns the indices of the true entries in mask
+func legalActions(mask []bool) []int {
+	var legal []int
+	for i, ok := range mask {
+		if ok {
+			legal = append(legal, i)
+		}
+	}
+	return legal
+}
+
+// MaskedMax returns the maximum value among entries allowed by mask.
+// A nil mask allows every entry.
+func MaskedMax(arr []float64, mask []bool) float64 {
+	return arr[MaskedArgmax(arr, mask)]
+}
+
+// MaskedArgmax returns the index of the maximum value among entries allowed by mask.
+// A nil mask allows every entry.
+func MaskedArgmax(arr []float64, mask []bool) int {
+	if mask == nil {
+		return Argmax(arr)
+	}
+	if len(mask) != len(arr) {
+		panic("Mask and values must have the same length")
+	}
+	maxIdx := -1
+	for i, val := range arr {
+		if mask[i] && (maxIdx < 0 || val > arr[maxIdx]) {
+			maxIdx = i
+		}
+	}
+	if maxIdx < 0 {
+		panic("Action mask has no legal actions")
+	}
+	return maxIdx
+}
+
 // Max returns the maximum value in a slice of float64
 func Max(arr []float64) float64 {
 	maxVal := arr[0]
@@ -75,4 +422,4 @@ func Argmax(arr []float64) int {
 		}
 	}
 	return maxIdx
-}
\ No newline at end of file
+}
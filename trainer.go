@@ -0,0 +1,544 @@
+// trainer.go
+package dqn
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+// EpisodeStats holds statistics of a single training episode.
+type EpisodeStats struct {
+	Episode int
+	Return  float64
+	Steps   int
+	Loss    float64 // mean loss over the episode's updates
+	Epsilon float64
+
+	updates int // steps that made an update
+}
+
+// Trainer runs the training loop of a DQN agent on an environment.
+type Trainer struct {
+	agent     *DQN
+	env       Environment
+	vec       *VecEnv
+	cfg       Config
+	history   []EpisodeStats
+	debugger  *Debugger
+	callbacks []func(*Trainer, EpisodeStats)
+	limiters  []*ActionLimiter // one per environment, if actions are limited
+	// accumulators build the n-step experiences of each environment
+	accumulators []*NStepAccumulator
+	// traces holds the eligibility traces of each environment, if the agent uses them
+	traces []*gradients
+	// params switches the environment parameters between training and evaluation
+	params *paramEnv
+
+	// Early stopping state
+	evaluations    []EvalResult
+	best           *Policy
+	bestScore      float64
+	sinceBest      int
+	aboveThreshold int
+	stopReason     string
+
+	// next is the index in history of the next episode returned by Next
+	next int
+}
+
+// NewTrainer initializes a new Trainer. Options not related to training are ignored.
+func NewTrainer(agent *DQN, env Environment, opts ...Option) *Trainer {
+	cfg := DefaultConfig(env.StateSize(), env.NumActions())
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	// Seed the environments from the agent's seed when none is given
+	if cfg.Seed == 0 {
+		cfg.Seed = agent.rngSource.seed
+	}
+	if seeder, ok := env.(Seeder); ok {
+		if seed := cfg.subsystemSeed(cfg.EnvSeed, envStream); seed != 0 {
+			seeder.Seed(seed)
+		}
+	}
+	seedCuriosity(cfg)
+	var params *paramEnv
+	if !cfg.TrainParams.empty() || !cfg.EvalParams.empty() {
+		params = newParamEnv(env, cfg)
+		env = params
+	}
+	if cfg.Pipeline != nil {
+		env = cfg.Pipeline.Wrap(env)
+	}
+	t := &Trainer{agent: agent, env: env, cfg: cfg, limiters: newActionLimiters(cfg, 1), params: params}
+	t.accumulators = t.newAccumulators(1)
+	t.traces = t.newTraces(1)
+	return t
+}
+
+// NewVecTrainer initializes a new Trainer that collects experience from
+// several environments in parallel. A configured pipeline wraps every
+// environment of vec, including replacements of environments that panic.
+func NewVecTrainer(agent *DQN, vec *VecEnv, opts ...Option) *Trainer {
+	cfg := DefaultConfig(vec.StateSize(), vec.NumActions())
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	// Seed the environments from the agent's seed when none is given
+	if cfg.Seed == 0 {
+		cfg.Seed = agent.rngSource.seed
+	}
+	if seed := cfg.subsystemSeed(cfg.EnvSeed, envStream); seed != 0 {
+		for i, env := range vec.envs {
+			if seeder, ok := env.(Seeder); ok {
+				seeder.Seed(seed + int64(i))
+			}
+		}
+	}
+	seedCuriosity(cfg)
+	t := &Trainer{agent: agent, vec: vec, cfg: cfg, limiters: newActionLimiters(cfg, len(vec.envs))}
+	if cfg.Pipeline != nil {
+		vec.wrap(t.wrapPipeline)
+	}
+	t.accumulators = t.newAccumulators(len(vec.envs))
+	t.traces = t.newTraces(len(vec.envs))
+	return t
+}
+
+// seedCuriosity seeds a configured RND that has not been seeded explicitly
+// or used yet from cfg.Seed.
+func seedCuriosity(cfg Config) {
+	if r := cfg.Curiosity; r != nil && r.seed == 0 && r.count == 0 {
+		r.Seed(cfg.subsystemSeed(0, curiosityStream))
+	}
+}
+
+// Run trains the agent until the configured number of episodes has been run,
+// counting episodes restored by Resume, and returns the statistics of every
+// episode. With a VecEnv, episodes that finish in the same batched step as
+// the last required one are recorded too.
+//
+// With EvalEvery set, the greedy policy is evaluated periodically on the
+// training environment, on episodes seeded from the evaluation stream, and
+// Run stops early once a stopping criterion is met (see StopReason). Early
+// stopping is not supported with a VecEnv.
+//
+// If ctx is cancelled, Run stops after the current step, discards the
+// unfinished episode and returns ctx.Err(). Likewise, if an environment
+// reports an error through an Err method, e.g. a remote environment whose
+// simulator is gone, Run stops without training on the failed step and
+// returns the error. In every case the policy is saved
+// to the checkpoint path, and a final rolling checkpoint is written, if they
+// are configured, before Run returns. When evaluations have run, the saved
+// policy is the best one seen.
+//
+// With a checkpoint directory, Run first records a RunManifest of the
+// software and hardware in it.
+func (t *Trainer) Run(ctx context.Context) ([]EpisodeStats, error) {
+	if t.cfg.CheckpointDir != "" {
+		if err := t.writeRunManifest(); err != nil {
+			return t.history, err
+		}
+	}
+	err := t.run(ctx)
+	if t.cfg.CheckpointDir != "" {
+		if saveErr := t.saveCheckpoint(); err == nil {
+			err = saveErr
+		}
+	}
+	if t.cfg.CheckpointPath != "" {
+		policy := t.best
+		if policy == nil {
+			policy = t.policy()
+		}
+		if saveErr := savePolicy(policy, t.cfg.CheckpointPath); err == nil {
+			err = saveErr
+		}
+	}
+	t.next = len(t.history)
+	return t.history, err
+}
+
+// run trains the agent until the configured number of episodes is reached or ctx is cancelled.
+func (t *Trainer) run(ctx context.Context) error {
+	if t.vec != nil {
+		return t.runVec(ctx, t.cfg.Episodes)
+	}
+	for len(t.history) < t.cfg.Episodes {
+		if stop, err := t.trainEpisode(ctx); err != nil || stop {
+			return err
+		}
+	}
+	return nil
+}
+
+// trainEpisode trains and records a single episode, runs a periodic
+// evaluation if one is due and reports whether a stopping criterion is met.
+func (t *Trainer) trainEpisode(ctx context.Context) (bool, error) {
+	stats, err := t.runEpisode(ctx, len(t.history))
+	if err != nil {
+		return false, err
+	}
+	t.appendEpisode(stats)
+	stop := t.cfg.EvalEvery > 0 && len(t.history)%t.cfg.EvalEvery == 0 && t.evaluate()
+	return stop, t.checkpointIfDue()
+}
+
+// ErrTrainingDone is returned by Next once training has finished.
+var ErrTrainingDone = errors.New("dqn: training done")
+
+// Next trains until the next episode finishes and returns its statistics,
+// so that training can be stepped incrementally, e.g. from a notebook. It
+// returns ErrTrainingDone once the configured number of episodes has been
+// run or a stopping criterion was met. Episodes run by Run or restored by
+// Resume are not returned. Unlike Run, Next does not write the run manifest,
+// the final checkpoint or the policy; call Run to finish training with them.
+//
+// With a VecEnv, a call can finish several episodes, which are returned by
+// the following calls, and unfinished episodes are discarded between calls.
+func (t *Trainer) Next(ctx context.Context) (EpisodeStats, error) {
+	if t.next >= len(t.history) {
+		if t.stopReason != "" || len(t.history) >= t.cfg.Episodes {
+			return EpisodeStats{}, ErrTrainingDone
+		}
+		var err error
+		if t.vec != nil {
+			err = t.runVec(ctx, len(t.history)+1)
+		} else {
+			_, err = t.trainEpisode(ctx)
+		}
+		if err != nil {
+			return EpisodeStats{}, err
+		}
+	}
+	stats := t.history[t.next]
+	t.next++
+	return stats, nil
+}
+
+// policy returns the agent's current policy with the configured pipeline
+// and action limits attached.
+func (t *Trainer) policy() *Policy {
+	policy := t.agent.Policy().WithActionLimits(t.cfg.ActionLimits...)
+	if t.cfg.Pipeline != nil {
+		policy = policy.WithPipeline(t.cfg.Pipeline)
+	}
+	return policy
+}
+
+// History returns the statistics of every episode run so far.
+func (t *Trainer) History() []EpisodeStats {
+	return t.history
+}
+
+// AddCallback registers a function called after every finished episode.
+func (t *Trainer) AddCallback(fn func(*Trainer, EpisodeStats)) {
+	t.callbacks = append(t.callbacks, fn)
+}
+
+// record appends finished episode statistics to the history, notifies the
+// callbacks and writes a rolling checkpoint when one is due.
+func (t *Trainer) record(stats EpisodeStats) error {
+	t.appendEpisode(stats)
+	return t.checkpointIfDue()
+}
+
+// appendEpisode appends finished episode statistics to the history and
+// notifies the callbacks.
+func (t *Trainer) appendEpisode(stats EpisodeStats) {
+	if stats.updates > 0 {
+		stats.Loss /= float64(stats.updates)
+	}
+	stats.Epsilon = t.agent.epsilon
+	t.history = append(t.history, stats)
+	for _, fn := range t.callbacks {
+		fn(t, stats)
+	}
+}
+
+// checkpointIfDue writes a rolling checkpoint if one is due after the last
+// recorded episode.
+func (t *Trainer) checkpointIfDue() error {
+	if t.cfg.CheckpointDir != "" && t.cfg.CheckpointEvery > 0 && len(t.history)%t.cfg.CheckpointEvery == 0 {
+		return t.saveCheckpoint()
+	}
+	return nil
+}
+
+// runEpisode trains the agent on a single episode.
+func (t *Trainer) runEpisode(ctx context.Context, episode int) (EpisodeStats, error) {
+	stats := EpisodeStats{Episode: episode}
+	state := t.env.Reset()
+	if err := envErr(t.env); err != nil {
+		return stats, err
+	}
+	t.resetEpisode(0)
+	for done := false; !done; {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		action := t.act(0, state)
+		if t.debugger != nil {
+			t.debugger.before(DebugStep{
+				Episode:   episode,
+				Step:      stats.Steps,
+				State:     state,
+				QValues:   t.agent.qNetwork.Predict(state),
+				Action:    action,
+				BufferLen: t.agent.replayBuffer.Len(),
+				BufferCap: t.agent.replayBuffer.Cap(),
+			})
+		}
+		nextState, reward, stepDone := t.env.Step(action)
+		if err := envErr(t.env); err != nil {
+			return stats, err
+		}
+		if interrupted(t.env) {
+			// The episode was cut short and its last transition is not real
+			return stats, nil
+		}
+		report, updated := t.observe(0, Experience{
+			State:     state,
+			NextState: nextState,
+			Action:    action,
+			Reward:    t.trainingReward(nextState, reward),
+			Done:      stepDone,
+			NextMask:  t.nextMask(0, stepDone),
+			Truncated: stepDone && truncated(t.env),
+		})
+
+		stats.Return += reward
+		stats.Loss += report.Loss
+		if updated {
+			stats.updates++
+		}
+		stats.Steps++
+		state = nextState
+		done = stepDone
+	}
+	return stats, nil
+}
+
+// wrapPipeline wraps env with the configured pipeline.
+func (t *Trainer) wrapPipeline(env Environment) Environment {
+	return t.cfg.Pipeline.Wrap(env)
+}
+
+// setPipeline replaces the configured pipeline, e.g. with the one restored
+// from a checkpoint, in the training environments.
+func (t *Trainer) setPipeline(p *Pipeline) {
+	wrapped := t.cfg.Pipeline != nil
+	t.cfg.Pipeline = p
+	if t.vec != nil {
+		if !wrapped {
+			t.vec.wrap(t.wrapPipeline)
+			return
+		}
+		for _, env := range t.vec.envs {
+			if pe, ok := env.(*pipelineEnv); ok {
+				pe.pipeline = p.Clone()
+			}
+		}
+		return
+	}
+	if pe, ok := t.env.(*pipelineEnv); ok {
+		pe.pipeline = p.Clone()
+	} else {
+		t.env = p.Wrap(t.env)
+	}
+}
+
+// rawEnv returns the training environment without the feature pipeline.
+func (t *Trainer) rawEnv() Environment {
+	if p, ok := t.env.(*pipelineEnv); ok {
+		return p.Environment
+	}
+	return t.env
+}
+
+// setEvaluating switches the environment to the evaluation parameters, or
+// back to the training parameters.
+func (t *Trainer) setEvaluating(evaluating bool) {
+	if t.params != nil {
+		t.params.evaluating = evaluating
+	}
+}
+
+// newActionLimiters returns one action limiter per environment, or nil if
+// the configuration has no action limits.
+func newActionLimiters(cfg Config, n int) []*ActionLimiter {
+	if len(cfg.ActionLimits) == 0 {
+		return nil
+	}
+	limiters := make([]*ActionLimiter, n)
+	for i := range limiters {
+		limiters[i] = NewActionLimiter(cfg.NumActions, cfg.ActionLimits...)
+	}
+	return limiters
+}
+
+// act selects an exploratory action for environment i among the actions
+// allowed by its action limits.
+func (t *Trainer) act(i int, state []float64) int {
+	if t.limiters == nil {
+		return t.agent.Explore(state, nil)
+	}
+	action := t.agent.Explore(state, t.limiters[i].Mask())
+	t.limiters[i].Record(action)
+	return action
+}
+
+// nextMask returns the actions allowed after the last step of environment i,
+// or nil if actions are not limited or the episode is done.
+func (t *Trainer) nextMask(i int, done bool) []bool {
+	if t.limiters == nil {
+		return nil
+	}
+	if done {
+		t.limiters[i].Reset()
+		return nil
+	}
+	return t.limiters[i].Mask()
+}
+
+// newAccumulators returns one n-step accumulator per environment, or nil if
+// the agent trains on one-step targets.
+func (t *Trainer) newAccumulators(n int) []*NStepAccumulator {
+	if t.agent.nSteps <= 1 {
+		return nil
+	}
+	accumulators := make([]*NStepAccumulator, n)
+	for i := range accumulators {
+		accumulators[i] = NewNStepAccumulator(t.agent.nSteps, t.agent.gamma)
+	}
+	return accumulators
+}
+
+// newTraces returns an empty eligibility trace per environment, or nil if
+// the agent does not use traces.
+func (t *Trainer) newTraces(n int) []*gradients {
+	if t.agent.lambda <= 0 {
+		return nil
+	}
+	return make([]*gradients, n)
+}
+
+// observe passes a transition of environment i to the agent, as part of
+// n-step experiences if configured, and reports on the last update made.
+// The agent's eligibility trace is swapped for the one of environment i, so
+// that interleaved transitions of several environments do not share a trace.
+func (t *Trainer) observe(i int, exp Experience) (StepReport, bool) {
+	if t.traces != nil {
+		t.agent.traces = t.traces[i]
+		defer func() { t.traces[i] = t.agent.traces }()
+	}
+	if t.accumulators == nil {
+		return t.agent.Observe(exp)
+	}
+	var report StepReport
+	var updated bool
+	for _, e := range t.accumulators[i].Add(exp) {
+		if r, ok := t.agent.Observe(e); ok {
+			report, updated = r, true
+		}
+	}
+	return report, updated
+}
+
+// resetEpisode clears the per-episode state of environment i, such as recent
+// actions, pending n-step transitions and eligibility traces, at the start
+// of an episode.
+func (t *Trainer) resetEpisode(i int) {
+	if t.traces != nil {
+		t.traces[i] = nil
+	}
+	if t.limiters != nil {
+		t.limiters[i].Reset()
+	}
+	if t.accumulators != nil {
+		t.accumulators[i].Reset()
+	}
+}
+
+// scaleReward converts an environment reward to the integer reward expected by Train.
+func (t *Trainer) scaleReward(reward float64) int {
+	return scaleReward(reward, t.cfg.RewardScale)
+}
+
+// scaleReward multiplies reward by scale and rounds it to an integer reward.
+func scaleReward(reward, scale float64) int {
+	return int(math.Round(reward * scale))
+}
+
+// trainingReward returns the reward the agent trains on for reaching
+// nextState, including the curiosity bonus if one is configured.
+func (t *Trainer) trainingReward(nextState []float64, reward float64) int {
+	if t.cfg.Curiosity != nil {
+		reward += t.cfg.Curiosity.Bonus(nextState)
+	}
+	return t.scaleReward(reward)
+}
+
+// runVec trains the agent on batched transitions until the history holds target episodes.
+func (t *Trainer) runVec(ctx context.Context, target int) error {
+	states := t.vec.Reset()
+	if err := t.vec.err(); err != nil {
+		return err
+	}
+	for i := range states {
+		t.resetEpisode(i)
+	}
+	running := make([]EpisodeStats, len(states))
+	actions := make([]int, len(states))
+	for len(t.history) < target {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for i, state := range states {
+			actions[i] = t.act(i, state)
+		}
+		step := t.vec.Step(actions)
+		if err := t.vec.err(); err != nil {
+			return err
+		}
+
+		for i := range states {
+			if step.Interrupted[i] {
+				// The episode was cut short and its last transition is not real
+				running[i].Episode = len(t.history)
+				if err := t.record(running[i]); err != nil {
+					return err
+				}
+				running[i] = EpisodeStats{}
+				t.resetEpisode(i)
+				continue
+			}
+			report, updated := t.observe(i, Experience{
+				State:     states[i],
+				NextState: step.NextStates[i],
+				Action:    actions[i],
+				Reward:    t.trainingReward(step.NextStates[i], step.Rewards[i]),
+				Done:      step.Dones[i],
+				NextMask:  t.nextMask(i, step.Dones[i]),
+				Truncated: step.Truncated[i],
+			})
+			running[i].Return += step.Rewards[i]
+			running[i].Loss += report.Loss
+			if updated {
+				running[i].updates++
+			}
+			running[i].Steps++
+			if step.Dones[i] {
+				running[i].Episode = len(t.history)
+				if err := t.record(running[i]); err != nil {
+					return err
+				}
+				running[i] = EpisodeStats{}
+				t.resetEpisode(i)
+			}
+		}
+		states = step.States
+	}
+	return nil
+}
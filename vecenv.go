@@ -0,0 +1,234 @@
+// vecenv.go
+package dqn
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// maxWorkerRestarts is the number of times in a row a worker is replaced
+// after panicking on Reset before the panic is propagated to the caller of
+// VecEnv.Reset or VecEnv.Step.
+const maxWorkerRestarts = 3
+
+// VecEnv steps several copies of an environment in parallel goroutines.
+// Environments that finish an episode are reset automatically.
+//
+// A panic in an environment's Reset or Step is recovered and recorded as a
+// WorkerPanic. The environment is closed if it implements io.Closer and
+// replaced with a new one from the factory, so that one bad environment
+// does not crash a long run. A Step that panics ends the episode with the
+// last known state and a zero reward, and is reported as interrupted.
+type VecEnv struct {
+	envs    []Environment
+	factory func() Environment
+	last    [][]float64 // last state of every environment
+
+	mu      sync.Mutex
+	panics  []WorkerPanic
+	onPanic func(WorkerPanic)
+}
+
+// WorkerPanic records a panic recovered from an environment of a VecEnv.
+type WorkerPanic struct {
+	Time   time.Time
+	Worker int
+	Op     string    // "reset" or "step"
+	State  []float64 // last state before the call, nil before the first Reset
+	Action int       // action passed to Step, -1 for Reset
+	Value  any       // value passed to panic
+	Stack  string
+}
+
+// String formats the panic for logging.
+func (p WorkerPanic) String() string {
+	return fmt.Sprintf("worker %d panicked in %s (state %v, action %d): %v", p.Worker, p.Op, p.State, p.Action, p.Value)
+}
+
+// VecStep is the batched result of VecEnv.Step.
+type VecStep struct {
+	// NextStates holds the state each environment reached, including terminal states.
+	NextStates [][]float64
+	Rewards    []float64
+	Dones      []bool
+	// States holds the states to act from next: NextStates, or the initial
+	// state of the new episode for environments that finished.
+	States [][]float64
+	// Interrupted marks steps that panicked or were interrupted, see
+	// Interrupter. They end the episode but are not real transitions.
+	Interrupted []bool
+	// Truncated marks steps that truncated the episode, see Truncater.
+	Truncated []bool
+}
+
+// NewVecEnv initializes a new VecEnv with n environments created by factory.
+// The factory is called again to replace environments that panic.
+func NewVecEnv(n int, factory func() Environment) *VecEnv {
+	envs := make([]Environment, n)
+	for i := range envs {
+		envs[i] = factory()
+	}
+	return &VecEnv{envs: envs, factory: factory, last: make([][]float64, n)}
+}
+
+// wrap replaces every environment, and every future replacement, with the
+// result of fn, e.g. to transform their states with a pipeline.
+func (v *VecEnv) wrap(fn func(Environment) Environment) {
+	for i, env := range v.envs {
+		v.envs[i] = fn(env)
+	}
+	factory := v.factory
+	v.factory = func() Environment { return fn(factory()) }
+}
+
+// Len returns the number of environments.
+func (v *VecEnv) Len() int {
+	return len(v.envs)
+}
+
+// StateSize returns the length of the state vector.
+func (v *VecEnv) StateSize() int {
+	return v.envs[0].StateSize()
+}
+
+// NumActions returns the number of discrete actions.
+func (v *VecEnv) NumActions() int {
+	return v.envs[0].NumActions()
+}
+
+// OnPanic registers a function called with every recovered panic, e.g. to
+// log it. It is called from the worker goroutines, one call at a time.
+func (v *VecEnv) OnPanic(fn func(WorkerPanic)) {
+	v.onPanic = fn
+}
+
+// Panics returns every panic recovered so far.
+func (v *VecEnv) Panics() []WorkerPanic {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]WorkerPanic(nil), v.panics...)
+}
+
+// Reset resets every environment and returns the initial states.
+func (v *VecEnv) Reset() [][]float64 {
+	states := make([][]float64, len(v.envs))
+	v.parallel(func(i int) {
+		states[i] = v.reset(i)
+	})
+	return states
+}
+
+// Step applies one action per environment.
+func (v *VecEnv) Step(actions []int) VecStep {
+	if len(actions) != len(v.envs) {
+		panic("Number of actions does not match number of environments")
+	}
+
+	step := VecStep{
+		NextStates:  make([][]float64, len(v.envs)),
+		Rewards:     make([]float64, len(v.envs)),
+		Dones:       make([]bool, len(v.envs)),
+		States:      make([][]float64, len(v.envs)),
+		Interrupted: make([]bool, len(v.envs)),
+		Truncated:   make([]bool, len(v.envs)),
+	}
+	v.parallel(func(i int) {
+		ok := v.guard(i, "step", actions[i], func() {
+			step.NextStates[i], step.Rewards[i], step.Dones[i] = v.envs[i].Step(actions[i])
+		})
+		if !ok {
+			step.NextStates[i], step.Rewards[i], step.Dones[i] = v.last[i], 0, true
+		}
+		step.Interrupted[i] = !ok || interrupted(v.envs[i])
+		step.Truncated[i] = ok && step.Dones[i] && truncated(v.envs[i])
+		step.States[i] = step.NextStates[i]
+		v.last[i] = step.NextStates[i]
+		if step.Dones[i] {
+			step.States[i] = v.reset(i)
+		}
+	})
+	return step
+}
+
+// reset resets environment i, replacing it while its Reset panics.
+func (v *VecEnv) reset(i int) []float64 {
+	for attempt := 1; ; attempt++ {
+		var state []float64
+		if v.guard(i, "reset", -1, func() { state = v.envs[i].Reset() }) {
+			v.last[i] = state
+			return state
+		}
+		if attempt > maxWorkerRestarts {
+			panic(fmt.Sprintf("Environment %d panicked on Reset %d times in a row", i, attempt))
+		}
+	}
+}
+
+// guard calls fn and reports whether it returned normally. If fn panics,
+// the panic is recorded and environment i is replaced.
+func (v *VecEnv) guard(i int, op string, action int, fn func()) (ok bool) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		p := WorkerPanic{
+			Time:   time.Now(),
+			Worker: i,
+			Op:     op,
+			State:  v.last[i],
+			Action: action,
+			Value:  r,
+			Stack:  string(debug.Stack()),
+		}
+		v.mu.Lock()
+		v.panics = append(v.panics, p)
+		if v.onPanic != nil {
+			v.onPanic(p)
+		}
+		v.mu.Unlock()
+
+		if closer, ok := v.envs[i].(io.Closer); ok {
+			closer.Close()
+		}
+		v.envs[i] = v.factory()
+		ok = false
+	}()
+	fn()
+	return true
+}
+
+// err returns the first error reported by an environment, see envErr.
+func (v *VecEnv) err() error {
+	for _, env := range v.envs {
+		if err := envErr(env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parallel calls fn for every environment in its own goroutine and waits for
+// all of them. A panic that escapes fn, e.g. from reset giving up, is raised
+// again on the calling goroutine so that it can be recovered there.
+func (v *VecEnv) parallel(fn func(i int)) {
+	var wg sync.WaitGroup
+	panics := make([]any, len(v.envs))
+	for i := range v.envs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { panics[i] = recover() }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+	for _, p := range panics {
+		if p != nil {
+			panic(p)
+		}
+	}
+}
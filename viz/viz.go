@@ -0,0 +1,200 @@
+// Package viz plots training histories and reward curves with gonum/plot.
+// The output format follows the file extension, e.g. .png or .svg.
+package viz
+
+import (
+	"math"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+
+	"github.com/iampaapa/dqn"
+)
+
+// Series is a named sequence of per-episode values, e.g. the returns of one run.
+type Series struct {
+	Name   string
+	Values []float64
+}
+
+// Smooth returns the trailing moving average of values over window episodes.
+// Windows of one or less return the values unchanged.
+func Smooth(values []float64, window int) []float64 {
+	window = max(window, 1)
+	smoothed := make([]float64, len(values))
+	var sum float64
+	for i, v := range values {
+		sum += v
+		if i >= window {
+			sum -= values[i-window]
+		}
+		smoothed[i] = sum / float64(min(i+1, window))
+	}
+	return smoothed
+}
+
+// Returns extracts the episode returns of a training history.
+func Returns(history []dqn.EpisodeStats) []float64 {
+	values := make([]float64, len(history))
+	for i, stats := range history {
+		values[i] = stats.Return
+	}
+	return values
+}
+
+// Losses extracts the mean episode losses of a training history.
+func Losses(history []dqn.EpisodeStats) []float64 {
+	values := make([]float64, len(history))
+	for i, stats := range history {
+		values[i] = stats.Loss
+	}
+	return values
+}
+
+// Epsilons extracts the exploration rate of every episode of a training history.
+func Epsilons(history []dqn.EpisodeStats) []float64 {
+	values := make([]float64, len(history))
+	for i, stats := range history {
+		values[i] = stats.Epsilon
+	}
+	return values
+}
+
+// Curves plots one smoothed line per series against the episode number, with
+// a legend when there is more than one series, and saves it to path.
+func Curves(path, title, yLabel string, window int, series ...Series) error {
+	p := plot.New()
+	p.Title.Text = title
+	p.X.Label.Text = "Episode"
+	p.Y.Label.Text = yLabel
+
+	for i, s := range series {
+		smoothed := Smooth(s.Values, window)
+		data := make(plotter.XYs, len(smoothed))
+		for j, v := range smoothed {
+			data[j].X = float64(j)
+			data[j].Y = v
+		}
+		line, err := plotter.NewLine(data)
+		if err != nil {
+			return err
+		}
+		line.Color = plotutil.Color(i)
+		p.Add(line)
+		if len(series) > 1 {
+			p.Legend.Add(s.Name, line)
+		}
+	}
+	return p.Save(8*vg.Inch, 4*vg.Inch, path)
+}
+
+// RewardCurves plots the smoothed returns of one or more runs, e.g. to
+// compare agents or hyperparameters.
+func RewardCurves(path string, window int, runs ...Series) error {
+	return Curves(path, "Episode Return", "Return", window, runs...)
+}
+
+// LossCurve plots the smoothed mean loss of every episode of a training history.
+func LossCurve(path string, window int, history []dqn.EpisodeStats) error {
+	return Curves(path, "Training Loss", "Loss", window, Series{Name: "Loss", Values: Losses(history)})
+}
+
+// EpsilonSchedule plots the exploration rate of every episode of a training history.
+func EpsilonSchedule(path string, history []dqn.EpisodeStats) error {
+	return Curves(path, "Exploration Rate", "Epsilon", 1, Series{Name: "Epsilon", Values: Epsilons(history)})
+}
+
+// CheckpointCurve plots the mean evaluation return of every checkpoint
+// against the training step at which it was saved, with the interquartile
+// range of the returns as error bars.
+func CheckpointCurve(path string, evaluations []dqn.CheckpointEvaluation) error {
+	p := plot.New()
+	p.Title.Text = "Checkpoint Evaluation"
+	p.X.Label.Text = "Training Step"
+	p.Y.Label.Text = "Mean Return"
+
+	data := make(plotter.XYs, len(evaluations))
+	errs := make(plotter.YErrors, len(evaluations))
+	for i, e := range evaluations {
+		data[i].X = float64(e.Steps)
+		data[i].Y = e.Report.Mean
+		if len(e.Report.Quantiles) == len(dqn.ReportQuantiles) {
+			errs[i].Low = e.Report.Mean - quantile(e.Report, 0.25)
+			errs[i].High = quantile(e.Report, 0.75) - e.Report.Mean
+		}
+	}
+	line, points, err := plotter.NewLinePoints(data)
+	if err != nil {
+		return err
+	}
+	bars, err := plotter.NewYErrorBars(struct {
+		plotter.XYer
+		plotter.YErrorer
+	}{data, errs})
+	if err != nil {
+		return err
+	}
+	p.Add(line, points, bars)
+	return p.Save(8*vg.Inch, 4*vg.Inch, path)
+}
+
+// quantile returns the value of the report at quantile level q, or the mean
+// if the level is not reported.
+func quantile(r dqn.ReturnReport, q float64) float64 {
+	for i, level := range dqn.ReportQuantiles {
+		if level == q {
+			return r.Quantiles[i]
+		}
+	}
+	return r.Mean
+}
+
+// Calibration plots the realized returns against the predicted Q-values of
+// a calibration report, with the binned means and the diagonal of a
+// perfectly calibrated network.
+func Calibration(path string, report dqn.CalibrationReport) error {
+	p := plot.New()
+	p.Title.Text = "Q-value Calibration"
+	p.X.Label.Text = "Predicted Q-value"
+	p.Y.Label.Text = "Realized Return"
+
+	points := make(plotter.XYs, len(report.Points))
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i, pt := range report.Points {
+		points[i].X, points[i].Y = pt.Predicted, pt.Realized
+		lo = min(lo, pt.Predicted, pt.Realized)
+		hi = max(hi, pt.Predicted, pt.Realized)
+	}
+	scatter, err := plotter.NewScatter(points)
+	if err != nil {
+		return err
+	}
+	scatter.Color = plotutil.Color(0)
+	p.Add(scatter)
+
+	bins := make(plotter.XYs, len(report.Bins))
+	for i, bin := range report.Bins {
+		bins[i].X, bins[i].Y = bin.Predicted, bin.Realized
+	}
+	line, markers, err := plotter.NewLinePoints(bins)
+	if err != nil {
+		return err
+	}
+	line.Color = plotutil.Color(1)
+	markers.Color = plotutil.Color(1)
+	p.Add(line, markers)
+	p.Legend.Add("Binned", line, markers)
+
+	if len(points) > 0 {
+		diagonal, err := plotter.NewLine(plotter.XYs{{X: lo, Y: lo}, {X: hi, Y: hi}})
+		if err != nil {
+			return err
+		}
+		diagonal.Dashes = []vg.Length{vg.Points(4), vg.Points(4)}
+		p.Add(diagonal)
+		p.Legend.Add("Perfect", diagonal)
+	}
+	return p.Save(6*vg.Inch, 6*vg.Inch, path)
+}
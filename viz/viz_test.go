@@ -0,0 +1,65 @@
+package viz
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iampaapa/dqn"
+)
+
+func TestSmooth(t *testing.T) {
+	got := Smooth([]float64{1, 3, 5, 7}, 2)
+	want := []float64{1, 2, 4, 6}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, got)
+			break
+		}
+	}
+	if got := Smooth([]float64{1, 3}, 0); got[1] != 3 {
+		t.Errorf("Expected unsmoothed values, got %v", got)
+	}
+}
+
+func TestPlots(t *testing.T) {
+	dir := t.TempDir()
+	history := []dqn.EpisodeStats{
+		{Episode: 0, Return: 1, Loss: 0.5, Epsilon: 0.1},
+		{Episode: 1, Return: 2, Loss: 0.4, Epsilon: 0.1},
+	}
+	runs := []Series{{Name: "A", Values: Returns(history)}, {Name: "B", Values: []float64{2, 1}}}
+
+	paths := []string{
+		filepath.Join(dir, "rewards.png"),
+		filepath.Join(dir, "loss.svg"),
+		filepath.Join(dir, "epsilon.png"),
+		filepath.Join(dir, "checkpoints.png"),
+		filepath.Join(dir, "calibration.png"),
+	}
+	if err := RewardCurves(paths[0], 10, runs...); err != nil {
+		t.Fatal(err)
+	}
+	if err := LossCurve(paths[1], 10, history); err != nil {
+		t.Fatal(err)
+	}
+	if err := EpsilonSchedule(paths[2], history); err != nil {
+		t.Fatal(err)
+	}
+	evaluations := []dqn.CheckpointEvaluation{
+		{Steps: 100, Report: dqn.NewReturnReport([]float64{1, 2, 3}, 0.1, 2)},
+		{Steps: 200, Report: dqn.NewReturnReport([]float64{2, 3, 4}, 0.1, 2)},
+	}
+	if err := CheckpointCurve(paths[3], evaluations); err != nil {
+		t.Fatal(err)
+	}
+	calibration := dqn.NewCalibrationReport([]dqn.CalibrationPoint{{Predicted: 1, Realized: 0}, {Predicted: 2, Realized: 3}}, 2)
+	if err := Calibration(paths[4], calibration); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Errorf("Expected a plot at %s", path)
+		}
+	}
+}
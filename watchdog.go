@@ -0,0 +1,145 @@
+// watchdog.go
+package dqn
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Incident records an environment call that exceeded the watchdog timeout.
+type Incident struct {
+	Time    time.Time
+	Op      string // "reset" or "step"
+	Timeout time.Duration
+}
+
+// WatchdogEnv guards an environment against calls that block forever, e.g.
+// a hung remote simulator. When Reset or Step exceeds the timeout, the
+// environment is abandoned, closed if it implements io.Closer, and replaced
+// with a new one from the factory. A timed out Step ends the episode and a
+// timed out Reset is retried on the replacement, up to maxWorkerRestarts
+// times in a row.
+//
+// A panic in the environment is passed on to the caller of Reset or Step,
+// so that a VecEnv around the watchdog can recover it. The optional Seeder,
+// Err, Interrupted and Truncated methods are forwarded to the current
+// environment, and a timed out Step is reported as interrupted.
+type WatchdogEnv struct {
+	Environment
+	factory   func() Environment
+	timeout   time.Duration
+	state     []float64
+	incidents []Incident
+	// timedOut is set when the last Step timed out
+	timedOut bool
+	// seed is the last seed set, applied to replacements too
+	seed   int64
+	seeded bool
+}
+
+// NewWatchdogEnv initializes a new WatchdogEnv around an environment created
+// by factory. Use it as the VecEnv factory to guard every worker.
+func NewWatchdogEnv(factory func() Environment, timeout time.Duration) *WatchdogEnv {
+	return &WatchdogEnv{Environment: factory(), factory: factory, timeout: timeout}
+}
+
+// stepResult is the outcome of a Step run by the watchdog.
+type stepResult struct {
+	state    []float64
+	reward   float64
+	done     bool
+	panicked any // value passed to panic, nil if fn returned
+}
+
+// call runs fn on its own goroutine and reports whether it finished in time.
+// If fn panics, the panic is raised again on the calling goroutine.
+func (w *WatchdogEnv) call(op string, fn func() stepResult) (stepResult, bool) {
+	result := make(chan stepResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result <- stepResult{panicked: r}
+			}
+		}()
+		result <- fn()
+	}()
+
+	timer := time.NewTimer(w.timeout)
+	defer timer.Stop()
+	select {
+	case r := <-result:
+		if r.panicked != nil {
+			panic(r.panicked)
+		}
+		return r, true
+	case <-timer.C:
+		w.incidents = append(w.incidents, Incident{Time: time.Now(), Op: op, Timeout: w.timeout})
+		if closer, ok := w.Environment.(io.Closer); ok {
+			go closer.Close()
+		}
+		w.Environment = w.factory()
+		if w.seeded {
+			seedEnv(w.Environment, w.seed)
+		}
+		return stepResult{}, false
+	}
+}
+
+// Reset starts a new episode, replacing the environment if it hangs.
+func (w *WatchdogEnv) Reset() []float64 {
+	for attempt := 1; ; attempt++ {
+		env := w.Environment
+		r, ok := w.call("reset", func() stepResult { return stepResult{state: env.Reset()} })
+		if ok {
+			w.state, w.timedOut = r.state, false
+			return r.state
+		}
+		if attempt > maxWorkerRestarts {
+			panic(fmt.Sprintf("Environment timed out on Reset %d times in a row", attempt))
+		}
+	}
+}
+
+// Step applies an action. If the environment hangs it is replaced and the
+// episode ends with the last known state and a zero reward.
+func (w *WatchdogEnv) Step(action int) ([]float64, float64, bool) {
+	env := w.Environment
+	r, ok := w.call("step", func() stepResult {
+		state, reward, done := env.Step(action)
+		return stepResult{state: state, reward: reward, done: done}
+	})
+	w.timedOut = !ok
+	if !ok {
+		return w.state, 0, true
+	}
+	w.state = r.state
+	return r.state, r.reward, r.done
+}
+
+// Seed seeds the current environment, and its replacements, if it implements Seeder.
+func (w *WatchdogEnv) Seed(seed int64) {
+	w.seed, w.seeded = seed, true
+	seedEnv(w.Environment, seed)
+}
+
+// Err returns the error of the current environment, if it keeps one.
+func (w *WatchdogEnv) Err() error {
+	return envErr(w.Environment)
+}
+
+// Interrupted reports whether the last Step timed out or was interrupted in
+// the current environment.
+func (w *WatchdogEnv) Interrupted() bool {
+	return w.timedOut || interrupted(w.Environment)
+}
+
+// Truncated reports whether the last Step of the current environment truncated the episode.
+func (w *WatchdogEnv) Truncated() bool {
+	return !w.timedOut && truncated(w.Environment)
+}
+
+// Incidents returns every timeout so far.
+func (w *WatchdogEnv) Incidents() []Incident {
+	return w.incidents
+}
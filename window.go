@@ -0,0 +1,105 @@
+// window.go
+package dqn
+
+import (
+	"fmt"
+	"math"
+)
+
+// Aggregations supported by the windowing helpers.
+const (
+	WindowMean = "mean"
+	WindowMin  = "min"
+	WindowMax  = "max"
+)
+
+// Windows turns a stream of sensor readings into observations over windows of
+// size consecutive readings, starting a new window every stride readings.
+// Without aggregations an observation is the concatenation of the readings of
+// its window. Otherwise it holds, for each aggregation in order, the
+// aggregate of every sensor over the window. Incomplete trailing windows are
+// dropped.
+func Windows(readings [][]float64, size, stride int, aggregations ...string) ([][]float64, error) {
+	if size <= 0 || stride <= 0 {
+		return nil, fmt.Errorf("dqn: window size and stride must be positive")
+	}
+	var observations [][]float64
+	for start := 0; start+size <= len(readings); start += stride {
+		obs, err := windowObservation(readings[start:start+size], aggregations)
+		if err != nil {
+			return nil, err
+		}
+		observations = append(observations, obs)
+	}
+	return observations, nil
+}
+
+// windowObservation builds the observation of a single window.
+func windowObservation(window [][]float64, aggregations []string) ([]float64, error) {
+	var obs []float64
+	if len(aggregations) == 0 {
+		for _, reading := range window {
+			obs = append(obs, reading...)
+		}
+		return obs, nil
+	}
+	for _, agg := range aggregations {
+		for sensor := range window[0] {
+			var value float64
+			switch agg {
+			case WindowMean:
+				for _, reading := range window {
+					value += reading[sensor]
+				}
+				value /= float64(len(window))
+			case WindowMin:
+				value = math.Inf(1)
+				for _, reading := range window {
+					value = math.Min(value, reading[sensor])
+				}
+			case WindowMax:
+				value = math.Inf(-1)
+				for _, reading := range window {
+					value = math.Max(value, reading[sensor])
+				}
+			default:
+				return nil, fmt.Errorf("dqn: unknown window aggregation %q", agg)
+			}
+			obs = append(obs, value)
+		}
+	}
+	return obs, nil
+}
+
+// SlidingWindow builds windowed observations from a live stream of readings,
+// one per reading once the window is full.
+type SlidingWindow struct {
+	size         int
+	aggregations []string
+	readings     [][]float64
+}
+
+// NewSlidingWindow initializes a new SlidingWindow over size readings with
+// the given aggregations, as in Windows.
+func NewSlidingWindow(size int, aggregations ...string) *SlidingWindow {
+	return &SlidingWindow{size: size, aggregations: aggregations}
+}
+
+// Push adds a reading and returns the observation of the latest window, or
+// false while fewer than size readings have been pushed.
+func (w *SlidingWindow) Push(reading []float64) ([]float64, bool, error) {
+	w.readings = append(w.readings, reading)
+	if len(w.readings) > w.size {
+		w.readings = w.readings[1:]
+	}
+	if len(w.readings) < w.size {
+		return nil, false, nil
+	}
+	obs, err := windowObservation(w.readings, w.aggregations)
+	return obs, err == nil, err
+}
+
+// Reset clears the window, e.g. at the start of an episode.
+func (w *SlidingWindow) Reset() {
+	w.readings = nil
+}